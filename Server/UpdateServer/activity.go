@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ActivityArchivePath 活动日志归档文件，每行一条JSON记录，按时间先后追加
+const ActivityArchivePath = "./activity.jsonl"
+
+// DefaultActivityRotateSize 归档文件达到该字节数后轮转
+const DefaultActivityRotateSize = 64 << 20
+
+// activitySummaryAction 压缩后的下载汇总记录类型
+const activitySummaryAction = "download-summary"
+
+// ActivityArchiveConfig 活动日志归档配置
+type ActivityArchiveConfig struct {
+	// RotateSize 当前归档文件超过该字节数时改名为 activity-<时间>.jsonl 并新建文件，0 表示不轮转；轮转出的文件不会被删除
+	RotateSize int64 `json:"rotateSize"`
+}
+
+// ActivityCompactionConfig 活动日志压缩配置
+type ActivityCompactionConfig struct {
+	// MinAge 早于该时长的下载记录才会被合并，之后的记录原样保留
+	MinAge Duration `json:"minAge"`
+	// Interval 自动压缩的间隔，0 表示只能通过 /api/jobs 手动触发
+	Interval Duration `json:"interval"`
+}
+
+// ActivityCompactionResult 一次压缩的结果
+type ActivityCompactionResult struct {
+	Cutoff     time.Time `json:"cutoff"`
+	Before     int       `json:"before"`
+	After      int       `json:"after"`
+	Summarized int       `json:"summarized"`
+}
+
+var (
+	activityArchiveMu sync.Mutex
+
+	activityCompactionNextRun   time.Time
+	activityCompactionNextRunMu sync.Mutex
+)
+
+// archiveActivity 将活动追加到归档文件并同步到磁盘，归档是完整的审计记录，写入后才算记录成功
+func archiveActivity(activity ActivityLog) {
+	data, err := json.Marshal(activity)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	activityArchiveMu.Lock()
+	defer activityArchiveMu.Unlock()
+
+	if err := rotateActivityArchiveLocked(int64(len(data)), activity.Timestamp); err != nil {
+		// 轮转失败时继续写入当前文件，不丢失记录
+		log.Printf("Error rotating activity archive: %v", err)
+	}
+
+	file, err := os.OpenFile(ActivityArchivePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Error opening activity archive: %v", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data); err != nil {
+		log.Printf("Error writing activity archive: %v", err)
+		return
+	}
+	if err := file.Sync(); err != nil {
+		log.Printf("Error syncing activity archive: %v", err)
+	}
+}
+
+// rotatedActivityArchivePath 轮转后的归档文件名，按名称排序即按时间先后
+func rotatedActivityArchivePath(at time.Time) string {
+	ext := filepath.Ext(ActivityArchivePath)
+	base := strings.TrimSuffix(ActivityArchivePath, ext)
+	return fmt.Sprintf("%s-%s%s", base, at.UTC().Format("20060102T150405.000000000Z"), ext)
+}
+
+// rotateActivityArchiveLocked 追加 size 字节后当前归档会超过 rotateSize 时先将其改名，调用方需持有 activityArchiveMu
+func rotateActivityArchiveLocked(size int64, now time.Time) error {
+	limit := config.ActivityArchive.RotateSize
+	if limit <= 0 {
+		return nil
+	}
+	info, err := os.Stat(ActivityArchivePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 || info.Size()+size <= limit {
+		return nil
+	}
+
+	rotated := rotatedActivityArchivePath(now)
+	if err := os.Rename(ActivityArchivePath, rotated); err != nil {
+		return err
+	}
+	log.Printf("Rotated activity archive to %s (%d bytes)", rotated, info.Size())
+	return nil
+}
+
+// activityArchiveFiles 返回全部归档文件：轮转出的文件按时间先后，当前文件在最后
+func activityArchiveFiles() ([]string, error) {
+	ext := filepath.Ext(ActivityArchivePath)
+	rotated, err := filepath.Glob(strings.TrimSuffix(ActivityArchivePath, ext) + "-*" + ext)
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(rotated)
+	return append(rotated, ActivityArchivePath), nil
+}
+
+// readActivityArchiveLocked 按时间先后读取全部归档文件中的活动，调用方需持有 activityArchiveMu
+func readActivityArchiveLocked() ([]ActivityLog, error) {
+	paths, err := activityArchiveFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var activities []ActivityLog
+	for _, path := range paths {
+		fileActivities, err := readActivityFile(path)
+		if err != nil {
+			return nil, err
+		}
+		activities = append(activities, fileActivities...)
+	}
+	return activities, nil
+}
+
+// readActivityFile 读取单个归档文件，文件不存在时返回空，无法解析的行会被跳过
+func readActivityFile(path string) ([]ActivityLog, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var activities []ActivityLog
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var activity ActivityLog
+		if err := json.Unmarshal(line, &activity); err != nil {
+			log.Printf("Skipping malformed activity record: %v", err)
+			continue
+		}
+		activities = append(activities, activity)
+	}
+	return activities, scanner.Err()
+}
+
+// parseActivityTime 解析查询中的时间，接受 RFC3339 或 UTC 日期（2006-01-02）
+func parseActivityTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// activityMatchesAction 活动是否属于查询的类型之一；download 同时匹配压缩后的下载汇总记录
+func activityMatchesAction(activity ActivityLog, actions []string) bool {
+	if len(actions) == 0 {
+		return true
+	}
+	for _, action := range actions {
+		if activity.Action == action || (action == "download" && activity.Action == activitySummaryAction) {
+			return true
+		}
+	}
+	return false
+}
+
+// activitiesHandler 从归档（含轮转出的文件）查询活动日志，不受统计中最近50条的限制，重启后仍可查询，最新的在前
+// GET /api/activities?action=download,upload&since=2026-01-01&until=2026-02-01T00:00:00Z&limit=100&offset=0
+func activitiesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	var actions []string
+	for _, value := range query["action"] {
+		for _, action := range strings.Split(value, ",") {
+			if action = strings.TrimSpace(action); action != "" {
+				actions = append(actions, action)
+			}
+		}
+	}
+	var since, until time.Time
+	for name, target := range map[string]*time.Time{"since": &since, "until": &until} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		t, err := parseActivityTime(value)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s: expected RFC3339 time or YYYY-MM-DD", name))
+			return
+		}
+		*target = t
+	}
+
+	activityArchiveMu.Lock()
+	activities, err := readActivityArchiveLocked()
+	activityArchiveMu.Unlock()
+	if err != nil {
+		http.Error(w, "Failed to read activity log", http.StatusInternalServerError)
+		log.Printf("Error reading activity archive: %v", err)
+		return
+	}
+
+	// since 包含，until 不包含
+	matched := []ActivityLog{}
+	for _, activity := range activities {
+		if !activityMatchesAction(activity, actions) ||
+			(!since.IsZero() && activity.Timestamp.Before(since)) ||
+			(!until.IsZero() && !activity.Timestamp.Before(until)) {
+			continue
+		}
+		matched = append(matched, activity)
+	}
+	slices.Reverse(matched)
+	slices.SortStableFunc(matched, func(a, b ActivityLog) int { return b.Timestamp.Compare(a.Timestamp) })
+	writeList(w, r, matched)
+}
+
+// downloadGroup 同一文件同一天内的下载记录
+type downloadGroup struct {
+	count int
+	first time.Time
+	last  time.Time
+}
+
+// downloadTarget 返回下载记录或汇总记录对应的文件名
+func downloadTarget(activity ActivityLog) (string, bool) {
+	switch activity.Action {
+	case "download":
+		return strings.CutPrefix(activity.Details, "Downloaded: ")
+	case activitySummaryAction:
+		return activity.Target, activity.Target != ""
+	}
+	return "", false
+}
+
+// compactActivities 将早于 cutoff 的下载记录按文件和日期（UTC）合并为汇总记录
+// 已有的汇总记录参与合并，重复压缩结果不变；汇总记录位于该组第一条记录的位置
+func compactActivities(activities []ActivityLog, cutoff time.Time) ([]ActivityLog, int) {
+	groupKey := func(activity ActivityLog) (string, bool) {
+		target, ok := downloadTarget(activity)
+		if !ok || !activity.Timestamp.Before(cutoff) {
+			return "", false
+		}
+		return target + "\x00" + activity.Timestamp.UTC().Format("2006-01-02"), true
+	}
+
+	groups := make(map[string]*downloadGroup)
+	for _, activity := range activities {
+		key, ok := groupKey(activity)
+		if !ok {
+			continue
+		}
+
+		count, first, last := 1, activity.Timestamp, activity.Timestamp
+		if activity.Action == activitySummaryAction {
+			count = max(activity.Count, 1)
+			if activity.Until != nil {
+				last = *activity.Until
+			}
+		}
+
+		group := groups[key]
+		if group == nil {
+			groups[key] = &downloadGroup{count: count, first: first, last: last}
+			continue
+		}
+		group.count += count
+		if first.Before(group.first) {
+			group.first = first
+		}
+		if last.After(group.last) {
+			group.last = last
+		}
+	}
+
+	compacted := make([]ActivityLog, 0, len(activities))
+	emitted := make(map[string]bool)
+	summarized := 0
+	for _, activity := range activities {
+		key, ok := groupKey(activity)
+		group := groups[key]
+		if !ok || (group.count == 1 && activity.Action == "download") {
+			compacted = append(compacted, activity)
+			continue
+		}
+		if emitted[key] {
+			continue
+		}
+		emitted[key] = true
+
+		target, _ := downloadTarget(activity)
+		until := group.last
+		compacted = append(compacted, ActivityLog{
+			Timestamp: group.first,
+			Action:    activitySummaryAction,
+			Details: fmt.Sprintf("%d downloads of %s between %s and %s",
+				group.count, target, group.first.Format(time.RFC3339), group.last.Format(time.RFC3339)),
+			Target: target,
+			Count:  group.count,
+			Until:  &until,
+		})
+		summarized++
+	}
+	return compacted, summarized
+}
+
+// runActivityCompactionJob 压缩活动日志归档，每个归档文件单独压缩并原地重写
+func runActivityCompactionJob(ctx context.Context, job *backgroundJob) (interface{}, error) {
+	cutoff := time.Now().Add(-config.ActivityCompaction.MinAge.Duration)
+
+	activityArchiveMu.Lock()
+	defer activityArchiveMu.Unlock()
+
+	paths, err := activityArchiveFiles()
+	if err != nil {
+		return nil, err
+	}
+	setJobProgress(job, 0, len(paths))
+
+	result := ActivityCompactionResult{Cutoff: cutoff}
+	for i, path := range paths {
+		if ctx.Err() != nil {
+			return nil, errors.New("cancelled")
+		}
+		activities, err := readActivityFile(path)
+		if err != nil {
+			return nil, err
+		}
+		compacted, summarized := compactActivities(activities, cutoff)
+		result.Before += len(activities)
+		result.After += len(compacted)
+		result.Summarized += summarized
+
+		if len(compacted) != len(activities) {
+			var buf bytes.Buffer
+			encoder := json.NewEncoder(&buf)
+			for _, activity := range compacted {
+				if err := encoder.Encode(activity); err != nil {
+					return nil, err
+				}
+			}
+			if err := atomicWriteFile(path, buf.Bytes(), 0644); err != nil {
+				return nil, err
+			}
+		}
+		setJobProgress(job, i+1, len(paths))
+	}
+	return result, nil
+}
+
+// activityCompactionLoop 按配置的间隔定期启动压缩任务
+func activityCompactionLoop() {
+	interval := config.ActivityCompaction.Interval.Duration
+	if interval <= 0 {
+		return
+	}
+
+	for {
+		next := time.Now().Add(interval)
+		activityCompactionNextRunMu.Lock()
+		activityCompactionNextRun = next
+		activityCompactionNextRunMu.Unlock()
+
+		time.Sleep(time.Until(next))
+		if err := startJob("compact-activity"); err != nil && !errors.Is(err, errJobRunning) {
+			log.Printf("Error starting activity compaction: %v", err)
+		}
+	}
+}
+
+// ActivityCompactionStatus 活动日志压缩状态
+type ActivityCompactionStatus struct {
+	MinAge      Duration   `json:"minAge"`
+	Interval    Duration   `json:"interval"`
+	NextRun     *time.Time `json:"nextRun,omitempty"`
+	ArchiveSize int64      `json:"archiveSize"`
+	// ArchiveFiles 归档文件数（含轮转出的文件）
+	ArchiveFiles int       `json:"archiveFiles"`
+	Job          JobStatus `json:"job"`
+}
+
+// activityCompactionStatus 返回压缩配置、归档大小和最近一次任务状态
+func activityCompactionStatus() ActivityCompactionStatus {
+	status := ActivityCompactionStatus{
+		MinAge:   config.ActivityCompaction.MinAge,
+		Interval: config.ActivityCompaction.Interval,
+		Job:      jobStatus("compact-activity"),
+	}
+
+	activityCompactionNextRunMu.Lock()
+	if !activityCompactionNextRun.IsZero() {
+		next := activityCompactionNextRun
+		status.NextRun = &next
+	}
+	activityCompactionNextRunMu.Unlock()
+
+	paths, _ := activityArchiveFiles()
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			status.ArchiveSize += info.Size()
+			status.ArchiveFiles++
+		}
+	}
+	return status
+}
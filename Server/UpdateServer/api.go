@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// writeJSONError 以JSON格式返回错误
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// apiNotFoundHandler 未知的 /api/ 路由返回JSON 404
+func apiNotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSONError(w, http.StatusNotFound, "Unknown API route: "+r.URL.Path)
+}
+
+// splitSubpath 拆分前缀之后的路径段，出现空段、"." 或 ".." 时视为非法路径
+func splitSubpath(path, prefix string) ([]string, bool) {
+	rest, ok := strings.CutPrefix(path, prefix)
+	if !ok || rest == "" {
+		return nil, false
+	}
+
+	parts := strings.Split(rest, "/")
+	for _, part := range parts {
+		if part == "" || part == "." || part == ".." {
+			return nil, false
+		}
+	}
+	return parts, true
+}
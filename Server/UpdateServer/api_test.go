@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitSubpath(t *testing.T) {
+	tests := []struct {
+		path   string
+		prefix string
+		want   []string
+		ok     bool
+	}{
+		{"/api/manifests/stable", "/api/manifests/", []string{"stable"}, true},
+		{"/api/manifests/stable/history", "/api/manifests/", []string{"stable", "history"}, true},
+		{"/api/manifests/stable/history/20260101T000000.000000000Z/restore", "/api/manifests/",
+			[]string{"stable", "history", "20260101T000000.000000000Z", "restore"}, true},
+		{"/api/files/a.zip/publish", "/api/files/", []string{"a.zip", "publish"}, true},
+		{"/api/mods/my-mod/1.0.0", "/api/mods/", []string{"my-mod", "1.0.0"}, true},
+
+		// 末尾斜杠产生空段
+		{"/api/manifests/stable/", "/api/manifests/", nil, false},
+		{"/api/manifests/stable/history/", "/api/manifests/", nil, false},
+		{"/api/manifests/", "/api/manifests/", nil, false},
+		{"/api/manifests", "/api/manifests/", nil, false},
+
+		// 空段与相对路径段
+		{"/api/manifests//stable", "/api/manifests/", nil, false},
+		{"/api/manifests/stable//history", "/api/manifests/", nil, false},
+		{"/api/files/./a.zip", "/api/files/", nil, false},
+		{"/api/files/../config.json", "/api/files/", nil, false},
+		{"/api/files/a/../../b", "/api/files/", nil, false},
+
+		{"/api/other/stable", "/api/manifests/", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := splitSubpath(tt.path, tt.prefix)
+			if ok != tt.ok || !slices.Equal(got, tt.want) {
+				t.Errorf("splitSubpath(%q, %q) = %q, %v; want %q, %v", tt.path, tt.prefix, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+// newAPITestMux 按 main 中的注册方式组装管理API路由
+func newAPITestMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/manifests/", basicAuth(manifestRouteHandler))
+	mux.HandleFunc("/api/files/", basicAuth(deleteFileHandler))
+	mux.HandleFunc("/api/", apiNotFoundHandler)
+	return mux
+}
+
+func TestAPIRoutes(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		want     int
+		wantJSON bool
+	}{
+		{"unknown route", http.MethodGet, "/api/nope", http.StatusNotFound, true},
+		{"unknown nested route", http.MethodPost, "/api/nope/deeper/still", http.StatusNotFound, true},
+		{"unknown manifest subroute", http.MethodGet, "/api/manifests/stable/unknown", http.StatusNotFound, true},
+		{"unknown history subroute", http.MethodGet, "/api/manifests/stable/history/x/y/z", http.StatusNotFound, true},
+
+		{"manifest trailing slash", http.MethodPut, "/api/manifests/stable/", http.StatusBadRequest, false},
+		{"history trailing slash", http.MethodGet, "/api/manifests/stable/history/", http.StatusBadRequest, false},
+		{"file nested path", http.MethodDelete, "/api/files/a.zip/extra", http.StatusBadRequest, false},
+		{"file trailing slash", http.MethodDelete, "/api/files/a.zip/", http.StatusBadRequest, false},
+
+		// 空段和相对路径段由 ServeMux 规范化后重定向，不会到达处理器
+		{"empty segment", http.MethodGet, "/api/manifests//stable", http.StatusTemporaryRedirect, false},
+		{"dot segment", http.MethodDelete, "/api/files/./a.zip", http.StatusTemporaryRedirect, false},
+		{"dot-dot segment", http.MethodDelete, "/api/files/../config.json", http.StatusTemporaryRedirect, false},
+	}
+
+	mux := newAPITestMux()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			r.SetBasicAuth(AdminUsername, AdminPassword)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("%s %s = %d, want %d: %s", tt.method, tt.path, w.Code, tt.want, w.Body)
+			}
+			if !tt.wantJSON {
+				return
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var body map[string]string
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil || !strings.Contains(body["error"], tt.path) {
+				t.Errorf("body = %v (%v), want an error naming %s", body, err, tt.path)
+			}
+		})
+	}
+}
+
+// sessionCookie 为内置管理员签发面板会话Cookie
+func sessionCookie(t *testing.T) *http.Cookie {
+	t.Helper()
+	w := httptest.NewRecorder()
+	if err := issueSession(w, httptest.NewRequest(http.MethodPost, "/admin/login", nil), AdminUsername); err != nil {
+		t.Fatal(err)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("issueSession set %d cookies", len(cookies))
+	}
+	return cookies[0]
+}
+
+func TestSessionCookieOnlyOnPanelPaths(t *testing.T) {
+	t.Chdir(t.TempDir())
+	mux := newAPITestMux()
+	mux.HandleFunc("/admin/api/", panelAPI(mux))
+	cookie := sessionCookie(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		withCookie bool
+		want       int
+	}{
+		// /api/ 路由不接受会话Cookie，跨站请求无法借用浏览器中的会话
+		{"cookie on api route", "/api/manifests/stable/unknown", true, http.StatusUnauthorized},
+		{"cookie via panel", "/admin/api/manifests/stable/unknown", true, http.StatusNotFound},
+		{"panel without cookie", "/admin/api/manifests/stable/unknown", false, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.withCookie {
+				r.AddCookie(cookie)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("GET %s = %d, want %d: %s", tt.path, w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestIsSecureRequestTrustsForwardedProtoOnlyWhenEnabled(t *testing.T) {
+	old := config
+	t.Cleanup(func() { config = old })
+
+	r := httptest.NewRequest(http.MethodGet, "/admin/login", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+
+	config.TrustForwardedHeaders = false
+	if isSecureRequest(r) {
+		t.Error("X-Forwarded-Proto trusted with trustForwardedHeaders disabled")
+	}
+	config.TrustForwardedHeaders = true
+	if !isSecureRequest(r) {
+		t.Error("X-Forwarded-Proto ignored with trustForwardedHeaders enabled")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// appendUpdateHandler 向频道清单追加单个版本，无需提交完整清单；
+// 新版本高于当前最新版本时同时更新 latestVersion
+// POST /api/manifests/{channel}/updates {UpdateInfo}
+func appendUpdateHandler(w http.ResponseWriter, r *http.Request, channel string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	var update UpdateInfo
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if err := validateUpdateVersions("", update); err != nil {
+		writeVersionFieldError(w, err)
+		return
+	}
+
+	// 读取、追加和写入在同一把锁内完成，并发追加不会互相覆盖
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		return
+	}
+	if findUpdate(manifest, update.Version) != nil {
+		http.Error(w, "Version already exists", http.StatusConflict)
+		return
+	}
+	if !enforceCadence(w, r, channel) {
+		return
+	}
+
+	if update.ReleaseDate.IsZero() {
+		update.ReleaseDate = time.Now()
+	}
+	if update.Dependencies == nil {
+		update.Dependencies = []string{}
+	}
+	if err := signReleaseFiles(&update, false); err != nil {
+		log.Printf("Error signing release %s: %v", update.Version, err)
+	}
+
+	manifest.Updates = append(manifest.Updates, update)
+	if manifest.LatestVersion == "" || compareVersions(update.Version, manifest.LatestVersion) > 0 {
+		manifest.LatestVersion = update.Version
+	}
+	manifest.LastUpdated = time.Now()
+
+	pruned := applyRetention(channel, manifest)
+	err = saveManifest(channel, manifest)
+	if errors.Is(err, errInvalidManifest) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save manifest", http.StatusInternalServerError)
+		log.Printf("Error saving manifest: %v", err)
+		return
+	}
+	finishPrune(channel, pruned)
+	recordPublish(channel, manifest.LastUpdated)
+
+	// 返回保存后的条目（含规范化字段与签名）
+	if saved := findUpdate(manifest, update.Version); saved != nil {
+		update = *saved
+	}
+
+	addActivity("manifest", fmt.Sprintf("Appended %s to %s (latest: %s)", update.Version, channel, manifest.LatestVersion))
+	log.Printf("Version appended: %s/%s", channel, update.Version)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(update)
+}
@@ -0,0 +1,86 @@
+package main
+
+// 发布文件类型
+const (
+	AssetKindInstaller = "installer"
+	AssetKindPortable  = "portable"
+	AssetKindSymbols   = "symbols"
+)
+
+// Asset 版本的单个发布文件，第一个为主文件
+type Asset struct {
+	Url      string `json:"url"`
+	Size     int64  `json:"size"`
+	Hash     string `json:"hash"`
+	Platform string `json:"platform,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	// Signature 对 Hash 的 Ed25519 签名，与版本的 signature 相同方式生成
+	Signature    string `json:"signature,omitempty"`
+	SigningKeyId string `json:"signingKeyId,omitempty"`
+}
+
+// releaseAssets 返回版本的全部发布文件，旧格式清单只有顶层下载地址时视为单个文件
+func releaseAssets(update UpdateInfo) []Asset {
+	if len(update.Assets) > 0 {
+		return update.Assets
+	}
+	if update.DownloadUrl == "" {
+		return nil
+	}
+	return []Asset{{
+		Url:          update.DownloadUrl,
+		Size:         update.FileSize,
+		Hash:         update.FileHash,
+		Signature:    update.Signature,
+		SigningKeyId: update.SigningKeyId,
+	}}
+}
+
+// releaseFiles 返回版本引用的下载文件名
+func releaseFiles(update UpdateInfo) []string {
+	var names []string
+	for _, asset := range releaseAssets(update) {
+		if name := downloadFilename(asset.Url); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// assetForPlatform 返回适用于平台的发布文件：优先平台完全匹配，其次不限平台的文件，最后回退到主文件
+// 调试符号等非安装文件不参与选择
+func assetForPlatform(update UpdateInfo, platform string) (Asset, bool) {
+	assets := releaseAssets(update)
+	if len(assets) == 0 {
+		return Asset{}, false
+	}
+
+	var generic *Asset
+	for i := range assets {
+		asset := &assets[i]
+		if asset.Kind == AssetKindSymbols {
+			continue
+		}
+		if platform != "" && asset.Platform == platform {
+			return *asset, true
+		}
+		if asset.Platform == "" && generic == nil {
+			generic = asset
+		}
+	}
+	if generic != nil {
+		return *generic, true
+	}
+	return assets[0], true
+}
+
+// syncPrimaryAsset 用主文件填充顶层下载字段，兼容只读取 downloadUrl 的旧客户端
+func syncPrimaryAsset(update *UpdateInfo) {
+	if len(update.Assets) == 0 {
+		return
+	}
+	primary := update.Assets[0]
+	update.DownloadUrl = primary.Url
+	update.FileSize = primary.Size
+	update.FileHash = primary.Hash
+}
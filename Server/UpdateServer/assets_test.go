@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// legacyManifest 旧格式清单：没有结构版本，每个版本只有顶层下载地址
+const legacyManifest = `{
+  "latestVersion": "1.1.0",
+  "channel": "beta",
+  "updates": [
+    {
+      "version": "1.1.0",
+      "downloadUrl": "http://localhost:51000/downloads/LizardClient-1.1.0.zip",
+      "fileSize": 100,
+      "fileHash": "aaaa"
+    }
+  ]
+}`
+
+// multiAssetManifest 按平台提供多个发布文件的清单
+const multiAssetManifest = `{
+  "manifestVersion": "1.1.0",
+  "latestVersion": "1.2.0",
+  "channel": "dev",
+  "updates": [
+    {
+      "version": "1.2.0",
+      "assets": [
+        {"url": "http://localhost:51000/downloads/LizardClient-1.2.0-win.exe", "size": 10, "hash": "1111", "platform": "windows", "kind": "installer"},
+        {"url": "http://localhost:51000/downloads/LizardClient-1.2.0-linux.tar.gz", "size": 20, "hash": "2222", "platform": "linux", "kind": "portable"},
+        {"url": "http://localhost:51000/downloads/LizardClient-1.2.0.jar", "size": 30, "hash": "3333", "kind": "portable"},
+        {"url": "http://localhost:51000/downloads/LizardClient-1.2.0-symbols.zip", "size": 40, "hash": "4444", "kind": "symbols"}
+      ]
+    }
+  ]
+}`
+
+func TestLegacyAndMultiAssetManifests(t *testing.T) {
+	setupManifestTest(t)
+	if err := os.WriteFile(manifestPath("beta"), []byte(legacyManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(manifestPath("dev"), []byte(multiAssetManifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, err := loadManifest("beta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	asset, ok := assetForPlatform(*findUpdate(legacy, "1.1.0"), "windows")
+	if !ok || asset.Url != "http://localhost:51000/downloads/LizardClient-1.1.0.zip" || asset.Hash != "aaaa" || asset.Size != 100 {
+		t.Errorf("legacy release resolved to %+v, %v", asset, ok)
+	}
+
+	multi, err := loadManifest("dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := findUpdate(multi, "1.2.0")
+	if release == nil {
+		t.Fatal("release 1.2.0 not found")
+	}
+	for platform, want := range map[string]string{
+		"windows": "http://localhost:51000/downloads/LizardClient-1.2.0-win.exe",
+		"linux":   "http://localhost:51000/downloads/LizardClient-1.2.0-linux.tar.gz",
+		"macos":   "http://localhost:51000/downloads/LizardClient-1.2.0.jar",
+		"":        "http://localhost:51000/downloads/LizardClient-1.2.0.jar",
+	} {
+		if asset, ok := assetForPlatform(*release, platform); !ok || asset.Url != want {
+			t.Errorf("platform %q resolved to %q, want %q", platform, asset.Url, want)
+		}
+	}
+
+	refs := referencedFiles()
+	for name, channel := range map[string]string{
+		"LizardClient-1.0.0.zip":          "stable",
+		"LizardClient-1.1.0.zip":          "beta",
+		"LizardClient-1.2.0-win.exe":      "dev",
+		"LizardClient-1.2.0-linux.tar.gz": "dev",
+		"LizardClient-1.2.0.jar":          "dev",
+		"LizardClient-1.2.0-symbols.zip":  "dev",
+	} {
+		if !refs[name][channel] {
+			t.Errorf("referencedFiles()[%q] = %v, want channel %s", name, refs[name], channel)
+		}
+	}
+}
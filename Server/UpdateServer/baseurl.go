@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// BaseURLEnv 服务器对外地址的环境变量，优先于配置文件
+const BaseURLEnv = "BASE_URL"
+
+var baseURLFlag = flag.String("base-url", "", "public base URL used in generated links, e.g. https://updates.example.com; overrides "+BaseURLEnv+" and baseUrl")
+
+// applyBaseURLOverride 以启动参数和环境变量覆盖配置文件中的对外地址，并校验格式
+func applyBaseURLOverride() {
+	if *baseURLFlag != "" {
+		config.BaseURL = *baseURLFlag
+	} else if value := os.Getenv(BaseURLEnv); value != "" {
+		config.BaseURL = value
+	}
+	if config.BaseURL == "" {
+		return
+	}
+
+	config.BaseURL = strings.TrimSuffix(config.BaseURL, "/")
+	u, err := url.Parse(config.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		log.Printf("WARNING: ignoring invalid base URL %q; expected http(s)://host[:port][/path]", config.BaseURL)
+		config.BaseURL = ""
+	}
+}
+
+// defaultBaseURL 既未配置对外地址也没有请求可供推断时使用的本机地址
+func defaultBaseURL() string {
+	return fmt.Sprintf("%s://localhost:%s", serverScheme(), Port)
+}
+
+// persistentBaseURL 写入清单、模组信息等持久化数据的地址前缀，只来自配置，不受请求头影响
+func persistentBaseURL() string {
+	if config.BaseURL != "" {
+		return config.BaseURL
+	}
+	return defaultBaseURL()
+}
+
+// serverBaseURL 只用于当次响应的绝对地址：配置了 baseUrl 时使用配置，否则根据请求推断；结果不可写入磁盘
+func serverBaseURL(r *http.Request) string {
+	if config.BaseURL != "" {
+		return config.BaseURL
+	}
+	if r != nil {
+		return requestBaseURL(r)
+	}
+	return defaultBaseURL()
+}
+
+// manifestBaseURL 为频道清单生成新地址时使用的前缀：baseUrl > 清单的 updateServerUrl > 本机地址
+func manifestBaseURL(manifest *UpdateManifest) string {
+	if config.BaseURL != "" {
+		return config.BaseURL
+	}
+	if base := strings.TrimSuffix(manifest.UpdateServerUrl, "/"); base != "" {
+		return base
+	}
+	return defaultBaseURL()
+}
+
+// servedManifestBaseURL 返回公开清单中地址应改写成的前缀；未配置 baseUrl 且未开启 rewriteManifestUrls 时不改写
+func servedManifestBaseURL(r *http.Request) (string, bool) {
+	if config.BaseURL != "" {
+		return config.BaseURL, true
+	}
+	if config.RewriteManifestUrls {
+		return requestBaseURL(r), true
+	}
+	return "", false
+}
+
+// loadPublicManifest 读取频道清单，配置了 baseUrl 时改写其中的地址；
+// 供轻量检查、latest.json 等由清单派生的公开接口使用（结果可按清单文件缓存，不依赖请求）
+func loadPublicManifest(channel string) (*UpdateManifest, error) {
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		return nil, err
+	}
+	if config.BaseURL != "" {
+		rewriteManifestURLs(manifest, config.BaseURL)
+	}
+	return manifest, nil
+}
+
+// rewriteManifestURLs 将清单中以 updateServerUrl 开头的地址改写为 base，指向其他主机（如CDN）的地址保持不变；
+// 改写后重新计算 contentHash，返回是否有改动
+func rewriteManifestURLs(manifest *UpdateManifest, base string) bool {
+	old := strings.TrimSuffix(manifest.UpdateServerUrl, "/")
+	if old == "" || old == base {
+		return false
+	}
+
+	rewrite := func(s *string) {
+		rest, ok := strings.CutPrefix(*s, old)
+		if ok && (rest == "" || rest[0] == '/' || rest[0] == '?') {
+			*s = base + rest
+		}
+	}
+	manifest.UpdateServerUrl = base
+	for i := range manifest.Updates {
+		update := &manifest.Updates[i]
+		rewrite(&update.DownloadUrl)
+		rewrite(&update.ReleaseNotesUrl)
+		rewrite(&update.PatchUrl)
+		for j := range update.Assets {
+			rewrite(&update.Assets[j].Url)
+		}
+	}
+	manifest.ContentHash = manifestContentHash(manifest)
+	return true
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PatchMagic 差分补丁文件头
+// 补丁结构与 bsdiff 4.0 (BSDIFF40) 相同：32字节头（魔数、控制块长度、差异块长度、新文件大小），
+// 随后依次为控制块、差异块、额外数据块；三个数据块使用 gzip 而不是 bzip2 压缩
+const PatchMagic = "BSDIFFGZ"
+
+// bsdiff 生成把 old 转换为 new 的差分补丁
+func bsdiff(old, new []byte) ([]byte, error) {
+	suffixes := qsufsort(old)
+
+	var ctrl, diff, extra bytes.Buffer
+	writeCtrl := func(values ...int) {
+		var buf [8]byte
+		for _, v := range values {
+			putOfft(buf[:], int64(v))
+			ctrl.Write(buf[:])
+		}
+	}
+
+	oldSize, newSize := len(old), len(new)
+	var scan, pos, length, lastScan, lastPos, lastOffset int
+	for scan < newSize {
+		oldScore := 0
+		scan += length
+		for scsc := scan; scan < newSize; scan++ {
+			length, pos = search(suffixes, old, new[scan:], 0, oldSize)
+			for ; scsc < scan+length; scsc++ {
+				if scsc+lastOffset < oldSize && old[scsc+lastOffset] == new[scsc] {
+					oldScore++
+				}
+			}
+			if (length == oldScore && length != 0) || length > oldScore+8 {
+				break
+			}
+			if scan+lastOffset < oldSize && old[scan+lastOffset] == new[scan] {
+				oldScore--
+			}
+		}
+
+		if length == oldScore && scan != newSize {
+			continue
+		}
+
+		// 向前扩展上一段匹配
+		s, sf, lenf := 0, 0, 0
+		for i := 0; lastScan+i < scan && lastPos+i < oldSize; {
+			if old[lastPos+i] == new[lastScan+i] {
+				s++
+			}
+			i++
+			if s*2-i > sf*2-lenf {
+				sf, lenf = s, i
+			}
+		}
+
+		// 向后扩展当前匹配
+		lenb := 0
+		if scan < newSize {
+			s, sb := 0, 0
+			for i := 1; scan >= lastScan+i && pos >= i; i++ {
+				if old[pos-i] == new[scan-i] {
+					s++
+				}
+				if s*2-i > sb*2-lenb {
+					sb, lenb = s, i
+				}
+			}
+		}
+
+		// 两段扩展重叠时选择最佳分割点
+		if lastScan+lenf > scan-lenb {
+			overlap := (lastScan + lenf) - (scan - lenb)
+			s, ss, lens := 0, 0, 0
+			for i := 0; i < overlap; i++ {
+				if new[lastScan+lenf-overlap+i] == old[lastPos+lenf-overlap+i] {
+					s++
+				}
+				if new[scan-lenb+i] == old[pos-lenb+i] {
+					s--
+				}
+				if s > ss {
+					ss, lens = s, i+1
+				}
+			}
+			lenf += lens - overlap
+			lenb -= lens
+		}
+
+		for i := 0; i < lenf; i++ {
+			diff.WriteByte(new[lastScan+i] - old[lastPos+i])
+		}
+		extraLen := (scan - lenb) - (lastScan + lenf)
+		extra.Write(new[lastScan+lenf : lastScan+lenf+extraLen])
+
+		writeCtrl(lenf, extraLen, (pos-lenb)-(lastPos+lenf))
+
+		lastScan = scan - lenb
+		lastPos = pos - lenb
+		lastOffset = pos - scan
+	}
+
+	var patch bytes.Buffer
+	patch.WriteString(PatchMagic)
+	patch.Write(make([]byte, 24))
+
+	ctrlLen, err := writeGzipBlock(&patch, ctrl.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	diffLen, err := writeGzipBlock(&patch, diff.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writeGzipBlock(&patch, extra.Bytes()); err != nil {
+		return nil, err
+	}
+
+	header := patch.Bytes()
+	putOfft(header[8:16], ctrlLen)
+	putOfft(header[16:24], diffLen)
+	putOfft(header[24:32], int64(newSize))
+	return header, nil
+}
+
+// bspatch 将补丁应用到 old，返回新文件内容
+func bspatch(old, patch []byte) ([]byte, error) {
+	if len(patch) < 32 || string(patch[:8]) != PatchMagic {
+		return nil, errors.New("invalid patch header")
+	}
+	ctrlLen, diffLen, newSize := offtin(patch[8:16]), offtin(patch[16:24]), offtin(patch[24:32])
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || 32+ctrlLen+diffLen > int64(len(patch)) {
+		return nil, errors.New("corrupt patch header")
+	}
+
+	body := patch[32:]
+	ctrl, err := gzip.NewReader(bytes.NewReader(body[:ctrlLen]))
+	if err != nil {
+		return nil, fmt.Errorf("control block: %w", err)
+	}
+	diff, err := gzip.NewReader(bytes.NewReader(body[ctrlLen : ctrlLen+diffLen]))
+	if err != nil {
+		return nil, fmt.Errorf("diff block: %w", err)
+	}
+	extra, err := gzip.NewReader(bytes.NewReader(body[ctrlLen+diffLen:]))
+	if err != nil {
+		return nil, fmt.Errorf("extra block: %w", err)
+	}
+
+	new := make([]byte, newSize)
+	var oldPos, newPos int64
+	var buf [24]byte
+	for newPos < newSize {
+		if _, err := io.ReadFull(ctrl, buf[:]); err != nil {
+			return nil, fmt.Errorf("control block: %w", err)
+		}
+		diffLen, extraLen, seek := offtin(buf[0:8]), offtin(buf[8:16]), offtin(buf[16:24])
+		if diffLen < 0 || extraLen < 0 || newPos+diffLen+extraLen > newSize {
+			return nil, errors.New("corrupt patch control data")
+		}
+
+		if _, err := io.ReadFull(diff, new[newPos:newPos+diffLen]); err != nil {
+			return nil, fmt.Errorf("diff block: %w", err)
+		}
+		for i := int64(0); i < diffLen; i++ {
+			if oldPos+i >= 0 && oldPos+i < int64(len(old)) {
+				new[newPos+i] += old[oldPos+i]
+			}
+		}
+		newPos += diffLen
+		oldPos += diffLen
+
+		if _, err := io.ReadFull(extra, new[newPos:newPos+extraLen]); err != nil {
+			return nil, fmt.Errorf("extra block: %w", err)
+		}
+		newPos += extraLen
+		oldPos += seek
+	}
+	return new, nil
+}
+
+// writeGzipBlock 压缩并写入一个数据块，返回压缩后的长度
+func writeGzipBlock(w *bytes.Buffer, data []byte) (int64, error) {
+	start := w.Len()
+	gz, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := gz.Write(data); err != nil {
+		return 0, err
+	}
+	if err := gz.Close(); err != nil {
+		return 0, err
+	}
+	return int64(w.Len() - start), nil
+}
+
+// putOfft 按 bsdiff 的符号-数值格式写入64位整数（小端序，最高位为符号位）
+func putOfft(buf []byte, x int64) {
+	u := uint64(x)
+	if x < 0 {
+		u = uint64(-x) | 1<<63
+	}
+	binary.LittleEndian.PutUint64(buf, u)
+}
+
+// offtin 读取 putOfft 写入的整数
+func offtin(buf []byte) int64 {
+	u := binary.LittleEndian.Uint64(buf)
+	x := int64(u &^ (1 << 63))
+	if u&(1<<63) != 0 {
+		x = -x
+	}
+	return x
+}
+
+// matchLen 返回 a 与 b 的公共前缀长度
+func matchLen(a, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// search 在后缀数组的 [st, en] 区间内二分查找与 target 最长的匹配，返回匹配长度和在 old 中的位置
+func search(suffixes []int32, old, target []byte, st, en int) (int, int) {
+	for en-st >= 2 {
+		mid := st + (en-st)/2
+		suffix := old[suffixes[mid]:]
+		n := min(len(suffix), len(target))
+		if bytes.Compare(suffix[:n], target[:n]) < 0 {
+			st = mid
+		} else {
+			en = mid
+		}
+	}
+
+	x := matchLen(old[suffixes[st]:], target)
+	y := matchLen(old[suffixes[en]:], target)
+	if x > y {
+		return x, int(suffixes[st])
+	}
+	return y, int(suffixes[en])
+}
+
+// qsufsort 使用 Larsson-Sadakane 算法构建后缀数组（含空后缀，长度为 len(old)+1）
+// 需要约 8 倍于 old 大小的内存
+func qsufsort(old []byte) []int32 {
+	n := len(old)
+	I := make([]int32, n+1)
+	V := make([]int32, n+1)
+
+	var buckets [256]int32
+	for _, c := range old {
+		buckets[c]++
+	}
+	for i := 1; i < 256; i++ {
+		buckets[i] += buckets[i-1]
+	}
+	for i := 255; i > 0; i-- {
+		buckets[i] = buckets[i-1]
+	}
+	buckets[0] = 0
+
+	for i, c := range old {
+		buckets[c]++
+		I[buckets[c]] = int32(i)
+	}
+	I[0] = int32(n)
+	for i, c := range old {
+		V[i] = buckets[c]
+	}
+	V[n] = 0
+	for i := 1; i < 256; i++ {
+		if buckets[i] == buckets[i-1]+1 {
+			I[buckets[i]] = -1
+		}
+	}
+	I[0] = -1
+
+	for h := 1; I[0] != -int32(n+1); h += h {
+		length := 0
+		i := 0
+		for i < n+1 {
+			if I[i] < 0 {
+				length -= int(I[i])
+				i -= int(I[i])
+			} else {
+				if length != 0 {
+					I[i-length] = -int32(length)
+				}
+				length = int(V[I[i]]) + 1 - i
+				suffixSplit(I, V, i, length, h)
+				i += length
+				length = 0
+			}
+		}
+		if length != 0 {
+			I[i-length] = -int32(length)
+		}
+	}
+
+	for i := 0; i < n+1; i++ {
+		I[V[i]] = int32(i)
+	}
+	return I
+}
+
+// suffixSplit qsufsort 的三路划分步骤
+func suffixSplit(I, V []int32, start, length, h int) {
+	if length < 16 {
+		for k := start; k < start+length; {
+			j := 1
+			x := V[int(I[k])+h]
+			for i := 1; k+i < start+length; i++ {
+				if v := V[int(I[k+i])+h]; v < x {
+					x = v
+					j = 0
+				}
+				if V[int(I[k+i])+h] == x {
+					I[k+j], I[k+i] = I[k+i], I[k+j]
+					j++
+				}
+			}
+			for i := 0; i < j; i++ {
+				V[I[k+i]] = int32(k + j - 1)
+			}
+			if j == 1 {
+				I[k] = -1
+			}
+			k += j
+		}
+		return
+	}
+
+	x := V[int(I[start+length/2])+h]
+	jj, kk := 0, 0
+	for i := start; i < start+length; i++ {
+		v := V[int(I[i])+h]
+		if v < x {
+			jj++
+		}
+		if v == x {
+			kk++
+		}
+	}
+	jj += start
+	kk += jj
+
+	i, j, k := start, 0, 0
+	for i < jj {
+		v := V[int(I[i])+h]
+		switch {
+		case v < x:
+			i++
+		case v == x:
+			I[i], I[jj+j] = I[jj+j], I[i]
+			j++
+		default:
+			I[i], I[kk+k] = I[kk+k], I[i]
+			k++
+		}
+	}
+	for jj+j < kk {
+		if V[int(I[jj+j])+h] == x {
+			j++
+		} else {
+			I[jj+j], I[kk+k] = I[kk+k], I[jj+j]
+			k++
+		}
+	}
+
+	if jj > start {
+		suffixSplit(I, V, start, jj-start, h)
+	}
+	for i := 0; i < kk-jj; i++ {
+		V[I[jj+i]] = int32(kk - 1)
+	}
+	if jj == kk-1 {
+		I[jj] = -1
+	}
+	if start+length > kk {
+		suffixSplit(I, V, kk, start+length-kk, h)
+	}
+}
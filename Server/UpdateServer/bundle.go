@@ -0,0 +1,159 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// BundleIndex 离线安装包索引，列出包内每个文件的SHA256，整体由发布密钥签名
+type BundleIndex struct {
+	Channel      string            `json:"channel"`
+	Version      string            `json:"version"`
+	Platform     string            `json:"platform,omitempty"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	SigningKeyId string            `json:"signingKeyId"`
+	Files        map[string]string `json:"files"`
+}
+
+// bundleHandler 打包频道当前版本的文件、更新日志、清单条目和签名为ZIP（流式生成）
+// GET /api/bundle?channel=stable&platform=windows
+func bundleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	channel := r.URL.Query().Get("channel")
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		return
+	}
+
+	release := currentRelease(manifest)
+	if release == nil {
+		http.Error(w, "No releases available", http.StatusNotFound)
+		return
+	}
+
+	// 多文件发布按平台选择打包的文件
+	asset, _ := assetForPlatform(*release, r.URL.Query().Get("platform"))
+	filename := downloadFilename(asset.Url)
+	file, err := os.Open(filepath.Join(DownloadsDir, filename))
+	if filename == "" || err != nil {
+		http.Error(w, "Release file not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	entry := *release
+	if err := signReleaseFiles(&entry, false); err != nil {
+		log.Printf("Error signing bundle release: %v", err)
+	}
+
+	bundleName := fmt.Sprintf("LizardClient-%s-%s-bundle.zip", channel, release.Version)
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", bundleName))
+
+	index := BundleIndex{
+		Channel:      channel,
+		Version:      release.Version,
+		Platform:     r.URL.Query().Get("platform"),
+		CreatedAt:    time.Now(),
+		SigningKeyId: currentKeyID(),
+		Files:        make(map[string]string),
+	}
+
+	zw := zip.NewWriter(w)
+	defer zw.Close()
+
+	// 发布文件本身已压缩，直接存储
+	if err := writeBundleFile(zw, filename, zip.Store, file, index.Files); err != nil {
+		log.Printf("Error writing bundle: %v", err)
+		return
+	}
+
+	changelog := release.Changelog
+	if data, err := os.ReadFile(filepath.Join(ChangelogsDir, release.Version+".md")); err == nil {
+		changelog = string(data)
+	}
+	if err := writeBundleBytes(zw, "changelog.md", []byte(changelog), index.Files); err != nil {
+		log.Printf("Error writing bundle: %v", err)
+		return
+	}
+
+	entryData, _ := json.MarshalIndent(entry, "", "  ")
+	if err := writeBundleBytes(zw, "manifest-entry.json", entryData, index.Files); err != nil {
+		log.Printf("Error writing bundle: %v", err)
+		return
+	}
+
+	signatureData, _ := json.MarshalIndent(map[string]string{
+		"algorithm":    "ed25519",
+		"fileHash":     entry.FileHash,
+		"signature":    entry.Signature,
+		"signingKeyId": entry.SigningKeyId,
+	}, "", "  ")
+	if err := writeBundleBytes(zw, "signature.json", signatureData, index.Files); err != nil {
+		log.Printf("Error writing bundle: %v", err)
+		return
+	}
+
+	// 索引及其签名放在最后，覆盖以上所有文件
+	indexData, _ := json.MarshalIndent(index, "", "  ")
+	indexSig, _, err := signData(indexData)
+	if err != nil {
+		log.Printf("Error signing bundle index: %v", err)
+	}
+	if err := writeBundleBytes(zw, "index.json", indexData, nil); err != nil {
+		log.Printf("Error writing bundle: %v", err)
+		return
+	}
+	if err := writeBundleBytes(zw, "index.json.sig", []byte(indexSig), nil); err != nil {
+		log.Printf("Error writing bundle: %v", err)
+		return
+	}
+
+	addActivity("bundle", fmt.Sprintf("Bundled %s/%s", channel, release.Version))
+}
+
+// writeBundleFile 将数据流写入ZIP，并记录其SHA256
+func writeBundleFile(zw *zip.Writer, name string, method uint16, src io.Reader, hashes map[string]string) error {
+	dst, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   method,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(dst, hash), src); err != nil {
+		return err
+	}
+	if hashes != nil {
+		hashes[name] = hex.EncodeToString(hash.Sum(nil))
+	}
+	return nil
+}
+
+// writeBundleBytes 以压缩方式写入小文件
+func writeBundleBytes(zw *zip.Writer, name string, data []byte, hashes map[string]string) error {
+	return writeBundleFile(zw, name, zip.Deflate, bytes.NewReader(data), hashes)
+}
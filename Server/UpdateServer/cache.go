@@ -0,0 +1,120 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DefaultCacheControl 未匹配任何规则时使用的 Cache-Control
+	DefaultCacheControl = "no-cache"
+	// DefaultImmutableCacheControl 文件名带版本号或内容哈希的下载文件使用的 Cache-Control
+	DefaultImmutableCacheControl = "public, max-age=31536000, immutable"
+
+	// downloadsRoutePrefix 下载文件的路由前缀
+	downloadsRoutePrefix = "/downloads/"
+)
+
+// immutableFilenamePattern 文件名中的版本号（如 _v1.2.0、-2.1）或内容哈希（32位以上十六进制）
+var immutableFilenamePattern = regexp.MustCompile(`(?i)(^|[^a-z0-9])v?\d+(\.\d+)+|[0-9a-f]{32,}`)
+
+// isImmutableDownload 下载文件名是否带版本号或内容哈希；同名文件被替换的情况应只出现在不带版本号的文件上
+func isImmutableDownload(urlPath string) bool {
+	name, ok := strings.CutPrefix(urlPath, downloadsRoutePrefix)
+	if !ok || name == "" || strings.Contains(name, "/") {
+		return false
+	}
+	return immutableFilenamePattern.MatchString(strings.TrimSuffix(name, path.Ext(name)))
+}
+
+// immutableDownloadName 下载目录中的文件是否以带 immutable 指令的 Cache-Control 提供；
+// 这类文件被替换后浏览器和CDN会继续使用旧内容，因此不允许覆盖上传
+func immutableDownloadName(filename string) bool {
+	cacheControl := cacheControlFor(downloadsRoutePrefix+filename, contentTypeFor(filename).ContentType)
+	for directive := range strings.SplitSeq(cacheControl, ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "immutable") {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControlFor 返回响应的 Cache-Control
+// 先按最长路由前缀匹配（以 "/" 开头的键），再按内容类型匹配，否则使用默认值；
+// 只匹配到 /downloads/ 规则的带版本号或内容哈希的文件使用 immutableCacheControl
+func cacheControlFor(path, contentType string) string {
+	value, matched := "", ""
+	for key, v := range config.CacheControl {
+		if strings.HasPrefix(key, "/") && strings.HasPrefix(path, key) && len(key) > len(matched) {
+			value, matched = v, key
+		}
+	}
+	if matched != "" {
+		if len(matched) <= len(downloadsRoutePrefix) && config.ImmutableCacheControl != "" && isImmutableDownload(path) {
+			return config.ImmutableCacheControl
+		}
+		return value
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if v, ok := config.CacheControl[mediaType]; ok {
+			return v
+		}
+	}
+	return DefaultCacheControl
+}
+
+// cacheControlWriter 在写出响应头前补充 Cache-Control，处理器自行设置的值优先
+type cacheControlWriter struct {
+	http.ResponseWriter
+	path        string
+	wroteHeader bool
+}
+
+func (w *cacheControlWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		header := w.Header()
+		if header.Get("Cache-Control") == "" {
+			// 错误响应不应被缓存
+			if status >= http.StatusBadRequest {
+				header.Set("Cache-Control", DefaultCacheControl)
+			} else {
+				header.Set("Cache-Control", cacheControlFor(w.path, header.Get("Content-Type")))
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cacheControlWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush 透传流式刷新
+func (w *cacheControlWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问底层ResponseWriter
+func (w *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// cacheControlMiddleware 统一按配置设置缓存头
+func cacheControlMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, path: r.URL.Path}, r)
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestCacheControlForDownloads(t *testing.T) {
+	oldConfig := config
+	t.Cleanup(func() { config = oldConfig })
+	config = defaultConfig()
+
+	short := config.CacheControl["/downloads/"]
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/downloads/LizardClient_v1.0.0.zip", DefaultImmutableCacheControl},
+		{"/downloads/mod-2.1.jar", DefaultImmutableCacheControl},
+		{"/downloads/LizardClient-3.0.0-beta.1.exe", DefaultImmutableCacheControl},
+		{"/downloads/e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855.zip", DefaultImmutableCacheControl},
+		{"/downloads/LizardClient.zip", short},
+		{"/downloads/setup2.exe", short},
+		{"/downloads/README.md", short},
+		{"/downloads/" + ChecksumsFilename, "public, max-age=60"},
+		{"/manifest-stable.json", "public, max-age=60"},
+	}
+	for _, tt := range tests {
+		if got := cacheControlFor(tt.path, ""); got != tt.want {
+			t.Errorf("cacheControlFor(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	config.ImmutableCacheControl = ""
+	if got := cacheControlFor("/downloads/LizardClient_v1.0.0.zip", ""); got != short {
+		t.Errorf("with immutableCacheControl disabled got %q, want %q", got, short)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// PublishTimesFile 各频道最近一次发布时间的存储文件
+const PublishTimesFile = "./publishes.json"
+
+var (
+	lastPublishes       map[string]time.Time
+	lastPublishesMu     sync.Mutex
+	lastPublishesLoaded sync.Once
+)
+
+// CadenceStatus 频道发布节奏状态
+type CadenceStatus struct {
+	Channel          string     `json:"channel"`
+	MinInterval      Duration   `json:"minInterval"`
+	LastPublishedAt  *time.Time `json:"lastPublishedAt,omitempty"`
+	NextAllowedAt    *time.Time `json:"nextAllowedAt,omitempty"`
+	RemainingSeconds int        `json:"remainingSeconds"`
+}
+
+// loadLastPublishesLocked 首次访问时加载发布时间，调用方需持有锁
+func loadLastPublishesLocked() {
+	lastPublishesLoaded.Do(func() {
+		lastPublishes = make(map[string]time.Time)
+		data, err := os.ReadFile(PublishTimesFile)
+		if err != nil {
+			return
+		}
+		if err := json.Unmarshal(data, &lastPublishes); err != nil {
+			log.Printf("Error loading publish times: %v", err)
+		}
+	})
+}
+
+// recordPublish 记录频道的发布时间
+func recordPublish(channel string, at time.Time) {
+	lastPublishesMu.Lock()
+	defer lastPublishesMu.Unlock()
+	loadLastPublishesLocked()
+
+	lastPublishes[channel] = at
+	data, err := json.MarshalIndent(lastPublishes, "", "  ")
+	if err == nil {
+		err = atomicWriteFile(PublishTimesFile, data, 0644)
+	}
+	if err != nil {
+		log.Printf("Error saving publish times: %v", err)
+	}
+}
+
+// cadenceStatus 返回频道的发布节奏状态，未配置最小间隔时不限制
+func cadenceStatus(channel string, now time.Time) CadenceStatus {
+	lastPublishesMu.Lock()
+	loadLastPublishesLocked()
+	last, ok := lastPublishes[channel]
+	lastPublishesMu.Unlock()
+
+	status := CadenceStatus{Channel: channel, MinInterval: config.ReleaseCadence[channel]}
+	if !ok {
+		return status
+	}
+	status.LastPublishedAt = &last
+
+	if status.MinInterval.Duration > 0 {
+		next := last.Add(status.MinInterval.Duration)
+		status.NextAllowedAt = &next
+		if remaining := next.Sub(now); remaining > 0 {
+			status.RemainingSeconds = int(remaining.Seconds()) + 1
+		}
+	}
+	return status
+}
+
+// errCadenceInEffect 发布时间早于频道最小发布间隔允许的时间
+var errCadenceInEffect = errors.New("release cadence in effect")
+
+// checkCadenceAt 检查频道在 at 时刻发布是否满足最小发布间隔，定时发布在创建和到期发布时各检查一次
+func checkCadenceAt(channel string, at time.Time) error {
+	status := cadenceStatus(channel, at)
+	if status.RemainingSeconds == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: next publish allowed at %s", errCadenceInEffect, status.NextAllowedAt.Format(time.RFC3339))
+}
+
+// enforceCadence 检查频道发布间隔，过早的发布返回 429，?override=true 时放行并单独记录
+// 返回 false 表示已写入错误响应
+func enforceCadence(w http.ResponseWriter, r *http.Request, channel string) bool {
+	status := cadenceStatus(channel, time.Now())
+	if status.RemainingSeconds == 0 {
+		return true
+	}
+
+	if r.URL.Query().Get("override") == "true" {
+		log.Printf("Release cadence OVERRIDDEN for %s (%ds remaining)", channel, status.RemainingSeconds)
+		addActivity("cadence-override", fmt.Sprintf("Overrode %s release cadence (%ds remaining)", channel, status.RemainingSeconds))
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", status.RemainingSeconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":            "Release cadence in effect",
+		"remainingSeconds": status.RemainingSeconds,
+		"nextAllowedAt":    status.NextAllowedAt,
+	})
+	return false
+}
+
+// cadenceHandler 各频道发布节奏状态
+// GET /api/cadence
+func cadenceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	now := time.Now()
+	statuses := make([]CadenceStatus, 0, len(Channels))
+	for _, channel := range Channels {
+		statuses = append(statuses, cadenceStatus(channel, now))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		want    interface{}
+		wantErr bool
+	}{
+		{"uint", []byte{0x18, 0x64}, int64(100), false},
+		{"negative", []byte{0x38, 0x63}, int64(-100), false},
+		{"text", []byte{0x63, 'a', 'b', 'c'}, "abc", false},
+		{"bytes", []byte{0x42, 0x01, 0x02}, []byte{0x01, 0x02}, false},
+		{"array", []byte{0x82, 0x01, 0xf5}, []interface{}{int64(1), true}, false},
+		// COSE 密钥风格的整数键映射 {1: 2, -1: "x"}
+		{"int keys", []byte{0xa2, 0x01, 0x02, 0x20, 0x61, 'x'},
+			map[interface{}]interface{}{int64(1): int64(2), int64(-1): "x"}, false},
+		{"text keys", []byte{0xa1, 0x63, 'f', 'm', 't', 0x64, 'n', 'o', 'n', 'e'},
+			map[interface{}]interface{}{"fmt": "none"}, false},
+
+		{"array key", []byte{0xa1, 0x80, 0x01}, nil, true},
+		{"map key", []byte{0xa1, 0xa0, 0x01}, nil, true},
+		{"byte string key", []byte{0xa1, 0x41, 0x00, 0x01}, nil, true},
+		{"bool key", []byte{0xa1, 0xf5, 0x01}, nil, true},
+		{"truncated", []byte{0x63, 'a'}, nil, true},
+		{"truncated map", []byte{0xa2, 0x01, 0x02}, nil, true},
+		{"uint overflow", []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, nil, true},
+		{"negative overflow", []byte{0x3b, 0x80, 0, 0, 0, 0, 0, 0, 0}, nil, true},
+		{"indefinite", []byte{0x9f, 0x01, 0xff}, nil, true},
+		{"tag", []byte{0xc0, 0x01}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := decodeCBOR(tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("decodeCBOR(% x) = %v, want error", tt.data, got)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCBOR(% x) = %#v, %v; want %#v", tt.data, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeCBORDepth(t *testing.T) {
+	// nested 返回 n 层单元素数组包裹的整数
+	nested := func(n int) []byte {
+		return append(bytes.Repeat([]byte{0x81}, n), 0x01)
+	}
+
+	if _, _, err := decodeCBOR(nested(maxCBORDepth)); err != nil {
+		t.Errorf("%d levels: %v", maxCBORDepth, err)
+	}
+	if _, _, err := decodeCBOR(nested(maxCBORDepth + 1)); err == nil {
+		t.Errorf("%d levels decoded, want error", maxCBORDepth+1)
+	}
+	// 深度远超上限的输入同样只返回错误
+	if _, _, err := decodeCBOR(nested(100000)); err == nil {
+		t.Error("deeply nested input decoded, want error")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ChangelogDiffEntry 版本区间内单个版本的变更信息
+type ChangelogDiffEntry struct {
+	Version     string    `json:"version"`
+	ReleaseDate time.Time `json:"releaseDate"`
+	Mandatory   bool      `json:"mandatory"`
+	Critical    bool      `json:"critical"`
+	FileSize    int64     `json:"fileSize"`
+	// SizeDelta 相对上一个版本的下载大小变化，起始版本不在清单中时为 null
+	SizeDelta *int64 `json:"sizeDelta"`
+	Changelog string `json:"changelog"`
+	// ChangelogSource 更新日志来源："file"（changelogs目录）、"manifest"（清单 changelog 字段）或 "missing"
+	ChangelogSource string `json:"changelogSource"`
+}
+
+// ChangelogDiffResponse 两个版本之间的结构化更新日志
+type ChangelogDiffResponse struct {
+	Channel   string               `json:"channel"`
+	From      string               `json:"from"`
+	To        string               `json:"to"`
+	Mandatory bool                 `json:"mandatory"`
+	SizeDelta *int64               `json:"sizeDelta"`
+	Missing   []string             `json:"missingChangelogs"`
+	Versions  []ChangelogDiffEntry `json:"versions"`
+}
+
+// versionChangelog 返回版本的更新日志，优先读取 changelogs 目录中的文件
+func versionChangelog(update UpdateInfo) (string, string) {
+	if data, err := os.ReadFile(filepath.Join(ChangelogsDir, update.Version+".md")); err == nil {
+		return string(data), "file"
+	}
+	if strings.TrimSpace(update.Changelog) != "" {
+		return update.Changelog, "manifest"
+	}
+	return "", "missing"
+}
+
+// manifestChangelog 在各频道清单中查找版本并返回其 changelog 字段，按 Channels 顺序取第一个非空的
+func manifestChangelog(version string) (string, bool) {
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+		if update := findUpdate(manifest, version); update != nil && strings.TrimSpace(update.Changelog) != "" {
+			return update.Changelog, true
+		}
+	}
+	return "", false
+}
+
+// changelogDiffHandler 返回 from（不含）到 to（含）之间各版本的更新日志与元数据，按语义化版本升序排列
+// 撤回的版本不计入区间；缺少更新日志的版本仍会列出并记入 missingChangelogs
+// GET /api/changelog/diff?channel=stable&from=1.0.0&to=1.2.0
+func changelogDiffHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	channel := query.Get("channel")
+	if channel == "" {
+		channel = "stable"
+	}
+	if !isValidChannel(channel) {
+		writeJSONError(w, http.StatusBadRequest, "Invalid channel")
+		return
+	}
+	from, to := query.Get("from"), query.Get("to")
+	if !isValidVersion(from) || !isValidVersion(to) {
+		writeJSONError(w, http.StatusBadRequest, "from and to must be valid versions")
+		return
+	}
+	if compareVersions(from, to) >= 0 {
+		writeJSONError(w, http.StatusBadRequest, "from must be lower than to")
+		return
+	}
+
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to read manifest")
+		return
+	}
+	if target := findUpdate(manifest, to); target == nil || target.Yanked {
+		writeJSONError(w, http.StatusNotFound, "Version not found: "+to)
+		return
+	}
+
+	var updates []UpdateInfo
+	for _, update := range manifest.Updates {
+		if update.Yanked || compareVersions(update.Version, from) <= 0 || compareVersions(update.Version, to) > 0 {
+			continue
+		}
+		updates = append(updates, update)
+	}
+	sort.SliceStable(updates, func(i, j int) bool {
+		return compareVersions(updates[i].Version, updates[j].Version) < 0
+	})
+
+	response := ChangelogDiffResponse{
+		Channel:  channel,
+		From:     from,
+		To:       to,
+		Missing:  []string{},
+		Versions: make([]ChangelogDiffEntry, 0, len(updates)),
+	}
+
+	var previousSize *int64
+	if base := findUpdate(manifest, from); base != nil {
+		size := base.FileSize
+		previousSize = &size
+	}
+	baseSize := previousSize
+
+	for _, update := range updates {
+		entry := ChangelogDiffEntry{
+			Version:     update.Version,
+			ReleaseDate: update.ReleaseDate,
+			Mandatory:   update.IsMandatory,
+			Critical:    update.IsCritical,
+			FileSize:    update.FileSize,
+		}
+		if previousSize != nil {
+			delta := update.FileSize - *previousSize
+			entry.SizeDelta = &delta
+		}
+		size := update.FileSize
+		previousSize = &size
+
+		entry.Changelog, entry.ChangelogSource = versionChangelog(update)
+		if entry.ChangelogSource == "missing" {
+			response.Missing = append(response.Missing, update.Version)
+		}
+		response.Mandatory = response.Mandatory || update.IsMandatory
+		response.Versions = append(response.Versions, entry)
+	}
+
+	if baseSize != nil && len(updates) > 0 {
+		delta := updates[len(updates)-1].FileSize - *baseSize
+		response.SizeDelta = &delta
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+)
+
+// ChannelUsage 单个频道的下载统计
+type ChannelUsage struct {
+	Channel       string `json:"channel,omitempty"`
+	Files         int    `json:"files"`
+	Downloads     int64  `json:"downloads"`
+	BytesServed   int64  `json:"bytesServed"`
+	UniqueClients int    `json:"uniqueClients"`
+}
+
+// ChannelStatistics 按频道汇总的下载统计
+// 被多个频道引用的文件同时计入每个频道，因此各频道之和可能大于总量
+type ChannelStatistics struct {
+	Channels     []ChannelUsage `json:"channels"`
+	Unattributed ChannelUsage   `json:"unattributed"`
+}
+
+// clientKey 将客户端标识转换为不可逆的短哈希，避免在统计中保存IP或客户端ID
+func clientKey(client string) string {
+	sum := sha256.Sum256([]byte(client))
+	return hex.EncodeToString(sum[:8])
+}
+
+// recordFileTransfer 记录文件的传输字节数和下载客户端（包括中断和断点续传的传输）
+func recordFileTransfer(filename, client string, bytes int64) {
+	if bytes <= 0 {
+		return
+	}
+	statsStore.RecordTransfer(filename, clientKey(client), bytes)
+}
+
+// channelStatistics 将按文件的统计映射到引用该文件的频道
+func channelStatistics() ChannelStatistics {
+	refs := referencedFiles()
+	stats := statsStore.Snapshot()
+
+	usage := make(map[string]*ChannelUsage, len(Channels))
+	clients := make(map[string]clientSketch, len(Channels))
+	for _, channel := range Channels {
+		usage[channel] = &ChannelUsage{Channel: channel}
+		clients[channel] = newClientSketch()
+	}
+	unattributed := &ChannelUsage{}
+	unattributedClients := newClientSketch()
+
+	files := make(map[string]bool)
+	for name := range stats.FileDownloads {
+		files[name] = true
+	}
+	for name := range stats.FileBytes {
+		files[name] = true
+	}
+
+	for name := range files {
+		type target struct {
+			usage   *ChannelUsage
+			clients clientSketch
+		}
+		var targets []target
+		for channel := range refs[name] {
+			targets = append(targets, target{usage[channel], clients[channel]})
+		}
+		if len(targets) == 0 {
+			targets = append(targets, target{unattributed, unattributedClients})
+		}
+
+		for _, t := range targets {
+			t.usage.Files++
+			t.usage.Downloads += stats.FileDownloads[name]
+			t.usage.BytesServed += stats.FileBytes[name]
+			t.clients.merge(stats.FileClients[name])
+		}
+	}
+
+	result := ChannelStatistics{Channels: make([]ChannelUsage, 0, len(Channels))}
+	for _, channel := range Channels {
+		usage[channel].UniqueClients = clients[channel].estimate()
+		result.Channels = append(result.Channels, *usage[channel])
+	}
+	unattributed.UniqueClients = unattributedClients.estimate()
+	result.Unattributed = *unattributed
+	return result
+}
+
+// channelStatisticsHandler 按频道汇总的下载统计
+// GET /api/statistics/by-channel
+func channelStatisticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(channelStatistics())
+}
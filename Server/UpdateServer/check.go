@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// CheckResponse 高频轮询使用的精简更新检查结果
+type CheckResponse struct {
+	HasUpdate     bool   `json:"hasUpdate"`
+	LatestVersion string `json:"latestVersion"`
+	// TargetVersion 本次应安装的版本，通常等于 LatestVersion，过旧的客户端可能需要先升级到中间版本
+	TargetVersion string `json:"targetVersion,omitempty"`
+	Mandatory     bool   `json:"mandatory"`
+	Critical      bool   `json:"critical"`
+	// ForceRedownload 已是最新版本也需重新下载（文件原地替换）
+	ForceRedownload bool   `json:"forceRedownload,omitempty"`
+	DownloadUrl     string `json:"downloadUrl,omitempty"`
+	Hash            string `json:"hash,omitempty"`
+	// Artifacts 目标版本有依赖时，按下载顺序列出全部文件（含目标版本本身）
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+}
+
+// checkHandler 客户端轻量更新检查
+// ETag 由清单文件状态与查询参数决定，清单未变化时无需读取清单即可返回 304
+func checkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	channel := query.Get("channel")
+	if channel == "" {
+		channel = "stable"
+	}
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+	// current 与 version 等价，version 为旧参数名
+	version := query.Get("current")
+	if version == "" {
+		version = query.Get("version")
+	}
+	if version == "" {
+		http.Error(w, "Missing version", http.StatusBadRequest)
+		return
+	}
+	platform := query.Get("platform")
+	clientID := query.Get("clientId")
+
+	info, err := os.Stat(manifestPath(channel))
+	if err != nil {
+		http.Error(w, "Manifest not found", http.StatusNotFound)
+		return
+	}
+
+	etag := checkETag(channel, info, version, platform, clientID)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	manifest, err := loadPublicManifest(channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		log.Printf("Error reading manifest for check %s: %v", channel, err)
+		return
+	}
+
+	decision := evaluateUpdate(manifest, version, platform, clientID)
+	var artifacts []Artifact
+	if target := findUpdate(manifest, decision.TargetVersion); decision.HasUpdate && target != nil && len(target.Dependencies) > 0 {
+		if artifacts, err = resolveDependencies(manifest, target, platform); err != nil {
+			writeDependencyError(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CheckResponse{
+		HasUpdate:       decision.HasUpdate,
+		LatestVersion:   decision.LatestVersion,
+		TargetVersion:   decision.TargetVersion,
+		Mandatory:       decision.Mandatory,
+		Critical:        decision.Critical,
+		ForceRedownload: decision.ForceRedownload,
+		DownloadUrl:     decision.DownloadUrl,
+		Hash:            decision.FileHash,
+		Artifacts:       artifacts,
+	})
+}
+
+// checkETag 根据清单修改时间、大小和查询参数计算 ETag
+func checkETag(channel string, info os.FileInfo, version, platform, clientID string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%d\x00%s\x00%s\x00%s",
+		channel, info.ModTime().UnixNano(), info.Size(), version, platform, clientID)))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ChecksumsFilename 校验和文件名，兼容 sha256sum -c
+const ChecksumsFilename = "SHA256SUMS"
+
+var (
+	// checksumsCache 按频道缓存生成的校验和文件，空字符串表示全部文件
+	checksumsCache = make(map[string][]byte)
+	checksumsMu    sync.Mutex
+)
+
+// invalidateChecksums 清空校验和缓存（上传、删除、清单更新时调用）
+func invalidateChecksums() {
+	checksumsMu.Lock()
+	checksumsCache = make(map[string][]byte)
+	checksumsMu.Unlock()
+}
+
+// checksumsHandler 提供 SHA256SUMS 校验和文件
+// GET /downloads/SHA256SUMS              所有可下载文件
+// GET /downloads/SHA256SUMS?channel=beta 指定频道清单引用的文件
+func checksumsHandler(w http.ResponseWriter, r *http.Request) {
+	channel := r.URL.Query().Get("channel")
+	if channel != "" && !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	checksumsMu.Lock()
+	data, ok := checksumsCache[channel]
+	checksumsMu.Unlock()
+
+	if !ok {
+		var err error
+		data, err = buildChecksums(channel)
+		if err != nil {
+			http.Error(w, "Failed to generate checksums", http.StatusInternalServerError)
+			log.Printf("Error generating checksums: %v", err)
+			return
+		}
+
+		checksumsMu.Lock()
+		checksumsCache[channel] = data
+		checksumsMu.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(data)
+}
+
+// buildChecksums 生成 sha256sum 格式的校验和内容
+func buildChecksums(channel string) ([]byte, error) {
+	var names []string
+
+	if channel == "" {
+		files, err := os.ReadDir(DownloadsDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if file.IsDir() || file.Name() == ChecksumsFilename {
+				continue
+			}
+			names = append(names, file.Name())
+		}
+	} else {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, update := range manifest.Updates {
+			for _, name := range releaseFiles(update) {
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		hash, err := cachedFileHash(filepath.Join(DownloadsDir, name))
+		if err != nil {
+			// 清单引用但尚未上传的文件直接跳过
+			continue
+		}
+		fmt.Fprintf(&buf, "%s  %s\n", hash, name)
+	}
+
+	return buf.Bytes(), nil
+}
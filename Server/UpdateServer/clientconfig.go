@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ClientConfigFormatVersion 客户端配置包格式版本，结构不兼容变更时递增
+const ClientConfigFormatVersion = 1
+
+// ClientConfigBundle 客户端首次启动时获取的引导配置
+type ClientConfigBundle struct {
+	FormatVersion    int              `json:"formatVersion"`
+	BaseUrl          string           `json:"baseUrl"`
+	Channels         []ChannelSummary `json:"channels"`
+	SigningPublicKey string           `json:"signingPublicKey"`
+	FeatureFlags     map[string]bool  `json:"featureFlags"`
+}
+
+// ChannelSummary 频道概要
+type ChannelSummary struct {
+	Name          string `json:"name"`
+	LatestVersion string `json:"latestVersion"`
+	ManifestUrl   string `json:"manifestUrl"`
+}
+
+// clientConfigHandler 返回客户端引导配置（公开端点）
+func clientConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	base := serverBaseURL(r)
+	bundle := ClientConfigBundle{
+		FormatVersion:    ClientConfigFormatVersion,
+		BaseUrl:          base,
+		Channels:         make([]ChannelSummary, 0, len(Channels)),
+		SigningPublicKey: currentPublicKey(),
+		FeatureFlags:     config.FeatureFlags,
+	}
+	if bundle.FeatureFlags == nil {
+		bundle.FeatureFlags = map[string]bool{}
+	}
+
+	for _, channel := range Channels {
+		summary := ChannelSummary{
+			Name:        channel,
+			ManifestUrl: base + "/manifest-" + channel + ".json",
+		}
+		if manifest, err := loadManifest(channel); err == nil {
+			if release := currentRelease(manifest); release != nil {
+				summary.LatestVersion = release.Version
+			}
+		}
+		bundle.Channels = append(bundle.Channels, summary)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bundle)
+}
+
+// requestBaseURL 根据请求推断服务器对外地址；X-Forwarded-* 头可由客户端伪造，只在开启 trustForwardedHeaders 时采用
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	if config.TrustForwardedHeaders {
+		if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+			scheme = proto
+		}
+		if fwd := r.Header.Get("X-Forwarded-Host"); fwd != "" {
+			host = fwd
+		}
+	}
+	return scheme + "://" + host
+}
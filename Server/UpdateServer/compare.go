@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// CompareResult 两个文件的哈希比较结果
+type CompareResult struct {
+	FileA string `json:"fileA"`
+	FileB string `json:"fileB"`
+	HashA string `json:"hashA"`
+	HashB string `json:"hashB"`
+	SizeA int64  `json:"sizeA"`
+	SizeB int64  `json:"sizeB"`
+	Match bool   `json:"match"`
+}
+
+// compareHandler 比较下载目录中两个文件的SHA256
+// POST /api/compare {"fileA": "...", "fileB": "..."}
+func compareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		FileA string `json:"fileA"`
+		FileB string `json:"fileB"`
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !filepath.IsLocal(req.FileA) || !filepath.IsLocal(req.FileB) {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+
+	result := CompareResult{FileA: req.FileA, FileB: req.FileB}
+	for _, file := range []struct {
+		name string
+		hash *string
+		size *int64
+	}{
+		{req.FileA, &result.HashA, &result.SizeA},
+		{req.FileB, &result.HashB, &result.SizeB},
+	} {
+		filePath := filepath.Join(DownloadsDir, file.name)
+		info, err := os.Stat(filePath)
+		if err != nil || info.IsDir() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"error": "File not found", "missing": file.name})
+			return
+		}
+
+		hash, err := cachedFileHash(filePath)
+		if err != nil {
+			http.Error(w, "Failed to calculate hash", http.StatusInternalServerError)
+			return
+		}
+		*file.hash = hash
+		*file.size = info.Size()
+	}
+	result.Match = result.HashA == result.HashB
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ConfigFile 服务器配置文件路径（可选）
+const ConfigFile = "./config.json"
+
+// ServerConfig 服务器配置，未配置的字段使用默认值
+type ServerConfig struct {
+	// LogExtendedFields 访问日志附加记录客户端版本
+	LogExtendedFields bool `json:"logExtendedFields"`
+	// LogFile 日志同时写入的文件，/api/logs/trace 从中检索请求ID
+	LogFile string `json:"logFile"`
+
+	// BaseURL 服务器对外地址，如 https://updates.example.com，用于生成下载、更新日志等绝对地址；启动参数 -base-url 与环境变量 BASE_URL 优先
+	BaseURL string `json:"baseUrl"`
+	// RewriteManifestUrls 未配置 BaseURL 时，按请求的 Host/X-Forwarded-* 改写公开清单中指向本服务器的地址
+	RewriteManifestUrls bool `json:"rewriteManifestUrls"`
+	// TrustForwardedHeaders 推断当次响应中的地址时采用 X-Forwarded-Proto/X-Forwarded-Host，仅在会覆盖这些头的反向代理后启用
+	TrustForwardedHeaders bool `json:"trustForwardedHeaders"`
+
+	// ContentTypes 下载文件扩展名到内容类型的映射，未列出的扩展名回退到 mime.TypeByExtension
+	ContentTypes map[string]ContentTypeRule `json:"contentTypes"`
+
+	// SigningKeyGracePeriod 签名密钥轮换后旧公钥继续公布的时长
+	SigningKeyGracePeriod Duration `json:"signingKeyGracePeriod"`
+	// FeatureFlags 下发给客户端的功能开关
+	FeatureFlags map[string]bool `json:"featureFlags"`
+
+	// LegacyListFormat 列表接口返回裸数组而不是分页信封（兼容旧客户端）
+	LegacyListFormat bool `json:"legacyListFormat"`
+
+	// MaxUploadSize 单个上传文件的最大字节数，0 表示不限制
+	MaxUploadSize int64 `json:"maxUploadSize"`
+	// StorageQuota 下载目录的存储配额（字节），0 表示不限制
+	StorageQuota int64 `json:"storageQuota"`
+	// StrictContentType 校验请求体的 Content-Type（JSON接口要求 application/json，上传要求 multipart/form-data），不匹配返回 415
+	StrictContentType bool `json:"strictContentType"`
+	// PatchMaxFileSize 生成差分补丁时新旧文件的大小上限（字节），超出时只提供完整下载，0 表示不限制
+	PatchMaxFileSize int64 `json:"patchMaxFileSize"`
+
+	// StatsBackend 统计数据存储：json 或 sqlite；留空时优先使用 SQLite（需 cgo 构建），否则使用 stats.json
+	StatsBackend string `json:"statsBackend"`
+
+	// UploadSessionTTL 分块上传会话空闲多久后连同已上传的数据一起清理，默认24小时
+	UploadSessionTTL Duration `json:"uploadSessionTtl"`
+
+	// UploadScan 上传文件扫描钩子，未配置时不扫描
+	UploadScan UploadScanConfig `json:"uploadScan"`
+	// ManifestWebhook 清单提交后的通知，通知失败时清单回滚
+	ManifestWebhook ManifestWebhookConfig `json:"manifestWebhook"`
+	// UploadStaging 新上传的文件先暂存，手动发布或延迟到期后才可下载
+	UploadStaging UploadStagingConfig `json:"uploadStaging"`
+	// UploadExtensions 允许上传的扩展名（含点，不区分大小写），"*" 表示不限制；其他扩展名和隐藏文件返回 400
+	UploadExtensions []string `json:"uploadExtensions"`
+	// UploadSniff 按文件头嗅探上传文件的实际类型，与扩展名的白名单不符时返回 422
+	UploadSniff UploadSniffConfig `json:"uploadSniff"`
+
+	// Jobs 后台维护任务（重新计算哈希、完整性扫描）的并发与限速
+	Jobs JobsConfig `json:"jobs"`
+
+	// ReleaseCadence 按频道配置的最小发布间隔，过早的发布返回 429（?override=true 可强制发布）
+	ReleaseCadence map[string]Duration `json:"releaseCadence"`
+
+	// Retention 按频道配置的版本保留策略，未配置的频道不自动清理
+	Retention map[string]RetentionPolicy `json:"retention"`
+
+	// DownloadLinkHeaders 下载响应附加 Link 头，指向校验和文件与更新日志
+	DownloadLinkHeaders bool `json:"downloadLinkHeaders"`
+
+	// MaxConnectionsPerClient 单个客户端IP的并发下载连接上限，0 表示不限制
+	MaxConnectionsPerClient int `json:"maxConnectionsPerClient"`
+
+	// DownloadCooldown 同一客户端完整下载同一文件后的冷却时间，0 表示不限制
+	DownloadCooldown Duration `json:"downloadCooldown"`
+
+	// RateLimit 按客户端IP的请求限流，公开端点与管理API分别计算，超出返回 429
+	RateLimit RateLimitConfig `json:"rateLimit"`
+	// CORS 跨域访问：公开端点默认允许任意来源，管理API只允许列出的来源
+	CORS CORSConfig `json:"cors"`
+
+	// TLS HTTPS 证书与双向TLS客户端CA，未配置时以 HTTP 提供服务
+	TLS TLSConfig `json:"tls"`
+	// ShutdownTimeout 收到 SIGINT/SIGTERM 后等待进行中请求（包括下载）完成的最长时间
+	ShutdownTimeout Duration `json:"shutdownTimeout"`
+
+	// SessionSecrets 面板会话Cookie的HMAC密钥，第一个用于签发，其余仍可校验（轮换时保留旧密钥）
+	SessionSecrets []string `json:"sessionSecrets"`
+	// SessionTTL 面板会话有效期，默认12小时
+	SessionTTL Duration `json:"sessionTtl"`
+	// TokenTTL /api/login 签发的JWT有效期，默认15分钟；签名密钥来自环境变量 LIZARD_JWT_SECRET
+	TokenTTL Duration `json:"tokenTtl"`
+
+	// DownloadURLSecrets 签名下载链接的HMAC密钥，第一个用于签发，其余仍可校验
+	DownloadURLSecrets []string `json:"downloadUrlSecrets"`
+	// RequireSignedDownloads 下载文件必须使用 /api/sign 签发的链接，未签名的请求返回 403
+	RequireSignedDownloads bool `json:"requireSignedDownloads"`
+
+	// WebAuthn 管理面板通行密钥登录，未配置时面板使用基础认证
+	WebAuthn WebAuthnConfig `json:"webauthn"`
+
+	// ManifestHistory 清单历史归档的保留数量与时长
+	ManifestHistory ManifestHistoryConfig `json:"manifestHistory"`
+
+	// ActivityArchive 活动日志归档文件的轮转大小
+	ActivityArchive ActivityArchiveConfig `json:"activityArchive"`
+
+	// ActivityCompaction 活动日志归档的压缩阈值与周期
+	ActivityCompaction ActivityCompactionConfig `json:"activityCompaction"`
+
+	// IntegrityCheck 定期重新计算清单引用文件的哈希并与清单核对
+	IntegrityCheck IntegrityCheckConfig `json:"integrityCheck"`
+
+	// ModSuggestionLimit 未知模组 404 响应中返回的相似模组ID数量上限，0 表示不返回
+	ModSuggestionLimit int `json:"modSuggestionLimit"`
+
+	// Gzip 对接受 gzip 的客户端压缩文本响应（清单、更新日志、模组信息、统计等），下载文件除外
+	Gzip GzipConfig `json:"gzip"`
+
+	// CacheControl 路由前缀（以 "/" 开头）或内容类型到 Cache-Control 的映射，未匹配时为 no-cache
+	CacheControl map[string]string `json:"cacheControl"`
+	// ImmutableCacheControl 文件名带版本号或内容哈希的下载文件的 Cache-Control，空字符串表示与其他下载文件相同
+	ImmutableCacheControl string `json:"immutableCacheControl"`
+}
+
+// RetentionPolicy 频道版本保留策略
+type RetentionPolicy struct {
+	// MaxRetainedVersions 清单中最多保留的版本数，0 表示不限制
+	MaxRetainedVersions int `json:"maxRetainedVersions"`
+	// DeleteFiles 清理版本时同时删除不再被任何清单引用的文件
+	DeleteFiles bool `json:"deleteFiles"`
+}
+
+// ContentTypeRule 下载文件的内容类型与展示方式
+type ContentTypeRule struct {
+	ContentType string `json:"contentType"`
+	// Disposition 为 "attachment"（下载）或 "inline"（浏览器内显示）
+	Disposition string `json:"disposition"`
+}
+
+var config = defaultConfig()
+
+// defaultConfig 返回默认配置
+func defaultConfig() ServerConfig {
+	return ServerConfig{
+		LogExtendedFields:     false,
+		SigningKeyGracePeriod: Duration{30 * 24 * time.Hour},
+		ShutdownTimeout:       Duration{DefaultShutdownTimeout},
+		Jobs: JobsConfig{
+			Workers:     1,
+			IORateLimit: 32 << 20,
+		},
+		ModSuggestionLimit: DefaultModSuggestionLimit,
+		StrictContentType:  true,
+		UploadExtensions:   defaultUploadExtensions(),
+		UploadSniff:        UploadSniffConfig{AllowedTypes: defaultSniffAllowedTypes()},
+		PatchMaxFileSize:   DefaultPatchMaxFileSize,
+		Gzip:               GzipConfig{Enabled: true, MinSize: DefaultGzipMinSize},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Public:  RateLimitRule{Rate: 10, Burst: 50},
+			Admin:   RateLimitRule{Rate: 50, Burst: 200},
+		},
+		ManifestHistory: ManifestHistoryConfig{Keep: DefaultManifestHistoryKeep},
+		ActivityArchive: ActivityArchiveConfig{RotateSize: DefaultActivityRotateSize},
+		ActivityCompaction: ActivityCompactionConfig{
+			MinAge:   Duration{7 * 24 * time.Hour},
+			Interval: Duration{24 * time.Hour},
+		},
+		IntegrityCheck: IntegrityCheckConfig{Interval: Duration{24 * time.Hour}},
+		CORS:           CORSConfig{PublicAnyOrigin: true, MaxAge: Duration{10 * time.Minute}},
+		ContentTypes: map[string]ContentTypeRule{
+			".zip": {ContentType: "application/zip", Disposition: "attachment"},
+			".jar": {ContentType: "application/java-archive", Disposition: "attachment"},
+			".exe": {ContentType: "application/vnd.microsoft.portable-executable", Disposition: "attachment"},
+			".dmg": {ContentType: "application/x-apple-diskimage", Disposition: "attachment"},
+			".md":  {ContentType: "text/markdown; charset=utf-8", Disposition: "inline"},
+		},
+		ImmutableCacheControl: DefaultImmutableCacheControl,
+		CacheControl: map[string]string{
+			"/manifest-":                      "public, max-age=60",
+			"/latest-":                        "public, max-age=60",
+			"/downloads/":                     "public, max-age=300, must-revalidate",
+			"/downloads/" + ChecksumsFilename: "public, max-age=60",
+			"/changelog/":                     "public, max-age=300",
+			"/api/check":                      "public, max-age=60",
+			"/api/limits":                     "public, max-age=60",
+			"/api/latest-multi":               "public, max-age=60",
+			"/api/resolve":                    "public, max-age=60",
+		},
+	}
+}
+
+// loadConfig 加载服务器配置
+func loadConfig() {
+	data, err := os.ReadFile(ConfigFile)
+	if err != nil {
+		log.Printf("No config file found, using defaults")
+		return
+	}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		log.Printf("Error loading config: %v", err)
+	}
+}
+
+// Duration 支持 "720h" 形式字符串的JSON时长
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON 解析时长字符串
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// MarshalJSON 输出时长字符串
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// contentTypeFor 返回下载文件的内容类型规则
+func contentTypeFor(filename string) ContentTypeRule {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if rule, ok := config.ContentTypes[ext]; ok && rule.ContentType != "" {
+		if rule.Disposition == "" {
+			rule.Disposition = "attachment"
+		}
+		return rule
+	}
+
+	contentType := mime.TypeByExtension(ext)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return ContentTypeRule{ContentType: contentType, Disposition: "attachment"}
+}
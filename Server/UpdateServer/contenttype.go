@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// requireContentType 校验请求体的 Content-Type，媒体类型不匹配时返回 415
+// 只比较媒体类型，允许 charset、boundary 等参数；配置 strictContentType 为 false 时不校验
+// 返回 false 表示已写入错误响应
+func requireContentType(w http.ResponseWriter, r *http.Request, expected string) bool {
+	if !config.StrictContentType {
+		return true
+	}
+
+	header := r.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err == nil && mediaType == expected {
+		return true
+	}
+
+	message := fmt.Sprintf("Content-Type must be %s", expected)
+	if header != "" {
+		message = fmt.Sprintf("Content-Type must be %s, got %q", expected, header)
+	}
+	writeJSONError(w, http.StatusUnsupportedMediaType, message)
+	return false
+}
+
+// requireJSON 要求请求体为 application/json
+func requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	return requireContentType(w, r, "application/json")
+}
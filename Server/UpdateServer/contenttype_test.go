@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRequireJSON(t *testing.T) {
+	old := config
+	t.Cleanup(func() { config = old })
+
+	tests := []struct {
+		name        string
+		contentType string
+		strict      bool
+		want        bool
+	}{
+		{"json", "application/json", true, true},
+		{"json with charset", "application/json; charset=utf-8", true, true},
+		{"json uppercase", "Application/JSON", true, true},
+		{"missing", "", true, false},
+		{"text", "text/plain", true, false},
+		{"form", "application/x-www-form-urlencoded", true, false},
+		{"json prefix", "application/jsonp", true, false},
+		{"malformed", "application/json; charset", true, false},
+		{"missing not strict", "", false, true},
+		{"text not strict", "text/plain", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config = defaultConfig()
+			config.StrictContentType = tt.strict
+
+			req := httptest.NewRequest(http.MethodPost, "/api/hash", strings.NewReader("{}"))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+
+			if got := requireJSON(rec, req); got != tt.want {
+				t.Fatalf("requireJSON = %v, want %v", got, tt.want)
+			}
+			if tt.want {
+				return
+			}
+			if rec.Code != http.StatusUnsupportedMediaType {
+				t.Errorf("status = %d, want 415", rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+				t.Errorf("error Content-Type = %q, want JSON", ct)
+			}
+		})
+	}
+}
+
+func TestLoginContentType(t *testing.T) {
+	old := config
+	t.Cleanup(func() { config = old })
+	config = defaultConfig()
+
+	form := url.Values{"username": {AdminUsername}, "password": {"wrong"}}.Encode()
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"missing", "", `{"username":"x","password":"y"}`, http.StatusUnsupportedMediaType},
+		{"text", "text/plain", `{"username":"x","password":"y"}`, http.StatusUnsupportedMediaType},
+		{"json wrong password", "application/json; charset=utf-8", `{"username":"x","password":"y"}`, http.StatusUnauthorized},
+		{"json invalid", "application/json", `{`, http.StatusBadRequest},
+		{"form wrong password", "application/x-www-form-urlencoded", form, http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/admin/login", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			loginHandler(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+}
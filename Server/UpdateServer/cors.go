@@ -0,0 +1,79 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// corsAllowMethods 预检响应允许的方法
+	corsAllowMethods = "GET, HEAD, POST, PUT, PATCH, DELETE, OPTIONS"
+	// corsAllowHeaders 预检响应允许的请求头
+	corsAllowHeaders = "Authorization, Content-Type, If-None-Match, If-Range, Range, Upload-Offset, X-Client-Id, X-Download-Session, X-Request-Id"
+	// corsExposeHeaders 跨域脚本可读取的响应头
+	corsExposeHeaders = "ETag, Retry-After, X-Request-Id, X-Total-Count, X-File-Hash, Content-Range, Content-Disposition, Upload-Offset"
+)
+
+// CORSConfig 跨域访问配置
+type CORSConfig struct {
+	// AllowedOrigins 允许跨域调用全部接口（含管理API）的来源，如 "https://dashboard.example.com"；"*" 表示任意来源
+	AllowedOrigins []string `json:"allowedOrigins"`
+	// PublicAnyOrigin 清单、下载、轻量检查等公开端点允许任意来源
+	PublicAnyOrigin bool `json:"publicAnyOrigin"`
+	// MaxAge 浏览器缓存预检结果的时长
+	MaxAge Duration `json:"maxAge"`
+}
+
+// corsOriginAllowed 来源是否可以跨域访问该路由
+func corsOriginAllowed(origin, path string) bool {
+	if config.CORS.PublicAnyOrigin && !isAdminRoute(path) {
+		return true
+	}
+	return slices.ContainsFunc(config.CORS.AllowedOrigins, func(allowed string) bool {
+		allowed = strings.TrimSuffix(allowed, "/")
+		return allowed == "*" || strings.EqualFold(allowed, origin)
+	})
+}
+
+// corsMiddleware 为允许的来源添加 CORS 响应头并直接响应 OPTIONS 预检请求；
+// 不允许携带凭据（Cookie），跨域调用管理API需使用 Authorization 头
+func corsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		header.Add("Vary", "Origin")
+		allowed := corsOriginAllowed(origin, r.URL.Path)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if preflight {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			if !allowed {
+				http.Error(w, "Origin not allowed", http.StatusForbidden)
+				return
+			}
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Allow-Methods", corsAllowMethods)
+			header.Set("Access-Control-Allow-Headers", corsAllowHeaders)
+			if maxAge := config.CORS.MaxAge.Duration; maxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge/time.Second)))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed {
+			header.Set("Access-Control-Allow-Origin", origin)
+			header.Set("Access-Control-Expose-Headers", corsExposeHeaders)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
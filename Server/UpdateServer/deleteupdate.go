@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// deleteUpdateHandler 从频道清单中删除单个版本条目，不删除下载文件；
+// 当前的最新版本不能删除，需先发布新版本或回滚
+// DELETE /api/manifests/{channel}/updates/{version}
+func deleteUpdateHandler(w http.ResponseWriter, r *http.Request, channel, version string) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		log.Printf("Error reading manifest: %v", err)
+		return
+	}
+
+	if findUpdate(manifest, version) == nil {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	if manifest.LatestVersion == version {
+		http.Error(w, "Cannot delete the latest version", http.StatusConflict)
+		return
+	}
+
+	manifest.Updates = slices.DeleteFunc(manifest.Updates, func(u UpdateInfo) bool {
+		return u.Version == version
+	})
+	manifest.LastUpdated = time.Now()
+
+	err = saveManifest(channel, manifest)
+	if errors.Is(err, errInvalidManifest) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save manifest", http.StatusInternalServerError)
+		log.Printf("Error saving manifest: %v", err)
+		return
+	}
+
+	deletedBy := "unknown"
+	if user, ok := currentUser(r); ok {
+		deletedBy = user.Username
+	}
+	addActivity("manifest", fmt.Sprintf("Deleted %s from %s (by %s)", version, channel, deletedBy))
+	log.Printf("Version deleted: %s/%s by %s", channel, version, deletedBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":        "success",
+		"channel":       channel,
+		"version":       version,
+		"latestVersion": manifest.LatestVersion,
+	})
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Diagnostics 运行诊断信息
+type Diagnostics struct {
+	ActivityCompaction ActivityCompactionStatus `json:"activityCompaction"`
+}
+
+// diagnosticsHandler 返回运行诊断信息
+func diagnosticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Diagnostics{
+		ActivityCompaction: activityCompactionStatus(),
+	})
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package main
+
+import "errors"
+
+// diskFreeBytes 当前平台不支持查询磁盘剩余空间
+func diskFreeBytes(dir string) (int64, error) {
+	return 0, errors.New("disk free space not supported on this platform")
+}
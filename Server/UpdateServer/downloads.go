@@ -0,0 +1,439 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// downloadSessionTTL 同一下载会话内重复完成同一文件不再计数的时长
+	downloadSessionTTL = 24 * time.Hour
+	// adminBasicAuthTTL 通过基础认证的凭据在多长时间内可识别管理员下载
+	adminBasicAuthTTL = 10 * time.Minute
+	// FileHashHeader HEAD 下载请求返回的文件SHA256
+	FileHashHeader = "X-File-Hash"
+	// MaxDownloadKeys 计数键与送达区间各自最多保存的键数，键由客户端的会话标识和IP决定，超出时淘汰最早的记录
+	MaxDownloadKeys = 100000
+	// MaxDeliveryRanges 单个计数键最多保存的不相邻区间数，超出时放弃该键已累计的区间
+	MaxDeliveryRanges = 32
+	// MaxDownloadSessionLength 下载会话标识的最大长度，更长的标识按未提供处理
+	MaxDownloadSessionLength = 128
+)
+
+var (
+	// countedSessions 已计数的下载键（会话+文件 或 客户端+日期+文件）及计数时间
+	countedSessions   = make(map[string]time.Time)
+	countedSessionsMu sync.Mutex
+)
+
+// downloadDelivery 同一计数键下已送达的文件区间；断点续传的各次传输合计覆盖整个文件时计为一次下载
+type downloadDelivery struct {
+	// etag 文件版本，文件被替换后重新累计
+	etag string
+	// ranges 已送达的字节区间 [start, end)，按起点排序且互不相邻
+	ranges    [][2]int64
+	updatedAt time.Time
+}
+
+var (
+	downloadDeliveries   = make(map[string]*downloadDelivery)
+	downloadDeliveriesMu sync.Mutex
+)
+
+var (
+	// adminBasicAuth 最近通过管理API基础认证的凭据摘要及通过时间；只用于判断下载是否计数，不用于授权
+	adminBasicAuth   = make(map[[sha256.Size]byte]time.Time)
+	adminBasicAuthMu sync.Mutex
+)
+
+var (
+	// completedDownloads 客户端+文件 最近一次完整下载的时间，用于下载冷却
+	completedDownloads   = make(map[string]time.Time)
+	completedDownloadsMu sync.Mutex
+)
+
+var (
+	// clientConnections 每个客户端IP正在进行的下载连接数
+	clientConnections   = make(map[string]int)
+	clientConnectionsMu sync.Mutex
+)
+
+// acquireDownloadSlot 占用客户端的一个下载连接名额，超过上限时返回false
+// 成功时必须调用 releaseDownloadSlot 释放
+func acquireDownloadSlot(client string) bool {
+	limit := config.MaxConnectionsPerClient
+	if limit <= 0 {
+		return true
+	}
+
+	clientConnectionsMu.Lock()
+	defer clientConnectionsMu.Unlock()
+
+	if clientConnections[client] >= limit {
+		return false
+	}
+	clientConnections[client]++
+	return true
+}
+
+// releaseDownloadSlot 释放客户端的下载连接名额
+func releaseDownloadSlot(client string) {
+	if config.MaxConnectionsPerClient <= 0 {
+		return
+	}
+
+	clientConnectionsMu.Lock()
+	defer clientConnectionsMu.Unlock()
+
+	if clientConnections[client] <= 1 {
+		delete(clientConnections, client)
+		return
+	}
+	clientConnections[client]--
+}
+
+// downloadCooldownRemaining 返回客户端再次完整下载该文件前需等待的时长，未启用冷却时为0
+func downloadCooldownRemaining(client, filename string) time.Duration {
+	window := config.DownloadCooldown.Duration
+	if window <= 0 {
+		return 0
+	}
+
+	completedDownloadsMu.Lock()
+	defer completedDownloadsMu.Unlock()
+
+	completedAt, ok := completedDownloads[client+"\x00"+filename]
+	if !ok {
+		return 0
+	}
+	return window - time.Since(completedAt)
+}
+
+// markDownloadCompleted 记录客户端完整下载了该文件，过期记录由 pruneDownloadKeys 定期清理
+func markDownloadCompleted(client, filename string) {
+	if config.DownloadCooldown.Duration <= 0 {
+		return
+	}
+
+	completedDownloadsMu.Lock()
+	completedDownloads[client+"\x00"+filename] = time.Now()
+	completedDownloadsMu.Unlock()
+}
+
+// pruneDownloadKeys 清理已过冷却期的完整下载记录、过期的计数键、送达区间和基础认证摘要，
+// 由调度循环定期调用，下载请求本身只做单键查找
+func pruneDownloadKeys(now time.Time) {
+	window := config.DownloadCooldown.Duration
+	completedDownloadsMu.Lock()
+	for k, completedAt := range completedDownloads {
+		if now.Sub(completedAt) > window {
+			delete(completedDownloads, k)
+		}
+	}
+	completedDownloadsMu.Unlock()
+
+	countedSessionsMu.Lock()
+	for k, countedAt := range countedSessions {
+		if now.Sub(countedAt) > downloadSessionTTL {
+			delete(countedSessions, k)
+		}
+	}
+	countedSessionsMu.Unlock()
+
+	adminBasicAuthMu.Lock()
+	for k, verifiedAt := range adminBasicAuth {
+		if now.Sub(verifiedAt) > adminBasicAuthTTL {
+			delete(adminBasicAuth, k)
+		}
+	}
+	adminBasicAuthMu.Unlock()
+
+	downloadDeliveriesMu.Lock()
+	for k, delivery := range downloadDeliveries {
+		if now.Sub(delivery.updatedAt) > downloadSessionTTL {
+			delete(downloadDeliveries, k)
+		}
+	}
+	downloadDeliveriesMu.Unlock()
+}
+
+// addByteRange 将区间 [start, end) 并入有序区间列表，重叠或相邻的区间合并
+func addByteRange(ranges [][2]int64, start, end int64) [][2]int64 {
+	merged := make([][2]int64, 0, len(ranges)+1)
+	i := 0
+	for ; i < len(ranges) && ranges[i][1] < start; i++ {
+		merged = append(merged, ranges[i])
+	}
+	for ; i < len(ranges) && ranges[i][0] <= end; i++ {
+		start = min(start, ranges[i][0])
+		end = max(end, ranges[i][1])
+	}
+	merged = append(merged, [2]int64{start, end})
+	return append(merged, ranges[i:]...)
+}
+
+// recordDelivery 记录计数键下送达的区间 [start, start+n)，已送达的区间覆盖整个文件时返回 true 并清除记录
+func recordDelivery(key, etag string, size, start, n int64, now time.Time) bool {
+	if n <= 0 {
+		return false
+	}
+
+	downloadDeliveriesMu.Lock()
+	defer downloadDeliveriesMu.Unlock()
+
+	delivery := downloadDeliveries[key]
+	if delivery == nil && len(downloadDeliveries) >= MaxDownloadKeys {
+		evictOldestDeliveryLocked()
+	}
+	if delivery == nil || delivery.etag != etag || now.Sub(delivery.updatedAt) > downloadSessionTTL {
+		delivery = &downloadDelivery{etag: etag}
+		downloadDeliveries[key] = delivery
+	}
+	delivery.ranges = addByteRange(delivery.ranges, start, start+n)
+	delivery.updatedAt = now
+
+	// 大量零散的区间请求不再累计，避免单个键占用无限的内存
+	if len(delivery.ranges) > MaxDeliveryRanges {
+		delete(downloadDeliveries, key)
+		return false
+	}
+
+	if len(delivery.ranges) == 1 && delivery.ranges[0] == [2]int64{0, size} {
+		delete(downloadDeliveries, key)
+		return true
+	}
+	return false
+}
+
+// evictOldestDeliveryLocked 移除最久未更新的送达区间记录，调用方需持有 downloadDeliveriesMu
+func evictOldestDeliveryLocked() {
+	var oldestKey string
+	var oldest time.Time
+	for k, delivery := range downloadDeliveries {
+		if oldestKey == "" || delivery.updatedAt.Before(oldest) {
+			oldestKey = k
+			oldest = delivery.updatedAt
+		}
+	}
+	delete(downloadDeliveries, oldestKey)
+}
+
+// deliveryCompletesFile 记录一次 200/206 传输实际送出的区间，返回该计数键下是否已累计送达整个文件；
+// 多区间请求无法确定送出的位置，不参与累计
+func deliveryCompletesFile(key, etag, rangeHeader string, status int, size, written int64) bool {
+	if size == 0 {
+		return status == http.StatusOK
+	}
+	var start int64
+	switch status {
+	case http.StatusOK:
+	case http.StatusPartialContent:
+		var ok bool
+		if start, _, ok = parseSingleRange(rangeHeader, size); !ok {
+			return false
+		}
+	default:
+		return false
+	}
+	return recordDelivery(key, etag, size, start, written, time.Now())
+}
+
+// downloadSession 返回客户端提供的下载会话标识（X-Download-Session 头或 session 参数），超过长度上限时视为未提供
+func downloadSession(r *http.Request) string {
+	session := r.Header.Get("X-Download-Session")
+	if session == "" {
+		session = r.URL.Query().Get("session")
+	}
+	if len(session) > MaxDownloadSessionLength {
+		return ""
+	}
+	return session
+}
+
+// downloadCountKey 下载计数去重的键：有会话标识时同一会话对同一文件只计一次，
+// 否则同一客户端IP每个UTC日对同一文件只计一次
+func downloadCountKey(r *http.Request, filename string) string {
+	if session := downloadSession(r); session != "" {
+		return "session\x00" + session + "\x00" + filename
+	}
+	return "client\x00" + clientIP(r) + "\x00" + time.Now().UTC().Format(time.DateOnly) + "\x00" + filename
+}
+
+// basicAuthDigest 基础认证凭据的摘要，缓存中不保存明文密码
+func basicAuthDigest(username, password string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(username + "\x00" + password))
+}
+
+// rememberAdminBasicAuth 记录刚通过基础认证的凭据，之后携带相同凭据的下载按管理员下载处理
+func rememberAdminBasicAuth(username, password string) {
+	adminBasicAuthMu.Lock()
+	adminBasicAuth[basicAuthDigest(username, password)] = time.Now()
+	adminBasicAuthMu.Unlock()
+}
+
+// isAdminDownload 请求是否来自已登录的管理员（面板会话、JWT 或最近通过管理API认证的基础认证凭据），管理员的下载不计入统计；
+// 公开下载不做 bcrypt 校验，基础认证只与缓存的摘要比对，避免未认证的请求消耗CPU
+func isAdminDownload(r *http.Request) bool {
+	if validSession(r) {
+		return true
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		_, err := parseToken(strings.TrimSpace(token), time.Now())
+		return err == nil
+	}
+	if username, password, ok := r.BasicAuth(); ok {
+		adminBasicAuthMu.Lock()
+		verifiedAt, known := adminBasicAuth[basicAuthDigest(username, password)]
+		adminBasicAuthMu.Unlock()
+		return known && time.Since(verifiedAt) <= adminBasicAuthTTL
+	}
+	return false
+}
+
+// recordDownload 记录一次完成的下载，键相同的下载在 downloadSessionTTL 内只计一次
+func recordDownload(filename, key string) {
+	if !claimDownloadKey(key) {
+		return
+	}
+	attribution := attributeDownload(filename)
+	statsStore.RecordDownload(filename, attribution)
+	observeDownload(filename, attribution)
+	addActivity("download", fmt.Sprintf("Downloaded: %s", filename))
+}
+
+// claimDownloadKey 登记下载键，downloadSessionTTL 内首次出现时返回 true；
+// 尚未被清理的过期键按未出现处理，结果不依赖清理时机
+func claimDownloadKey(key string) bool {
+	now := time.Now()
+
+	countedSessionsMu.Lock()
+	defer countedSessionsMu.Unlock()
+	countedAt, seen := countedSessions[key]
+	if seen && now.Sub(countedAt) <= downloadSessionTTL {
+		return false
+	}
+	if !seen && len(countedSessions) >= MaxDownloadKeys {
+		evictOldestCountedLocked()
+	}
+	countedSessions[key] = now
+	return true
+}
+
+// evictOldestCountedLocked 移除最早登记的计数键，调用方需持有 countedSessionsMu
+func evictOldestCountedLocked() {
+	var oldestKey string
+	var oldest time.Time
+	for k, countedAt := range countedSessions {
+		if oldestKey == "" || countedAt.Before(oldest) {
+			oldestKey = k
+			oldest = countedAt
+		}
+	}
+	delete(countedSessions, oldestKey)
+}
+
+// attributeDownload 按当前清单和模组信息确定下载文件所属的频道版本与模组
+func attributeDownload(filename string) DownloadAttribution {
+	var attribution DownloadAttribution
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+		for _, update := range manifest.Updates {
+			if slices.Contains(releaseFiles(update), filename) {
+				attribution.Releases = append(attribution.Releases, ReleaseRef{Channel: channel, Version: update.Version})
+			}
+		}
+	}
+	attribution.ModID = modForFile(filename)
+	return attribution
+}
+
+// downloadETag 由文件大小和修改时间生成强 ETag，文件被替换后随之变化，供 If-Range 校验续传的仍是同一文件
+func downloadETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+}
+
+// transferCompletesFile 判断Range请求的响应是否完整送达了文件末尾
+// 仅支持单个区间；If-Range 不匹配时服务端会返回完整文件，此时按完整下载判断
+func transferCompletesFile(rangeHeader string, size, written int64) bool {
+	if written == size {
+		return true
+	}
+
+	start, end, ok := parseSingleRange(rangeHeader, size)
+	if !ok || end != size-1 {
+		return false
+	}
+	return written == end-start+1
+}
+
+// parseSingleRange 解析 "bytes=start-end" 形式的单个区间
+func parseSingleRange(rangeHeader string, size int64) (start, end int64, ok bool) {
+	spec, found := strings.CutPrefix(rangeHeader, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	if first == "" {
+		// 后缀区间：最后 N 个字节
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+
+	end = size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end > size-1 {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
+
+// downloadLinkHeader 返回下载响应的 Link 发现头：校验和文件，以及引用该文件的版本的更新日志
+func downloadLinkHeader(filename string) string {
+	links := []string{fmt.Sprintf(`</downloads/%s>; rel="checksums"`, ChecksumsFilename)}
+
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+		for _, update := range manifest.Updates {
+			if !slices.Contains(releaseFiles(update), filename) {
+				continue
+			}
+			links = append(links, fmt.Sprintf(`</changelog/%s.md>; rel="release-notes"; title="%s"`, update.Version, update.Version))
+			return strings.Join(links, ", ")
+		}
+	}
+	return strings.Join(links, ", ")
+}
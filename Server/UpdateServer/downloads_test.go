@@ -0,0 +1,327 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cutoffWriter 写出 limit 字节后返回错误，模拟下载中途断开
+type cutoffWriter struct {
+	*httptest.ResponseRecorder
+	limit int
+}
+
+func (w *cutoffWriter) Write(b []byte) (int, error) {
+	if len(b) <= w.limit {
+		w.limit -= len(b)
+		return w.ResponseRecorder.Write(b)
+	}
+	n, _ := w.ResponseRecorder.Write(b[:w.limit])
+	w.limit = 0
+	return n, errors.New("connection reset")
+}
+
+// setupDownloadTest 在临时目录中准备下载目录、统计存储和一个 size 字节的文件
+func setupDownloadTest(t *testing.T, filename string, size int) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	oldDownloads, oldManifests, oldStore, oldConfig := DownloadsDir, ManifestsDir, statsStore, config
+	t.Cleanup(func() {
+		DownloadsDir, ManifestsDir, statsStore, config = oldDownloads, oldManifests, oldStore, oldConfig
+	})
+	DownloadsDir, ManifestsDir = "downloads", "manifests"
+	config.DownloadCooldown = Duration{}
+	config.ActivityArchive.RotateSize = 0
+	statsStore = newJSONStatsStore("stats.json")
+	countedSessions = make(map[string]time.Time)
+	downloadDeliveries = make(map[string]*downloadDelivery)
+	completedDownloads = make(map[string]time.Time)
+
+	if err := os.MkdirAll(DownloadsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	if err := os.WriteFile(filepath.Join(DownloadsDir, filename), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// download 从指定客户端IP请求文件，rangeHeader 为空时请求完整文件，cutoff 大于0时送出该字节数后断开
+func download(t *testing.T, filename, ip, rangeHeader string, cutoff int) int {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/downloads/"+filename, nil)
+	r.RemoteAddr = ip + ":40000"
+	if rangeHeader != "" {
+		r.Header.Set("Range", rangeHeader)
+	}
+	recorder := httptest.NewRecorder()
+	var w http.ResponseWriter = recorder
+	if cutoff > 0 {
+		w = &cutoffWriter{ResponseRecorder: recorder, limit: cutoff}
+	}
+	downloadHandler(w, r)
+	return recorder.Code
+}
+
+func TestDownloadSlotKeyedByClientIP(t *testing.T) {
+	setupDownloadTest(t, "a.zip", 100)
+	config.MaxConnectionsPerClient = 1
+	config.RateLimit.TrustForwardedFor = false
+	clientConnections = make(map[string]int)
+
+	// 已占满 httptest 默认来源IP的名额，换一个 X-Client-Id 或伪造 X-Forwarded-For 都不能绕过
+	if !acquireDownloadSlot("192.0.2.1") {
+		t.Fatal("first slot was refused")
+	}
+	defer releaseDownloadSlot("192.0.2.1")
+
+	r := httptest.NewRequest(http.MethodGet, "/downloads/a.zip", nil)
+	r.Header.Set("X-Client-Id", "another-client")
+	r.Header.Set("X-Forwarded-For", "203.0.113.9")
+	w := httptest.NewRecorder()
+	downloadHandler(w, r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("download with a new X-Client-Id = %d, want 429", w.Code)
+	}
+
+	// 位于反向代理后时按代理追加的地址区分客户端
+	config.RateLimit.TrustForwardedFor = true
+	w = httptest.NewRecorder()
+	downloadHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Errorf("download from a different forwarded IP = %d, want 200", w.Code)
+	}
+}
+
+func TestDownloadCooldownKeyedByClientIP(t *testing.T) {
+	setupDownloadTest(t, "a.zip", 100)
+	config.DownloadCooldown = Duration{time.Hour}
+
+	if code := download(t, "a.zip", "198.51.100.1", "", 0); code != http.StatusOK {
+		t.Fatalf("first download = %d, want 200", code)
+	}
+
+	// 冷却期内同一IP换一个 X-Client-Id 仍被拒绝
+	r := httptest.NewRequest(http.MethodGet, "/downloads/a.zip", nil)
+	r.RemoteAddr = "198.51.100.1:40001"
+	r.Header.Set("X-Client-Id", "another-client")
+	w := httptest.NewRecorder()
+	downloadHandler(w, r)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("repeat download with a new X-Client-Id = %d, want 429 with Retry-After", w.Code)
+	}
+
+	if code := download(t, "a.zip", "198.51.100.1", "bytes=50-", 0); code != http.StatusPartialContent {
+		t.Errorf("range resume during cooldown = %d, want 206", code)
+	}
+	if code := download(t, "a.zip", "198.51.100.2", "", 0); code != http.StatusOK {
+		t.Errorf("download from another IP = %d, want 200", code)
+	}
+}
+
+func TestDownloadCountedOnceAcrossResume(t *testing.T) {
+	const filename, size = "big.zip", 100000
+
+	type step struct {
+		rangeHeader string
+		cutoff      int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		want  int64
+	}{
+		{"complete", []step{{"", 0}}, 1},
+		{"interrupted then resumed", []step{{"", 50000}, {"bytes=50000-", 0}}, 1},
+		{"two ranges", []step{{"bytes=0-49999", 0}, {"bytes=50000-", 0}}, 1},
+		{"ranges out of order", []step{{"bytes=50000-", 0}, {"bytes=0-49999", 0}}, 1},
+		{"overlapping ranges", []step{{"bytes=0-59999", 0}, {"bytes=40000-", 0}}, 1},
+		{"interrupted only", []step{{"", 50000}}, 0},
+		{"partial probe", []step{{"bytes=0-99", 0}, {"bytes=-100", 0}}, 0},
+		{"gap remains", []step{{"bytes=0-49999", 0}, {"bytes=50001-", 0}}, 0},
+		{"repeated complete downloads", []step{{"", 0}, {"", 50000}, {"bytes=50000-", 0}, {"", 0}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDownloadTest(t, filename, size)
+			for _, s := range tt.steps {
+				code := download(t, filename, "198.51.100.1", s.rangeHeader, s.cutoff)
+				if code != http.StatusOK && code != http.StatusPartialContent {
+					t.Fatalf("download %q: status %d", s.rangeHeader, code)
+				}
+			}
+			if got := statsStore.Snapshot().FileDownloads[filename]; got != tt.want {
+				t.Errorf("downloads = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadResumeByAnotherClientNotMerged(t *testing.T) {
+	setupDownloadTest(t, "big.zip", 100000)
+	download(t, "big.zip", "198.51.100.1", "", 50000)
+	download(t, "big.zip", "198.51.100.2", "bytes=50000-", 0)
+	if got := statsStore.Snapshot().FileDownloads["big.zip"]; got != 0 {
+		t.Errorf("downloads = %d, want 0", got)
+	}
+}
+
+func TestAddByteRange(t *testing.T) {
+	tests := []struct {
+		ranges     [][2]int64
+		start, end int64
+		want       [][2]int64
+	}{
+		{nil, 0, 10, [][2]int64{{0, 10}}},
+		{[][2]int64{{0, 10}}, 10, 20, [][2]int64{{0, 20}}},
+		{[][2]int64{{0, 10}}, 11, 20, [][2]int64{{0, 10}, {11, 20}}},
+		{[][2]int64{{20, 30}}, 0, 10, [][2]int64{{0, 10}, {20, 30}}},
+		{[][2]int64{{0, 10}, {20, 30}, {40, 50}}, 5, 45, [][2]int64{{0, 50}}},
+		{[][2]int64{{0, 10}, {20, 30}, {40, 50}}, 12, 18, [][2]int64{{0, 10}, {12, 18}, {20, 30}, {40, 50}}},
+	}
+	for _, tt := range tests {
+		if got := addByteRange(tt.ranges, tt.start, tt.end); !slices.Equal(got, tt.want) {
+			t.Errorf("addByteRange(%v, %d, %d) = %v, want %v", tt.ranges, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+// downloadInSession 在下载会话中请求文件，rangeHeader 为空时请求完整文件，cutoff 大于0时送出该字节数后断开
+func downloadInSession(t *testing.T, filename, session, rangeHeader string, cutoff int) int {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/downloads/"+filename, nil)
+	if session != "" {
+		r.Header.Set("X-Download-Session", session)
+	}
+	if rangeHeader != "" {
+		r.Header.Set("Range", rangeHeader)
+	}
+	recorder := httptest.NewRecorder()
+	var w http.ResponseWriter = recorder
+	if cutoff > 0 {
+		w = &cutoffWriter{ResponseRecorder: recorder, limit: cutoff}
+	}
+	downloadHandler(w, r)
+	return recorder.Code
+}
+
+func TestDownloadKeysBounded(t *testing.T) {
+	setupDownloadTest(t, "a.zip", 100)
+	t.Cleanup(func() {
+		countedSessions = make(map[string]time.Time)
+		downloadDeliveries = make(map[string]*downloadDelivery)
+	})
+	now := time.Now()
+
+	// 已满时登记新键淘汰最早的键
+	for i := range MaxDownloadKeys {
+		key := fmt.Sprintf("key-%d", i)
+		countedSessions[key] = now.Add(time.Duration(i) * time.Millisecond)
+		downloadDeliveries[key] = &downloadDelivery{ranges: [][2]int64{{0, 1}}, updatedAt: now.Add(time.Duration(i) * time.Millisecond)}
+	}
+	if !claimDownloadKey("new") {
+		t.Fatal("new key was not claimed")
+	}
+	recordDelivery("new", "", 100, 0, 10, now.Add(time.Hour))
+	for name, n := range map[string]int{"countedSessions": len(countedSessions), "downloadDeliveries": len(downloadDeliveries)} {
+		if n != MaxDownloadKeys {
+			t.Errorf("%s has %d keys, want %d", name, n, MaxDownloadKeys)
+		}
+	}
+	if _, ok := countedSessions["key-0"]; ok {
+		t.Error("oldest counted key was not evicted")
+	}
+	if _, ok := downloadDeliveries["key-0"]; ok {
+		t.Error("oldest delivery was not evicted")
+	}
+
+	// 零散区间超过上限时放弃该键
+	for i := range int64(MaxDeliveryRanges + 1) {
+		recordDelivery("scattered", "", 1000, i*10, 1, now)
+	}
+	if _, ok := downloadDeliveries["scattered"]; ok {
+		t.Errorf("delivery with more than %d ranges was kept", MaxDeliveryRanges)
+	}
+
+	// 过长的会话标识按未提供处理
+	r := httptest.NewRequest(http.MethodGet, "/downloads/a.zip", nil)
+	r.Header.Set("X-Download-Session", strings.Repeat("s", MaxDownloadSessionLength+1))
+	if session := downloadSession(r); session != "" {
+		t.Errorf("downloadSession = %d bytes, want empty", len(session))
+	}
+}
+
+func TestDownloadCountedOnCompletion(t *testing.T) {
+	const filename, size = "big.zip", 100000
+
+	type step struct {
+		session     string
+		rangeHeader string
+		cutoff      int
+	}
+	tests := []struct {
+		name  string
+		steps []step
+		want  int64
+	}{
+		{"complete", []step{{"", "", 0}}, 1},
+		{"interrupted", []step{{"", "", 50000}}, 0},
+		{"interrupted then resumed", []step{{"s1", "", 50000}, {"s1", "bytes=50000-", 0}}, 1},
+		{"range not reaching the end", []step{{"", "bytes=0-99", 0}}, 0},
+		{"same session twice", []step{{"s1", "", 0}, {"s1", "", 0}}, 1},
+		{"resume after completion in same session", []step{{"s1", "", 0}, {"s1", "bytes=50000-", 0}}, 1},
+		{"different sessions", []step{{"s1", "", 0}, {"s2", "", 0}}, 2},
+		{"same client same day", []step{{"", "", 0}, {"", "", 0}}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupDownloadTest(t, filename, size)
+			for _, s := range tt.steps {
+				downloadInSession(t, filename, s.session, s.rangeHeader, s.cutoff)
+			}
+			stats := statsStore.Snapshot()
+			if got := stats.FileDownloads[filename]; got != tt.want {
+				t.Errorf("downloads = %d, want %d", got, tt.want)
+			}
+			if stats.TotalDownloads != tt.want {
+				t.Errorf("total downloads = %d, want %d", stats.TotalDownloads, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransferCompletesFile(t *testing.T) {
+	tests := []struct {
+		rangeHeader string
+		written     int64
+		want        bool
+	}{
+		{"bytes=0-", 1000, true},
+		{"bytes=500-", 500, true},
+		{"bytes=500-999", 500, true},
+		{"bytes=500-5000", 500, true},
+		{"bytes=-300", 300, true},
+		{"bytes=500-", 200, false},
+		{"bytes=0-499", 500, false},
+		{"bytes=0-99,900-999", 200, false},
+		{"items=0-", 1000, true},
+		{"garbage", 10, false},
+	}
+	for _, tt := range tests {
+		if got := transferCompletesFile(tt.rangeHeader, 1000, tt.written); got != tt.want {
+			t.Errorf("transferCompletesFile(%q, 1000, %d) = %v, want %v", tt.rangeHeader, tt.written, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// ManifestFilters 客户端请求清单时携带的过滤参数
+type ManifestFilters struct {
+	Platform string `json:"platform,omitempty"`
+	ClientID string `json:"clientId,omitempty"`
+	Phase    string `json:"phase,omitempty"`
+}
+
+// FilteredOutUpdate 被过滤掉的版本及原因
+type FilteredOutUpdate struct {
+	Version string `json:"version"`
+	Reason  string `json:"reason"`
+}
+
+// EffectiveManifestResponse 客户端实际收到的清单及过滤说明
+type EffectiveManifestResponse struct {
+	Channel  string              `json:"channel"`
+	Filters  ManifestFilters     `json:"filters"`
+	Applied  []string            `json:"applied"`
+	Ignored  []string            `json:"ignored,omitempty"`
+	Removed  []FilteredOutUpdate `json:"removed"`
+	Manifest *UpdateManifest     `json:"manifest"`
+}
+
+// manifestFiltersFromQuery 读取公开清单支持的过滤参数
+func manifestFiltersFromQuery(r *http.Request) ManifestFilters {
+	query := r.URL.Query()
+	return ManifestFilters{
+		Platform: query.Get("platform"),
+		ClientID: query.Get("clientId"),
+		Phase:    query.Get("phase"),
+	}
+}
+
+// hasManifestFilters 是否需要过滤清单，未携带任何参数的请求返回原始清单
+func hasManifestFilters(filters ManifestFilters) bool {
+	return filters.Platform != "" || filters.ClientID != ""
+}
+
+// filterManifest 生成客户端实际收到的清单，返回过滤后的清单、已应用的过滤器和被移除的版本
+// 撤回的版本总是移除；携带 clientId 时移除客户端不在灰度范围内的非强制版本；
+// 携带 platform 时只保留该平台及不限平台的文件，调试符号不下发
+func filterManifest(manifest *UpdateManifest, filters ManifestFilters) (*UpdateManifest, []string, []FilteredOutUpdate) {
+	filtered := *manifest
+	filtered.Updates = make([]UpdateInfo, 0, len(manifest.Updates))
+	applied := []string{"yank"}
+	if filters.ClientID != "" {
+		applied = append(applied, "rollout")
+	}
+	if filters.Platform != "" {
+		applied = append(applied, "platform")
+	}
+
+	removed := []FilteredOutUpdate{}
+	for _, update := range manifest.Updates {
+		if update.Yanked {
+			removed = append(removed, FilteredOutUpdate{Version: update.Version, Reason: "yanked"})
+			continue
+		}
+		if filters.ClientID != "" && !update.IsMandatory &&
+			rolloutBucket(filters.ClientID, update.Version) >= rolloutPercentage(&update) {
+			removed = append(removed, FilteredOutUpdate{Version: update.Version, Reason: "not in rollout"})
+			continue
+		}
+		if filters.Platform != "" {
+			update.Assets = platformAssets(update, filters.Platform)
+			syncPrimaryAsset(&update)
+		}
+		filtered.Updates = append(filtered.Updates, update)
+	}
+
+	filtered.LatestVersion = ""
+	if release := currentRelease(&filtered); release != nil {
+		filtered.LatestVersion = release.Version
+	}
+	if hasManifestFilters(filters) {
+		filtered.ContentHash = manifestContentHash(&filtered)
+	}
+	return &filtered, applied, removed
+}
+
+// platformAssets 返回平台适用的发布文件，assetForPlatform 选中的文件排在首位作为主文件
+func platformAssets(update UpdateInfo, platform string) []Asset {
+	if len(update.Assets) == 0 {
+		return nil
+	}
+	primary, _ := assetForPlatform(update, platform)
+	assets := []Asset{primary}
+	for _, asset := range update.Assets {
+		if asset.Url == primary.Url || asset.Kind == AssetKindSymbols {
+			continue
+		}
+		if asset.Platform == "" || asset.Platform == platform {
+			assets = append(assets, asset)
+		}
+	}
+	return assets
+}
+
+// writeFilteredManifest 按请求参数过滤后返回公开清单
+func writeFilteredManifest(w http.ResponseWriter, r *http.Request, channel string, filters ManifestFilters) {
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		log.Printf("Error reading manifest: %v", err)
+		return
+	}
+
+	filtered, _, _ := filterManifest(manifest, filters)
+	if base, ok := servedManifestBaseURL(r); ok {
+		rewriteManifestURLs(filtered, base)
+	}
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(filtered); err != nil {
+		http.Error(w, "Failed to encode manifest", http.StatusInternalServerError)
+		return
+	}
+	writeWithETag(w, r, "application/json", buf.Bytes())
+}
+
+// effectiveManifestHandler 预览指定客户端从公开清单接口收到的内容，用于核对过滤结果
+// GET /api/manifests/{channel}/effective?platform=windows&clientId=abc
+func effectiveManifestHandler(w http.ResponseWriter, r *http.Request, channel string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		return
+	}
+
+	filters := manifestFiltersFromQuery(r)
+	response := EffectiveManifestResponse{Channel: channel, Filters: filters}
+	if hasManifestFilters(filters) {
+		response.Manifest, response.Applied, response.Removed = filterManifest(manifest, filters)
+	} else {
+		// 未携带参数的客户端收到原始清单
+		response.Manifest, response.Applied, response.Removed = manifest, []string{}, []FilteredOutUpdate{}
+	}
+	if filters.Phase != "" {
+		// 服务器尚未实现发布阶段，phase 参数不影响下发的清单
+		response.Ignored = append(response.Ignored, "phase")
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// ManifestExportSchemaVersion 导出文件格式版本
+const ManifestExportSchemaVersion = 1
+
+// ManifestExport 全部频道清单的导出文件
+type ManifestExport struct {
+	SchemaVersion int                        `json:"schemaVersion"`
+	ExportedAt    time.Time                  `json:"exportedAt"`
+	Manifests     map[string]*UpdateManifest `json:"manifests"`
+	// Checksum 为 manifests 字段JSON的SHA256
+	Checksum string `json:"checksum"`
+}
+
+// manifestsChecksum 计算清单集合的校验和
+func manifestsChecksum(manifests map[string]*UpdateManifest) string {
+	data, _ := json.Marshal(manifests)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// exportManifestsHandler 导出全部频道清单为单个文件
+// GET /api/manifests/export
+func exportManifestsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	export := ManifestExport{
+		SchemaVersion: ManifestExportSchemaVersion,
+		ExportedAt:    time.Now(),
+		Manifests:     make(map[string]*UpdateManifest),
+	}
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+		export.Manifests[channel] = manifest
+	}
+	export.Checksum = manifestsChecksum(export.Manifests)
+
+	filename := fmt.Sprintf("lizard-manifests-%s.json", export.ExportedAt.Format("20060102T150405"))
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(export)
+}
+
+// importManifestsHandler 导入导出文件，先校验全部清单，任一频道保存失败时恢复已导入的频道
+// POST /api/manifests/import
+func importManifestsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var export ManifestExport
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 32<<20)).Decode(&export); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if export.SchemaVersion != ManifestExportSchemaVersion {
+		http.Error(w, fmt.Sprintf("Unsupported schema version %d", export.SchemaVersion), http.StatusBadRequest)
+		return
+	}
+	if export.Checksum != manifestsChecksum(export.Manifests) {
+		http.Error(w, "Checksum mismatch", http.StatusBadRequest)
+		return
+	}
+
+	for channel, manifest := range export.Manifests {
+		if !isValidChannel(channel) || manifest == nil {
+			http.Error(w, fmt.Sprintf("Invalid channel %q", channel), http.StatusBadRequest)
+			return
+		}
+		if err := validateManifest(channel, manifest); err != nil {
+			http.Error(w, fmt.Sprintf("%s: %v", channel, err), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	previous := make(map[string]*UpdateManifest)
+	imported := []string{}
+	for _, channel := range Channels {
+		manifest, ok := export.Manifests[channel]
+		if !ok {
+			continue
+		}
+		if current, err := loadManifest(channel); err == nil {
+			previous[channel] = current
+		}
+
+		manifest.LastUpdated = time.Now()
+		err := saveManifest(channel, manifest)
+		if err == nil {
+			imported = append(imported, channel)
+			continue
+		}
+
+		// 恢复本次已导入的频道
+		for _, done := range imported {
+			if old := previous[done]; old != nil {
+				if rollbackErr := saveManifest(done, old); rollbackErr != nil {
+					log.Printf("Error restoring manifest %s: %v", done, rollbackErr)
+				}
+			}
+		}
+		if errors.Is(err, errInvalidManifest) {
+			http.Error(w, fmt.Sprintf("%s: %v", channel, err), http.StatusUnprocessableEntity)
+			return
+		}
+		http.Error(w, "Failed to save manifest", http.StatusInternalServerError)
+		log.Printf("Error importing manifest %s: %v", channel, err)
+		return
+	}
+
+	addActivity("manifest", fmt.Sprintf("Imported manifests: %v", imported))
+	log.Printf("Manifests imported: %v", imported)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"imported": imported,
+	})
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxFilenameBytes 上传文件名的最大字节数，留出余量以兼容各文件系统的255字节限制
+const MaxFilenameBytes = 200
+
+// maxExtensionBytes 截断文件名时保留的最长扩展名
+const maxExtensionBytes = 32
+
+// errInvalidFilename 文件名无法规范化为可用的名称
+var errInvalidFilename = errors.New("invalid filename")
+
+// defaultUploadExtensions 默认允许上传的扩展名；.exe 等可执行文件需在 uploadExtensions 中显式加入
+func defaultUploadExtensions() []string {
+	return []string{".zip", ".jar", ".dmg", ".md"}
+}
+
+// uploadExtensions 生效的扩展名白名单
+func uploadExtensions() []string {
+	if len(config.UploadExtensions) == 0 {
+		return defaultUploadExtensions()
+	}
+	return config.UploadExtensions
+}
+
+// hasExtension 文件名是否以该扩展名结尾（不区分大小写），扩展名可以是 .tar.gz 这样的多段扩展名
+func hasExtension(name, ext string) bool {
+	return strings.HasPrefix(ext, ".") && len(name) > len(ext) && strings.EqualFold(name[len(name)-len(ext):], ext)
+}
+
+// matchExtension 返回 exts 中与文件名匹配的最长扩展名，没有匹配时返回空字符串
+func matchExtension(name string, exts []string) string {
+	match := ""
+	for _, ext := range exts {
+		if len(ext) > len(match) && hasExtension(name, ext) {
+			match = ext
+		}
+	}
+	return match
+}
+
+// uploadExtensionAllowed 文件名的扩展名是否在 uploadExtensions 白名单中（不区分大小写，支持多段扩展名），"*" 表示不限制
+func uploadExtensionAllowed(filename string) bool {
+	allowed := uploadExtensions()
+	return slices.Contains(allowed, "*") || matchExtension(filename, allowed) != ""
+}
+
+// uploadFilename 校验并规范化上传的文件名：拒绝以点开头的隐藏文件，规范化后扩展名须在白名单中
+func uploadFilename(name string) (string, error) {
+	if strings.HasPrefix(path.Base(strings.ReplaceAll(name, "\\", "/")), ".") {
+		return "", errInvalidFilename
+	}
+	filename, err := sanitizeFilename(name)
+	if err != nil {
+		return "", err
+	}
+	if !uploadExtensionAllowed(filename) {
+		ext := path.Ext(filename)
+		if ext == "" {
+			return "", errors.New("file extension required")
+		}
+		return "", fmt.Errorf("file extension %q not allowed", ext)
+	}
+	return filename, nil
+}
+
+// windowsReservedNames Windows 保留的设备名，无论扩展名如何都不能作为文件名
+var windowsReservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// sanitizeFilename 将上传的文件名规范化为跨平台可用的名称：
+// 去掉路径、NFC 规范化、去除控制字符、替换 Windows 非法字符、去掉首尾的点和空格、
+// 按字节截断并保留扩展名；Windows 保留名直接拒绝
+func sanitizeFilename(name string) (string, error) {
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = norm.NFC.String(name)
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == utf8.RuneError, unicode.IsControl(r), unicode.Is(unicode.Cf, r):
+			continue
+		case strings.ContainsRune(`<>:"/\|?*`, r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	name = strings.Trim(b.String(), ". ")
+	if name == "" {
+		return "", errInvalidFilename
+	}
+
+	stem, _, _ := strings.Cut(name, ".")
+	if windowsReservedNames[strings.ToUpper(strings.TrimSpace(stem))] {
+		return "", errInvalidFilename
+	}
+
+	if len(name) > MaxFilenameBytes {
+		// 白名单中的多段扩展名（如 .tar.gz）整体保留
+		ext := matchExtension(name, uploadExtensions())
+		if ext == "" {
+			ext = path.Ext(name)
+		}
+		if len(ext) > maxExtensionBytes {
+			ext = ""
+		}
+		stem := truncateUTF8(strings.TrimSuffix(name, ext), MaxFilenameBytes-len(ext))
+		name = strings.TrimRight(stem, ". ") + ext
+	}
+	return name, nil
+}
+
+// truncateUTF8 按字节截断字符串，不截断多字节字符
+func truncateUTF8(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit]
+}
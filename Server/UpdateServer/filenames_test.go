@@ -0,0 +1,167 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string // 为空表示应拒绝
+	}{
+		{"plain", "LizardClient-1.0.0.zip", "LizardClient-1.0.0.zip"},
+		{"chinese", "客户端-1.0.zip", "客户端-1.0.zip"},
+		{"emoji", "Ünïcödé 😀.zip", "Ünïcödé 😀.zip"},
+		{"nfd to nfc", "Cafe\u0301.zip", "Caf\u00e9.zip"},
+		{"zero width", "a\u200bb\u200e.zip", "ab.zip"},
+		{"control", "a\x00b\x1f.zip", "ab.zip"},
+		{"invalid utf8", "a\xffb.zip", "ab.zip"},
+		{"windows illegal", `a<b>:c"d|e?f*.zip`, "a_b__c_d_e_f_.zip"},
+		{"windows path", `C:\Users\x\evil.zip`, "evil.zip"},
+		{"unix path", "../../etc/passwd.zip", "passwd.zip"},
+
+		{"trailing dot", "file.zip.", "file.zip"},
+		{"trailing space", "file.zip ", "file.zip"},
+		{"trailing dots and spaces", "file.zip. . ", "file.zip"},
+		{"leading space", "  file.zip", "file.zip"},
+		{"only dots", "...", ""},
+		{"only spaces", "   ", ""},
+		{"empty", "", ""},
+
+		{"CON", "CON", ""},
+		{"con lowercase", "con.zip", ""},
+		{"NUL with extensions", "NUL.tar.gz", ""},
+		{"NUL trailing dot", "nul.", ""},
+		{"CON trailing space before extension", "CON .zip", ""},
+		{"COM1", "COM1.zip", ""},
+		{"LPT9", "lpt9", ""},
+		{"PRN in path", `dir\PRN.zip`, ""},
+		{"reserved prefix allowed", "CONSOLE.zip", "CONSOLE.zip"},
+		{"COM10 allowed", "COM10.zip", "COM10.zip"},
+		{"reserved inside name allowed", "my-con.zip", "my-con.zip"},
+
+		{"long ascii", strings.Repeat("a", 300) + ".zip", strings.Repeat("a", MaxFilenameBytes-4) + ".zip"},
+		{"long multibyte", strings.Repeat("é", 150) + ".zip", strings.Repeat("é", (MaxFilenameBytes-4)/2) + ".zip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sanitizeFilename(tt.in)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("sanitizeFilename(%q) = %q, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("sanitizeFilename(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+			}
+			if len(got) > MaxFilenameBytes {
+				t.Errorf("sanitizeFilename(%q) is %d bytes, limit %d", tt.in, len(got), MaxFilenameBytes)
+			}
+		})
+	}
+}
+
+func TestUploadFilename(t *testing.T) {
+	old := config
+	t.Cleanup(func() { config = old })
+	config = defaultConfig()
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"LizardClient.zip", "LizardClient.zip"},
+		{"LizardClient.ZIP", "LizardClient.ZIP"},
+		{"客户端.jar", "客户端.jar"},
+		{"setup.exe", ""},
+		{"archive.tar.gz", ""},
+		{".hidden.zip", ""},
+		{`dir\.hidden.zip`, ""},
+		{"notes.txt", ""},
+		{"noextension", ""},
+		{"AUX.zip", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := uploadFilename(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("uploadFilename(%q) = %q, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("uploadFilename(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestUploadFilenameConfiguredExtensions(t *testing.T) {
+	old := config
+	t.Cleanup(func() { config = old })
+	config = defaultConfig()
+	config.UploadExtensions = []string{".exe", ".tar.gz"}
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"setup.exe.", "setup.exe"},
+		{"LizardClient-1.0.0.TAR.GZ", "LizardClient-1.0.0.TAR.GZ"},
+		{"LizardClient.gz", ""},
+		{".tar.gz", ""},
+		{"LizardClient.zip", ""},
+		{strings.Repeat("a", 300) + ".tar.gz", strings.Repeat("a", MaxFilenameBytes-7) + ".tar.gz"},
+	}
+	for _, tt := range tests {
+		got, err := uploadFilename(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("uploadFilename(%q) = %q, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("uploadFilename(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestCheckUploadType(t *testing.T) {
+	old := config
+	t.Cleanup(func() { config = old })
+	config = defaultConfig()
+	config.UploadSniff.AllowedTypes[".tar.gz"] = []string{"application/x-gzip"}
+
+	zip := []byte("PK\x03\x04rest")
+	gzip := []byte("\x1f\x8b\x08rest")
+	html := []byte("<!DOCTYPE html><html><script>alert(1)</script></html>")
+	tests := []struct {
+		name     string
+		sniff    bool
+		filename string
+		claimed  string
+		head     []byte
+		want     bool
+	}{
+		{"sniff disabled zip", false, "a.zip", "application/zip", zip, true},
+		{"sniff disabled html content", false, "a.md", "text/markdown", html, false},
+		{"sniff disabled html claimed", false, "a.md", "text/html; charset=utf-8", []byte("# notes"), false},
+		{"sniff enabled zip", true, "a.zip", "", zip, true},
+		{"sniff enabled mismatch", true, "a.zip", "", html, false},
+		{"sniff enabled compound extension", true, "a.tar.gz", "", gzip, true},
+		{"sniff enabled compound extension mismatch", true, "a.tar.gz", "", zip, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.UploadSniff.Enabled = tt.sniff
+			if detected, ok := checkUploadType(tt.filename, tt.claimed, tt.head); ok != tt.want {
+				t.Errorf("checkUploadType(%q, %q) = %q, %v; want %v", tt.filename, tt.claimed, detected, ok, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// safeJoin 将请求中的相对路径拼接到 base 下；清理后为绝对路径、含空字节或不在 base 之内时返回错误
+func safeJoin(base, userPath string) (string, error) {
+	if userPath == "" || strings.ContainsRune(userPath, 0) {
+		return "", fmt.Errorf("invalid path %q", userPath)
+	}
+	userPath = filepath.FromSlash(userPath)
+	if filepath.IsAbs(userPath) || filepath.VolumeName(userPath) != "" {
+		return "", fmt.Errorf("absolute path %q not allowed", userPath)
+	}
+
+	joined := filepath.Join(base, userPath)
+	rel, err := filepath.Rel(filepath.Clean(base), joined)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q escapes %s", userPath, base)
+	}
+	return joined, nil
+}
+
+// removeStaleTempFiles 删除进程中断时 atomicWriteFile 遗留的临时文件，启动时调用
+func removeStaleTempFiles(dirs ...string) {
+	for _, dir := range dirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, ".*.tmp-*"))
+		for _, match := range matches {
+			if err := os.Remove(match); err == nil {
+				log.Printf("Removed stale temp file: %s", match)
+			}
+		}
+	}
+}
+
+// atomicWriteFile 先写入同目录临时文件再重命名，避免进程中断时留下半写文件
+func atomicWriteFile(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// 同步目录项，断电后重命名同样生效；部分平台不支持同步目录，忽略错误
+	if dir, err := os.Open(filepath.Dir(path)); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+	return nil
+}
+
+// moveFile 将文件移动到 dst；上传临时目录与下载目录不在同一文件系统（如下载目录挂载为独立的卷）时
+// 无法直接重命名，改为复制到目标目录的临时文件后重命名，再删除源文件
+func moveFile(src, dst string) error {
+	renameErr := os.Rename(src, dst)
+	if renameErr == nil {
+		return nil
+	}
+	if _, err := os.Stat(src); err != nil {
+		return renameErr
+	}
+
+	tmpPath, err := copyToTemp(src, dst)
+	if err == nil {
+		err = os.Rename(tmpPath, dst)
+	}
+	if err != nil {
+		if tmpPath != "" {
+			os.Remove(tmpPath)
+		}
+		return fmt.Errorf("%w (copy: %v)", renameErr, err)
+	}
+	removeMovedSource(src, dst)
+	return nil
+}
+
+// moveFileExclusive 与 moveFile 相同，但 dst 已存在时不覆盖并返回 fs.ErrExist；
+// 以硬链接原子地创建目标，检查目标是否存在与移入之间不会被并发的同名写入抢先
+func moveFileExclusive(src, dst string) error {
+	linkErr := os.Link(src, dst)
+	if linkErr == nil {
+		removeMovedSource(src, dst)
+		return nil
+	}
+	if errors.Is(linkErr, fs.ErrExist) {
+		return linkErr
+	}
+	if _, err := os.Stat(src); err != nil {
+		return linkErr
+	}
+
+	// 跨文件系统时先复制到目标目录，再在同一目录内链接
+	tmpPath, err := copyToTemp(src, dst)
+	if err == nil {
+		err = os.Link(tmpPath, dst)
+	}
+	if tmpPath != "" {
+		os.Remove(tmpPath)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return err
+		}
+		return fmt.Errorf("%w (copy: %v)", linkErr, err)
+	}
+	removeMovedSource(src, dst)
+	return nil
+}
+
+// copyToTemp 将 src 复制到 dst 所在目录的临时文件并同步到磁盘，返回临时文件路径；出错时临时文件已删除
+func copyToTemp(src, dst string) (string, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := tmp.Name()
+	// 保留源文件权限，与直接重命名的结果一致
+	if info, statErr := in.Stat(); statErr == nil {
+		tmp.Chmod(info.Mode().Perm())
+	}
+	_, err = io.Copy(tmp, in)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	return tmpPath, nil
+}
+
+// removeMovedSource 删除已移入 dst 的源文件
+func removeMovedSource(src, dst string) {
+	if err := os.Remove(src); err != nil {
+		log.Printf("Error removing %s after copying to %s: %v", src, dst, err)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMoveFileExclusive(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "upload.tmp")
+	dst := filepath.Join(dir, "LizardClient.zip")
+	if err := os.WriteFile(src, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 检查之后由并发上传创建的同名文件
+	if err := os.WriteFile(dst, []byte("existing"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := moveFileExclusive(src, dst); !errors.Is(err, fs.ErrExist) {
+		t.Fatalf("moveFileExclusive onto existing file = %v, want fs.ErrExist", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "existing" {
+		t.Errorf("existing file replaced with %q", data)
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Errorf("source removed after failed move: %v", err)
+	}
+
+	os.Remove(dst)
+	if err := moveFileExclusive(src, dst); err != nil {
+		t.Fatalf("moveFileExclusive = %v", err)
+	}
+	if data, _ := os.ReadFile(dst); string(data) != "new" {
+		t.Errorf("moved file contains %q, want %q", data, "new")
+	}
+	if _, err := os.Stat(src); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("source still present after move: %v", err)
+	}
+}
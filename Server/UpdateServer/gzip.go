@@ -0,0 +1,200 @@
+package main
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultGzipMinSize 小于该字节数的响应不压缩，压缩收益抵不过开销
+const DefaultGzipMinSize = 1024
+
+// GzipConfig 响应压缩配置
+type GzipConfig struct {
+	// Enabled 是否对文本响应进行 gzip 压缩
+	Enabled bool `json:"enabled"`
+	// MinSize 压缩的最小响应字节数
+	MinSize int `json:"minSize"`
+}
+
+// gzipExcludedPrefixes 不压缩的路由：下载文件本身已压缩，且需要保留 Content-Length 与 Range 支持
+var gzipExcludedPrefixes = []string{"/downloads/", "/patches/"}
+
+var gzipWriterPool = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// acceptsGzip 客户端的 Accept-Encoding 是否接受 gzip（q=0 表示拒绝）
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// compressibleType 文本类内容值得压缩
+func compressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"):
+		return true
+	case strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// gzipResponseWriter 先缓冲响应开头，达到 MinSize 且内容可压缩时改为 gzip 输出
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	status      int
+	wroteHeader bool
+	// decided 已确定是否压缩并写出响应头
+	decided bool
+	buf     []byte
+	gz      *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+
+	// 无响应体、分段响应或处理器已编码的内容直接透传
+	header := w.Header()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified ||
+		header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" ||
+		!compressibleType(header.Get("Content-Type")) {
+		w.commitPlain()
+	}
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		if w.gz != nil {
+			return w.gz.Write(b)
+		}
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf = append(w.buf, b...)
+	if len(w.buf) >= w.minSize {
+		if err := w.startGzip(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// startGzip 写出压缩响应头并将已缓冲的内容写入压缩流
+func (w *gzipResponseWriter) startGzip() error {
+	w.decided = true
+	header := w.Header()
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", "gzip")
+	w.ResponseWriter.WriteHeader(w.status)
+
+	w.gz = gzipWriterPool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+	buf := w.buf
+	w.buf = nil
+	_, err := w.gz.Write(buf)
+	return err
+}
+
+// commitPlain 不压缩，写出响应头和已缓冲的内容
+func (w *gzipResponseWriter) commitPlain() {
+	w.decided = true
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) > 0 {
+		w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+// Flush 流式输出时不再等待达到 MinSize
+func (w *gzipResponseWriter) Flush() {
+	if w.wroteHeader && !w.decided {
+		w.startGzip()
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close 处理器返回后输出未达到 MinSize 的缓冲内容或结束压缩流
+func (w *gzipResponseWriter) close() {
+	if w.wroteHeader && !w.decided {
+		w.commitPlain()
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		gzipWriterPool.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问底层ResponseWriter
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// gzipMiddleware 对接受 gzip 的客户端压缩清单、更新日志、统计等文本响应
+func gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.Gzip.Enabled || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		for _, prefix := range gzipExcludedPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		// 同一URL的响应因 Accept-Encoding 而不同，缓存需分别保存
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w, minSize: config.Gzip.MinSize}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
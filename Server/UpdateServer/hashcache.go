@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// HashCacheFile 文件哈希缓存，与统计数据一起定期保存，重启后无需重新读取所有文件
+const HashCacheFile = "./hashcache.json"
+
+// hashCacheEntry 哈希缓存条目，文件大小或修改时间变化时失效
+type hashCacheEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash"`
+}
+
+var (
+	hashCache   = make(map[string]hashCacheEntry)
+	hashCacheMu sync.Mutex
+	// hashCacheDirty 缓存有变化尚未保存
+	hashCacheDirty bool
+)
+
+// loadHashCache 启动时加载持久化的哈希缓存，丢弃文件已删除或已变化的条目
+func loadHashCache() {
+	data, err := os.ReadFile(HashCacheFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error reading hash cache: %v", err)
+		}
+		return
+	}
+
+	var loaded map[string]hashCacheEntry
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		log.Printf("Ignoring corrupt hash cache: %v", err)
+		return
+	}
+
+	hashCacheMu.Lock()
+	defer hashCacheMu.Unlock()
+	for path, entry := range loaded {
+		info, err := os.Stat(path)
+		if err != nil || info.Size() != entry.Size || !info.ModTime().Equal(entry.ModTime) {
+			hashCacheDirty = true
+			continue
+		}
+		hashCache[path] = entry
+	}
+}
+
+// saveHashCache 缓存有变化时写入磁盘
+func saveHashCache() {
+	hashCacheMu.Lock()
+	if !hashCacheDirty {
+		hashCacheMu.Unlock()
+		return
+	}
+	data, err := json.MarshalIndent(hashCache, "", "  ")
+	hashCacheDirty = false
+	hashCacheMu.Unlock()
+	if err != nil {
+		log.Printf("Error encoding hash cache: %v", err)
+		return
+	}
+
+	if err := atomicWriteFile(HashCacheFile, data, 0644); err != nil {
+		hashCacheMu.Lock()
+		hashCacheDirty = true
+		hashCacheMu.Unlock()
+		log.Printf("Error saving hash cache: %v", err)
+	}
+}
+
+// cachedFileHash 获取文件SHA256哈希，文件未变化时直接返回缓存结果
+func cachedFileHash(filePath string) (string, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	hashCacheMu.Lock()
+	entry, ok := hashCache[filePath]
+	hashCacheMu.Unlock()
+	if ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		return entry.Hash, nil
+	}
+
+	hash, err := calculateFileHash(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	storeFileHash(filePath, info, hash)
+	return hash, nil
+}
+
+// storeFileHash 写入文件哈希缓存
+func storeFileHash(filePath string, info os.FileInfo, hash string) {
+	hashCacheMu.Lock()
+	hashCache[filePath] = hashCacheEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Hash:    hash,
+	}
+	hashCacheDirty = true
+	hashCacheMu.Unlock()
+}
+
+// invalidateFileHash 移除文件的缓存哈希
+func invalidateFileHash(filePath string) {
+	hashCacheMu.Lock()
+	if _, ok := hashCache[filePath]; ok {
+		delete(hashCache, filePath)
+		hashCacheDirty = true
+	}
+	hashCacheMu.Unlock()
+}
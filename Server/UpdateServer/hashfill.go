@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// MissingHash 缺少文件哈希但文件存在的清单条目
+type MissingHash struct {
+	Channel string `json:"channel"`
+	Version string `json:"version"`
+	File    string `json:"file"`
+	Size    int64  `json:"size"`
+	Hash    string `json:"hash,omitempty"`
+}
+
+// missingHashUpdates 返回清单中缺少哈希且下载文件存在的条目
+func missingHashUpdates(channel string, manifest *UpdateManifest) []MissingHash {
+	var missing []MissingHash
+	for _, update := range manifest.Updates {
+		for _, asset := range releaseAssets(update) {
+			if asset.Hash != "" {
+				continue
+			}
+
+			name := downloadFilename(asset.Url)
+			if name == "" {
+				continue
+			}
+			info, err := os.Stat(filepath.Join(DownloadsDir, name))
+			if err != nil || info.IsDir() {
+				continue
+			}
+
+			missing = append(missing, MissingHash{
+				Channel: channel,
+				Version: update.Version,
+				File:    name,
+				Size:    info.Size(),
+			})
+		}
+	}
+	return missing
+}
+
+// fillAssetHash 补全版本中指定文件的哈希，大小未填写时一并补全
+func fillAssetHash(update *UpdateInfo, file, hash string, size int64) {
+	if len(update.Assets) == 0 {
+		update.FileHash = hash
+		if update.FileSize == 0 {
+			update.FileSize = size
+		}
+		return
+	}
+
+	for i := range update.Assets {
+		asset := &update.Assets[i]
+		if asset.Hash != "" || downloadFilename(asset.Url) != file {
+			continue
+		}
+		asset.Hash = hash
+		if asset.Size == 0 {
+			asset.Size = size
+		}
+	}
+	syncPrimaryAsset(update)
+}
+
+// missingHashesHandler 列出所有频道中缺少哈希的条目
+// GET /api/manifests/missing-hashes
+func missingHashesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	missing := []MissingHash{}
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+		missing = append(missing, missingHashUpdates(channel, manifest)...)
+	}
+
+	writeList(w, r, missing)
+}
+
+// fillHashesHandler 计算并补全缺少的文件哈希，保存前归档旧清单并重新校验
+// POST /api/manifests/fill-hashes
+func fillHashesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	filled := []MissingHash{}
+	failed := make(map[string]string)
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+
+		missing := missingHashUpdates(channel, manifest)
+		if len(missing) == 0 {
+			continue
+		}
+
+		var channelFilled []MissingHash
+		for _, entry := range missing {
+			hash, err := cachedFileHash(filepath.Join(DownloadsDir, entry.File))
+			if err != nil {
+				log.Printf("Error hashing %s: %v", entry.File, err)
+				continue
+			}
+
+			fillAssetHash(findUpdate(manifest, entry.Version), entry.File, hash, entry.Size)
+			entry.Hash = hash
+			channelFilled = append(channelFilled, entry)
+		}
+		if len(channelFilled) == 0 {
+			continue
+		}
+
+		if err := saveManifest(channel, manifest); err != nil {
+			failed[channel] = err.Error()
+			if !errors.Is(err, errInvalidManifest) {
+				log.Printf("Error saving manifest %s: %v", channel, err)
+			}
+			continue
+		}
+
+		filled = append(filled, channelFilled...)
+		addActivity("manifest", fmt.Sprintf("Filled %d missing hashes in %s", len(channelFilled), channel))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"filled": filled,
+		"failed": failed,
+	})
+}
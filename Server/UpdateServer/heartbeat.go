@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// HeartbeatRetention 心跳记录保留时长，也是活跃窗口的上限
+	HeartbeatRetention = time.Hour
+	// MaxTrackedClients 内存中最多跟踪的客户端数量
+	MaxTrackedClients = 100000
+)
+
+// clientPresence 客户端最近一次心跳
+type clientPresence struct {
+	Version  string
+	Channel  string
+	LastSeen time.Time
+}
+
+var (
+	activeClients   = make(map[string]clientPresence)
+	activeClientsMu sync.Mutex
+)
+
+// heartbeatHandler 记录客户端心跳（公开端点）
+func heartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ClientID string `json:"clientId"`
+		Version  string `json:"version"`
+		Channel  string `json:"channel"`
+	}
+
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.ClientID == "" || len(req.ClientID) > 128 {
+		http.Error(w, "Invalid clientId", http.StatusBadRequest)
+		return
+	}
+	if req.Channel != "" && !isValidChannel(req.Channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+
+	activeClientsMu.Lock()
+	if _, exists := activeClients[req.ClientID]; !exists && len(activeClients) >= MaxTrackedClients {
+		pruneClientsLocked(now)
+		if len(activeClients) >= MaxTrackedClients {
+			evictOldestClientLocked()
+		}
+	}
+	activeClients[req.ClientID] = clientPresence{
+		Version:  req.Version,
+		Channel:  req.Channel,
+		LastSeen: now,
+	}
+	activeClientsMu.Unlock()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// activeClientsHandler 返回窗口期内活跃的客户端数量
+// GET /api/active-clients?window=5m
+func activeClientsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	window := 5 * time.Minute
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			http.Error(w, "Invalid window", http.StatusBadRequest)
+			return
+		}
+		window = min(d, HeartbeatRetention)
+	}
+
+	now := time.Now()
+	cutoff := now.Add(-window)
+	count := 0
+	byChannel := make(map[string]int)
+	byVersion := make(map[string]int)
+
+	activeClientsMu.Lock()
+	pruneClientsLocked(now)
+	for _, client := range activeClients {
+		if client.LastSeen.Before(cutoff) {
+			continue
+		}
+		count++
+		if client.Channel != "" {
+			byChannel[client.Channel]++
+		}
+		if client.Version != "" {
+			byVersion[client.Version]++
+		}
+	}
+	activeClientsMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"window":        window.String(),
+		"activeClients": count,
+		"byChannel":     byChannel,
+		"byVersion":     byVersion,
+	})
+}
+
+// pruneClientsLocked 移除超过保留时长的心跳记录，调用方需持有锁
+func pruneClientsLocked(now time.Time) {
+	cutoff := now.Add(-HeartbeatRetention)
+	for id, client := range activeClients {
+		if client.LastSeen.Before(cutoff) {
+			delete(activeClients, id)
+		}
+	}
+}
+
+// evictOldestClientLocked 移除最久未活跃的客户端，调用方需持有锁
+func evictOldestClientLocked() {
+	var oldestID string
+	var oldest time.Time
+	for id, client := range activeClients {
+		if oldestID == "" || client.LastSeen.Before(oldest) {
+			oldestID = id
+			oldest = client.LastSeen
+		}
+	}
+	delete(activeClients, oldestID)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// historyExt 归档清单扩展名，归档内容使用gzip压缩存储
+const historyExt = ".json.gz"
+
+// DefaultManifestHistoryKeep 每个频道默认保留的历史归档数量
+const DefaultManifestHistoryKeep = 200
+
+// ManifestHistoryConfig 清单历史归档的保留策略，两个条件都满足时才保留
+type ManifestHistoryConfig struct {
+	// Keep 每个频道保留的最近归档数量，0 表示不按数量清理
+	Keep int `json:"keep"`
+	// MaxAge 早于该时长的归档被删除（最新一份除外），0 表示不按时间清理
+	MaxAge Duration `json:"maxAge"`
+}
+
+// HistoryEntry 清单历史记录
+type HistoryEntry struct {
+	ID         string    `json:"id"`
+	ArchivedAt time.Time `json:"archivedAt"`
+	Size       int64     `json:"size"`
+}
+
+// historyDir 返回频道历史归档目录
+func historyDir(channel string) string {
+	return filepath.Join(ManifestsDir, "history", channel)
+}
+
+// channelHistoryDirs 返回所有频道的历史目录
+func channelHistoryDirs() []string {
+	dirs := make([]string, 0, len(Channels))
+	for _, channel := range Channels {
+		dirs = append(dirs, historyDir(channel))
+	}
+	return dirs
+}
+
+// archiveManifest 将写入前的清单内容压缩归档并返回归档文件路径，data 为 nil（频道原先没有清单）时不做任何操作；
+// 保存事务回滚时由调用方删除该归档，旧归档的清理在提交成功后进行
+func archiveManifest(channel string, data []byte) (string, error) {
+	if data == nil {
+		return "", nil
+	}
+
+	dir := historyDir(channel)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(data); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+
+	id := time.Now().UTC().Format("20060102T150405.000000000Z")
+	path := filepath.Join(dir, id+historyExt)
+	if err := atomicWriteFile(path, buf.Bytes(), 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// pruneManifestHistory 按保留策略删除频道的旧归档，最新一份始终保留
+func pruneManifestHistory(channel string, now time.Time) error {
+	keep := config.ManifestHistory.Keep
+	maxAge := config.ManifestHistory.MaxAge.Duration
+	if keep <= 0 && maxAge <= 0 {
+		return nil
+	}
+
+	entries, err := listManifestHistory(channel)
+	if err != nil {
+		return err
+	}
+
+	removed := 0
+	for i, entry := range entries {
+		if i == 0 {
+			continue
+		}
+		expired := maxAge > 0 && !entry.ArchivedAt.IsZero() && now.Sub(entry.ArchivedAt) > maxAge
+		if (keep > 0 && i >= keep) || expired {
+			if err := os.Remove(filepath.Join(historyDir(channel), entry.ID+historyExt)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			removed++
+		}
+	}
+	if removed > 0 {
+		log.Printf("Pruned %d manifest history entries for %s", removed, channel)
+	}
+	return nil
+}
+
+// readArchivedManifest 读取并解压归档清单
+func readArchivedManifest(channel, id string) ([]byte, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return nil, errors.New("invalid history id")
+	}
+
+	file, err := os.Open(filepath.Join(historyDir(channel), id+historyExt))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	return io.ReadAll(gz)
+}
+
+// listManifestHistory 列出频道的历史归档，按时间降序
+func listManifestHistory(channel string) ([]HistoryEntry, error) {
+	files, err := os.ReadDir(historyDir(channel))
+	if os.IsNotExist(err) {
+		return []HistoryEntry{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]HistoryEntry, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), historyExt) {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		id := strings.TrimSuffix(file.Name(), historyExt)
+		archivedAt, _ := time.Parse("20060102T150405.000000000Z", id)
+		entries = append(entries, HistoryEntry{
+			ID:         id,
+			ArchivedAt: archivedAt,
+			Size:       info.Size(),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID > entries[j].ID
+	})
+	return entries, nil
+}
+
+// validateManifest 校验清单内容是否可以发布
+func validateManifest(channel string, manifest *UpdateManifest) error {
+	if manifest.Channel != "" && manifest.Channel != channel {
+		return fmt.Errorf("manifest channel %q does not match %q", manifest.Channel, channel)
+	}
+	if manifest.LatestVersion != "" && findUpdate(manifest, manifest.LatestVersion) == nil {
+		return fmt.Errorf("latestVersion %q not found in updates", manifest.LatestVersion)
+	}
+	return nil
+}
+
+// historyHandler 处理清单历史相关请求
+// GET  /api/manifests/{channel}/history
+// GET  /api/manifests/{channel}/history/{id}
+// POST /api/manifests/{channel}/history/{id}/restore
+func historyHandler(w http.ResponseWriter, r *http.Request, channel string, parts []string) {
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 0:
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		entries, err := listManifestHistory(channel)
+		if err != nil {
+			http.Error(w, "Failed to read history", http.StatusInternalServerError)
+			log.Printf("Error reading manifest history: %v", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+
+	case len(parts) == 1:
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		data, err := readArchivedManifest(channel, parts[0])
+		if err != nil {
+			http.Error(w, "History entry not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+
+	case len(parts) == 2 && parts[1] == "restore":
+		restoreManifestHandler(w, r, channel, parts[0])
+
+	default:
+		apiNotFoundHandler(w, r)
+	}
+}
+
+// restoreManifestHandler 将频道清单恢复为指定的历史版本
+func restoreManifestHandler(w http.ResponseWriter, r *http.Request, channel, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := readArchivedManifest(channel, id)
+	if err != nil {
+		http.Error(w, "History entry not found", http.StatusNotFound)
+		return
+	}
+
+	// 归档可能早于当前清单结构，恢复前同样迁移
+	manifest, _, err := decodeManifest(channel, data)
+	if err != nil {
+		http.Error(w, "Archived manifest is invalid", http.StatusUnprocessableEntity)
+		return
+	}
+	if err := validateManifest(channel, manifest); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	manifest.LastUpdated = time.Now()
+
+	manifestMu.Lock()
+	err = saveManifest(channel, manifest)
+	manifestMu.Unlock()
+	if err != nil {
+		http.Error(w, "Failed to save manifest", http.StatusInternalServerError)
+		log.Printf("Error saving manifest: %v", err)
+		return
+	}
+
+	addActivity("manifest", fmt.Sprintf("Restored manifest %s from %s", channel, id))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+
+	log.Printf("Manifest restored: %s (%s)", channel, id)
+}
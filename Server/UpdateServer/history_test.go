@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestManifestHistoryArchiveRestore(t *testing.T) {
+	original := setupManifestTest(t)
+
+	if err := saveManifest("stable", testManifest("2.0.0")); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := listManifestHistory("stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("history has %d entries, want 1", len(entries))
+	}
+	archived, err := readArchivedManifest("stable", entries[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(archived, original) {
+		t.Errorf("archived manifest differs from the one it replaced:\n%s", archived)
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/api/manifests/stable/history/"+entries[0].ID+"/restore", nil)
+	restoreManifestHandler(rec, req, "stable", entries[0].ID)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore status = %d: %s", rec.Code, rec.Body)
+	}
+
+	restored, err := loadManifest("stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.LatestVersion != "1.0.0" {
+		t.Errorf("restored latestVersion = %q, want 1.0.0", restored.LatestVersion)
+	}
+
+	// 恢复本身也是一次保存，被替换的 2.0.0 进入历史
+	entries, _ = listManifestHistory("stable")
+	if len(entries) != 2 {
+		t.Errorf("history has %d entries after restore, want 2", len(entries))
+	}
+}
+
+func TestManifestHistoryKeepsRecentEntries(t *testing.T) {
+	setupManifestTest(t)
+	config.ManifestHistory = ManifestHistoryConfig{Keep: 3}
+
+	for i := 2; i <= 7; i++ {
+		if err := saveManifest("stable", testManifest(fmt.Sprintf("%d.0.0", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := listManifestHistory("stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("history has %d entries, want 3", len(entries))
+	}
+
+	// 保留的是最近归档的 4.0.0 到 6.0.0
+	data, err := readArchivedManifest("stable", entries[len(entries)-1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, _, err := decodeManifest("stable", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.LatestVersion != "4.0.0" {
+		t.Errorf("oldest kept entry is %q, want 4.0.0", manifest.LatestVersion)
+	}
+}
+
+func TestManifestHistoryPrunesByAge(t *testing.T) {
+	setupManifestTest(t)
+	config.ManifestHistory = ManifestHistoryConfig{MaxAge: Duration{24 * time.Hour}}
+
+	dir := historyDir("stable")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-48 * time.Hour).UTC().Format("20060102T150405.000000000Z")
+	if err := os.WriteFile(filepath.Join(dir, old+historyExt), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 唯一的归档即使过期也保留
+	if err := pruneManifestHistory("stable", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := listManifestHistory("stable"); len(entries) != 1 {
+		t.Fatalf("history has %d entries, want the only entry kept", len(entries))
+	}
+
+	if err := saveManifest("stable", testManifest("2.0.0")); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := listManifestHistory("stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].ID == old {
+		t.Errorf("history = %+v, want only the new archive", entries)
+	}
+}
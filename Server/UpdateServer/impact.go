@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// FileReference 清单中对文件的引用
+type FileReference struct {
+	Channel  string `json:"channel"`
+	Version  string `json:"version"`
+	IsLatest bool   `json:"isLatest"`
+}
+
+// DeleteImpact 删除文件的影响评估
+type DeleteImpact struct {
+	File         string          `json:"file"`
+	Exists       bool            `json:"exists"`
+	References   []FileReference `json:"references"`
+	BreaksLatest bool            `json:"breaksLatest"`
+}
+
+// deleteImpact 评估删除文件会影响哪些频道和版本
+func deleteImpact(filename string) DeleteImpact {
+	impact := DeleteImpact{
+		File:       filename,
+		References: []FileReference{},
+	}
+
+	if info, err := os.Stat(filepath.Join(DownloadsDir, filename)); err == nil && !info.IsDir() {
+		impact.Exists = true
+	}
+
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+
+		release := currentRelease(manifest)
+		for _, update := range manifest.Updates {
+			if !slices.Contains(releaseFiles(update), filename) {
+				continue
+			}
+
+			isLatest := release != nil && release.Version == update.Version
+			impact.References = append(impact.References, FileReference{
+				Channel:  channel,
+				Version:  update.Version,
+				IsLatest: isLatest,
+			})
+			if isLatest {
+				impact.BreaksLatest = true
+			}
+		}
+	}
+	return impact
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// IntegrityCheckConfig 定期完整性检查配置
+type IntegrityCheckConfig struct {
+	// Interval 自动检查的间隔，0 表示只能通过 /api/jobs/integrity 手动触发
+	Interval Duration `json:"interval"`
+}
+
+// IntegrityStatus 完整性检查状态
+type IntegrityStatus struct {
+	Interval Duration   `json:"interval"`
+	NextRun  *time.Time `json:"nextRun,omitempty"`
+	Job      JobStatus  `json:"job"`
+	// LastReport 最近一次完成的检查报告，尚未检查过时为 null
+	LastReport *VerifyReport `json:"lastReport"`
+	// Problems 最近一次检查中哈希或大小不一致、文件缺失的条目
+	Problems []VerifyResult `json:"problems"`
+}
+
+var (
+	integrityNextRun   time.Time
+	integrityNextRunMu sync.Mutex
+
+	// integrityKnownProblems 上一次检查发现的问题，只为新出现的问题记录活动日志
+	integrityKnownProblems   = make(map[string]bool)
+	integrityKnownProblemsMu sync.Mutex
+)
+
+// isIntegrityProblem 校验结果是否表示磁盘文件与清单不一致；清单未填写哈希不算
+func isIntegrityProblem(status string) bool {
+	return status == VerifyHashMismatch || status == VerifySizeMismatch || status == VerifyMissingFile
+}
+
+// integrityProblems 返回报告中的问题条目
+func integrityProblems(report VerifyReport) []VerifyResult {
+	problems := []VerifyResult{}
+	for _, result := range report.Results {
+		if isIntegrityProblem(result.Status) {
+			problems = append(problems, result)
+		}
+	}
+	return problems
+}
+
+// reportIntegrityProblems 记录检查发现的问题：每次都写日志，新出现的问题额外记入活动日志
+func reportIntegrityProblems(report VerifyReport) {
+	problems := integrityProblems(report)
+	current := make(map[string]bool, len(problems))
+
+	integrityKnownProblemsMu.Lock()
+	defer integrityKnownProblemsMu.Unlock()
+
+	for _, p := range problems {
+		key := fmt.Sprintf("%s/%s/%s/%s", p.Channel, p.Version, p.File, p.Status)
+		current[key] = true
+		log.Printf("Integrity check: %s/%s %s: %s", p.Channel, p.Version, p.File, p.Status)
+		if integrityKnownProblems[key] {
+			continue
+		}
+
+		switch p.Status {
+		case VerifyMissingFile:
+			addActivity("integrity", fmt.Sprintf("Missing file for %s/%s: %s", p.Channel, p.Version, p.File))
+		case VerifySizeMismatch:
+			addActivity("integrity", fmt.Sprintf("Size mismatch for %s/%s: %s (expected %d, actual %d)",
+				p.Channel, p.Version, p.File, p.ExpectedSize, p.ActualSize))
+		default:
+			addActivity("integrity", fmt.Sprintf("Hash mismatch for %s/%s: %s (expected %s, actual %s)",
+				p.Channel, p.Version, p.File, p.ExpectedHash, p.ActualHash))
+		}
+	}
+	for key := range integrityKnownProblems {
+		if !current[key] {
+			log.Printf("Integrity check: resolved %s", key)
+		}
+	}
+	integrityKnownProblems = current
+
+	if len(problems) == 0 {
+		log.Printf("Integrity check passed (%d files)", len(report.Results))
+	}
+}
+
+// integrityCheckLoop 按配置的间隔定期启动完整性检查任务
+func integrityCheckLoop() {
+	interval := config.IntegrityCheck.Interval.Duration
+	if interval <= 0 {
+		return
+	}
+
+	for {
+		next := time.Now().Add(interval)
+		integrityNextRunMu.Lock()
+		integrityNextRun = next
+		integrityNextRunMu.Unlock()
+
+		time.Sleep(time.Until(next))
+		if err := startJob("integrity"); err != nil && !errors.Is(err, errJobRunning) {
+			log.Printf("Error starting integrity check: %v", err)
+		}
+	}
+}
+
+// integrityStatus 返回检查配置、下次运行时间和最近一次检查结果
+func integrityStatus() IntegrityStatus {
+	status := IntegrityStatus{
+		Interval: config.IntegrityCheck.Interval,
+		Job:      jobStatus("integrity"),
+		Problems: []VerifyResult{},
+	}
+
+	// 报告单独返回，任务状态中不再重复
+	if report, ok := status.Job.LastResult.(VerifyReport); ok {
+		status.LastReport = &report
+		status.Problems = integrityProblems(report)
+	}
+	status.Job.LastResult = nil
+
+	integrityNextRunMu.Lock()
+	if !integrityNextRun.IsZero() {
+		next := integrityNextRun
+		status.NextRun = &next
+	}
+	integrityNextRunMu.Unlock()
+	return status
+}
+
+// integrityHandler 返回最近一次完整性检查的结果，立即检查使用 POST /api/jobs/integrity
+// GET /api/integrity
+func integrityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(integrityStatus())
+}
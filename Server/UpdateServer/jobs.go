@@ -0,0 +1,378 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JobsConfig 后台维护任务配置
+type JobsConfig struct {
+	// Workers 并发计算哈希的工作协程数
+	Workers int `json:"workers"`
+	// IORateLimit 所有工作协程合计的读取速率上限（字节/秒），0 表示不限速
+	IORateLimit int64 `json:"ioRateLimit"`
+}
+
+// JobStatus 后台任务状态
+type JobStatus struct {
+	Name       string      `json:"name"`
+	Running    bool        `json:"running"`
+	StartedAt  *time.Time  `json:"startedAt,omitempty"`
+	FinishedAt *time.Time  `json:"finishedAt,omitempty"`
+	Total      int         `json:"total"`
+	Done       int         `json:"done"`
+	Progress   float64     `json:"progress"`
+	Error      string      `json:"error,omitempty"`
+	LastResult interface{} `json:"lastResult,omitempty"`
+}
+
+// backgroundJob 可取消的后台任务
+type backgroundJob struct {
+	status JobStatus
+	cancel context.CancelFunc
+}
+
+// jobRunners 已注册的后台任务
+var jobRunners = map[string]func(ctx context.Context, job *backgroundJob) (interface{}, error){
+	"rehash":           runRehashJob,
+	"integrity":        runIntegrityJob,
+	"compact-activity": runActivityCompactionJob,
+}
+
+var (
+	jobs   = make(map[string]*backgroundJob)
+	jobsMu sync.Mutex
+)
+
+// startJob 启动后台任务，同名任务运行中时返回错误
+func startJob(name string) error {
+	runner, ok := jobRunners[name]
+	if !ok {
+		return fmt.Errorf("unknown job %q", name)
+	}
+
+	jobsMu.Lock()
+	job := jobs[name]
+	if job != nil && job.status.Running {
+		jobsMu.Unlock()
+		return errJobRunning
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	startedAt := time.Now()
+	job = &backgroundJob{
+		status: JobStatus{Name: name, Running: true, StartedAt: &startedAt},
+		cancel: cancel,
+	}
+	if previous := jobs[name]; previous != nil {
+		job.status.LastResult = previous.status.LastResult
+	}
+	jobs[name] = job
+	jobsMu.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := runner(ctx, job)
+		finishedAt := time.Now()
+
+		jobsMu.Lock()
+		job.status.Running = false
+		job.status.FinishedAt = &finishedAt
+		if err != nil {
+			job.status.Error = err.Error()
+		} else {
+			job.status.LastResult = result
+		}
+		jobsMu.Unlock()
+
+		if err != nil {
+			log.Printf("Job %s stopped: %v", name, err)
+		} else {
+			log.Printf("Job %s finished in %s", name, finishedAt.Sub(startedAt))
+		}
+	}()
+
+	log.Printf("Job %s started", name)
+	return nil
+}
+
+// errJobRunning 任务已在运行
+var errJobRunning = errors.New("job already running")
+
+// setJobProgress 更新任务进度
+func setJobProgress(job *backgroundJob, done, total int) {
+	jobsMu.Lock()
+	job.status.Done = done
+	job.status.Total = total
+	if total > 0 {
+		job.status.Progress = float64(done) / float64(total) * 100
+	}
+	jobsMu.Unlock()
+}
+
+// jobStatuses 返回所有任务状态（包括从未运行过的任务）
+func jobStatuses() []JobStatus {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+
+	statuses := make([]JobStatus, 0, len(jobRunners))
+	for _, name := range []string{"rehash", "integrity", "compact-activity"} {
+		statuses = append(statuses, jobStatusLocked(name))
+	}
+	return statuses
+}
+
+// jobStatus 返回单个任务的状态
+func jobStatus(name string) JobStatus {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	return jobStatusLocked(name)
+}
+
+// jobStatusLocked 返回单个任务的状态，调用方需持有 jobsMu
+func jobStatusLocked(name string) JobStatus {
+	if job := jobs[name]; job != nil {
+		return job.status
+	}
+	return JobStatus{Name: name}
+}
+
+// hashFilesThrottled 按配置的并发度和速率重新计算文件哈希并写入缓存
+func hashFilesThrottled(ctx context.Context, job *backgroundJob, paths []string) error {
+	workers := max(config.Jobs.Workers, 1)
+	limiter := newRateLimiter(config.Jobs.IORateLimit)
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range queue {
+				if err := rehashFile(ctx, path, limiter); err != nil && ctx.Err() == nil {
+					log.Printf("Error hashing %s: %v", path, err)
+				}
+
+				mu.Lock()
+				done++
+				setJobProgress(job, done, len(paths))
+				mu.Unlock()
+			}
+		}()
+	}
+
+	setJobProgress(job, 0, len(paths))
+	for _, path := range paths {
+		select {
+		case queue <- path:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return errors.New("cancelled")
+	}
+	return nil
+}
+
+// rehashFile 限速读取文件计算哈希，并更新哈希缓存
+func rehashFile(ctx context.Context, filePath string, limiter *rateLimiter) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.New()
+	buf := make([]byte, 256<<10)
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		n, readErr := file.Read(buf)
+		if n > 0 {
+			hash.Write(buf[:n])
+			if err := limiter.wait(ctx, n); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	storeFileHash(filePath, info, hex.EncodeToString(hash.Sum(nil)))
+	return nil
+}
+
+// downloadFilePaths 返回下载目录中的所有文件路径
+func downloadFilePaths() ([]string, error) {
+	files, err := os.ReadDir(DownloadsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		paths = append(paths, filepath.Join(DownloadsDir, file.Name()))
+	}
+	return paths, nil
+}
+
+// runRehashJob 重新计算下载目录中所有文件的哈希
+func runRehashJob(ctx context.Context, job *backgroundJob) (interface{}, error) {
+	paths, err := downloadFilePaths()
+	if err != nil {
+		return nil, err
+	}
+	if err := hashFilesThrottled(ctx, job, paths); err != nil {
+		return nil, err
+	}
+	invalidateChecksums()
+	return map[string]int{"files": len(paths)}, nil
+}
+
+// runIntegrityJob 重新读取清单引用的文件并核对哈希
+func runIntegrityJob(ctx context.Context, job *backgroundJob) (interface{}, error) {
+	var paths []string
+	for name := range referencedFiles() {
+		path := filepath.Join(DownloadsDir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			paths = append(paths, path)
+		}
+	}
+
+	if err := hashFilesThrottled(ctx, job, paths); err != nil {
+		return nil, err
+	}
+	report := verifyManifests()
+	reportIntegrityProblems(report)
+	return report, nil
+}
+
+// rateLimiter 简单的字节速率限制器
+type rateLimiter struct {
+	rate int64
+	mu   sync.Mutex
+	next time.Time
+}
+
+// newRateLimiter 创建速率限制器，rate<=0 表示不限速
+func newRateLimiter(rate int64) *rateLimiter {
+	return &rateLimiter{rate: rate}
+}
+
+// wait 为读取的 n 个字节预约时间片并等待
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l.rate <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// jobsHandler 后台任务管理
+// GET    /api/jobs          任务状态
+// POST   /api/jobs/{name}   启动任务
+// DELETE /api/jobs/{name}   取消运行中的任务
+func jobsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/jobs" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jobStatuses())
+		return
+	}
+
+	parts, ok := splitSubpath(r.URL.Path, "/api/jobs/")
+	if !ok || len(parts) != 1 {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+	name := parts[0]
+	if _, ok := jobRunners[name]; !ok {
+		writeJSONError(w, http.StatusNotFound, "Unknown job: "+name)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		if err := startJob(name); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		addActivity("job", fmt.Sprintf("Started job: %s", name))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"status": "started"})
+
+	case http.MethodDelete:
+		jobsMu.Lock()
+		job := jobs[name]
+		running := job != nil && job.status.Running
+		if running {
+			job.cancel()
+		}
+		jobsMu.Unlock()
+
+		if !running {
+			http.Error(w, "Job not running", http.StatusConflict)
+			return
+		}
+		addActivity("job", fmt.Sprintf("Cancelled job: %s", name))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "cancelling"})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// JWTSecretEnv JWT 签名密钥的环境变量
+	JWTSecretEnv = "LIZARD_JWT_SECRET"
+	// DefaultTokenTTL 未配置 tokenTtl 时令牌的有效期
+	DefaultTokenTTL = 15 * time.Minute
+)
+
+// jwtHeader 固定的 JWT 头，只签发和接受 HS256
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// tokenClaims JWT 载荷
+type tokenClaims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// LoginResponse /api/login 成功响应
+type LoginResponse struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"tokenType"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	ExpiresIn int       `json:"expiresIn"`
+}
+
+var (
+	// ephemeralJWTSecret 未设置环境变量时使用的临时密钥，重启后令牌失效
+	ephemeralJWTSecret     []byte
+	ephemeralJWTSecretOnce sync.Once
+)
+
+// jwtSecret 返回令牌签名密钥
+func jwtSecret() []byte {
+	if secret := os.Getenv(JWTSecretEnv); secret != "" {
+		return []byte(secret)
+	}
+
+	ephemeralJWTSecretOnce.Do(func() {
+		ephemeralJWTSecret = make([]byte, 32)
+		if _, err := rand.Read(ephemeralJWTSecret); err != nil {
+			log.Fatalf("Failed to generate token secret: %v", err)
+		}
+		log.Printf("%s not set, tokens will not survive a restart", JWTSecretEnv)
+	})
+	return ephemeralJWTSecret
+}
+
+// tokenTTL 返回令牌有效期
+func tokenTTL() time.Duration {
+	if config.TokenTTL.Duration > 0 {
+		return config.TokenTTL.Duration
+	}
+	return DefaultTokenTTL
+}
+
+// signToken 计算 JWT 签名
+func signToken(signingInput string) string {
+	mac := hmac.New(sha256.New, jwtSecret())
+	mac.Write([]byte(signingInput))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issueToken 为账号签发 JWT
+func issueToken(user AdminUser, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(tokenTTL())
+	data, err := json.Marshal(tokenClaims{
+		Subject:   user.Username,
+		Role:      user.Role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	signingInput := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(data)
+	return signingInput + "." + signToken(signingInput), expiresAt, nil
+}
+
+// parseToken 校验 JWT 的头、签名和有效期，返回令牌对应的账号
+func parseToken(token string, now time.Time) (AdminUser, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return AdminUser{}, errors.New("malformed token")
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(signToken(parts[0]+"."+parts[1]))) {
+		return AdminUser{}, errors.New("invalid signature")
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return AdminUser{}, errors.New("malformed token")
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return AdminUser{}, errors.New("malformed token")
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return AdminUser{}, errors.New("token expired")
+	}
+
+	// 账号已被移除的令牌立即失效，角色以令牌签发时为准
+	user, ok := lookupUser(claims.Subject)
+	if !ok {
+		return AdminUser{}, errors.New("unknown user")
+	}
+	user.Role = claims.Role
+	return user, nil
+}
+
+// jwtAuth 接受 Bearer 令牌，未携带令牌时回退到基础认证，兼容现有脚本
+func jwtAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || mtlsEnabled() {
+			basicAuth(handler)(w, r)
+			return
+		}
+
+		user, err := parseToken(strings.TrimSpace(token), time.Now())
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeJSONError(w, http.StatusUnauthorized, "Invalid token: "+err.Error())
+			return
+		}
+		handler(w, withAdminUser(r, user))
+	}
+}
+
+// apiLoginHandler 校验账号密码并签发 JWT
+// POST /api/login {"username", "password"}
+func apiLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	user, ok := checkAdminCredentials(req.Username, req.Password)
+	if !ok {
+		log.Printf("Failed API login for %q from %s", req.Username, r.RemoteAddr)
+		writeJSONError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	now := time.Now()
+	token, expiresAt, err := issueToken(user, now)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Failed to issue token")
+		return
+	}
+	log.Printf("API login: %s (%s)", user.Username, user.Role)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(LoginResponse{
+		Token:     token,
+		TokenType: "Bearer",
+		ExpiresAt: expiresAt,
+		ExpiresIn: int(expiresAt.Sub(now).Seconds()),
+	})
+}
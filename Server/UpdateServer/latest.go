@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// LatestInfo 精简的最新版本信息，供轻量客户端使用
+type LatestInfo struct {
+	Version         string  `json:"version"`
+	DownloadUrl     string  `json:"downloadUrl"`
+	FileHash        string  `json:"fileHash"`
+	FileSize        int64   `json:"fileSize"`
+	Mandatory       bool    `json:"mandatory"`
+	ForceRedownload bool    `json:"forceRedownload,omitempty"`
+	Assets          []Asset `json:"assets,omitempty"`
+}
+
+// latestCacheEntry 按清单文件修改时间缓存的 latest.json
+type latestCacheEntry struct {
+	modTime time.Time
+	data    []byte // nil 表示频道没有可用版本
+	etag    string
+}
+
+var (
+	latestCache   = make(map[string]latestCacheEntry)
+	latestCacheMu sync.Mutex
+)
+
+// latestHandler latest-{channel}.json 处理器工厂函数
+func latestHandler(channel string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entry, err := latestForChannel(channel)
+		if err != nil {
+			http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+			log.Printf("Error building latest.json for %s: %v", channel, err)
+			return
+		}
+		if entry.data == nil {
+			http.Error(w, "No releases available", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("ETag", entry.etag)
+		if etagMatches(r.Header.Get("If-None-Match"), entry.etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(entry.data)
+	}
+}
+
+// latestForChannel 返回频道的 latest.json，清单文件变化时重新生成
+func latestForChannel(channel string) (latestCacheEntry, error) {
+	info, err := os.Stat(manifestPath(channel))
+	if err != nil {
+		return latestCacheEntry{}, err
+	}
+
+	latestCacheMu.Lock()
+	entry, ok := latestCache[channel]
+	latestCacheMu.Unlock()
+	if ok && entry.modTime.Equal(info.ModTime()) {
+		return entry, nil
+	}
+
+	manifest, err := loadPublicManifest(channel)
+	if err != nil {
+		return latestCacheEntry{}, err
+	}
+
+	entry = latestCacheEntry{modTime: info.ModTime()}
+	if release := currentRelease(manifest); release != nil {
+		data, err := json.Marshal(LatestInfo{
+			Version:         release.Version,
+			DownloadUrl:     release.DownloadUrl,
+			FileHash:        release.FileHash,
+			FileSize:        release.FileSize,
+			Mandatory:       release.IsMandatory,
+			ForceRedownload: release.ForceRedownload || manifest.ForceRedownload,
+			Assets:          release.Assets,
+		})
+		if err != nil {
+			return latestCacheEntry{}, err
+		}
+		sum := sha256.Sum256(data)
+		entry.data = data
+		entry.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+
+	latestCacheMu.Lock()
+	latestCache[channel] = entry
+	latestCacheMu.Unlock()
+	return entry, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ChannelLatest 批量查询中单个频道的结果
+type ChannelLatest struct {
+	Status int         `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Latest *UpdateInfo `json:"latest,omitempty"`
+}
+
+// MultiLatestResponse 多频道最新版本
+type MultiLatestResponse struct {
+	Platform string                   `json:"platform,omitempty"`
+	Channels map[string]ChannelLatest `json:"channels"`
+}
+
+// channelLatest 返回频道当前发布的版本，指定平台时只保留该平台适用的文件
+func channelLatest(channel, platform string) ChannelLatest {
+	if !isValidChannel(channel) {
+		return ChannelLatest{Status: http.StatusBadRequest, Error: "Invalid channel"}
+	}
+	manifest, err := loadPublicManifest(channel)
+	if err != nil {
+		return ChannelLatest{Status: http.StatusNotFound, Error: "Manifest not found"}
+	}
+
+	release := currentRelease(manifest)
+	if release == nil {
+		return ChannelLatest{Status: http.StatusNotFound, Error: "No releases available"}
+	}
+	update := *release
+	if platform != "" {
+		update.Assets = platformAssets(update, platform)
+		syncPrimaryAsset(&update)
+	}
+	return ChannelLatest{Status: http.StatusOK, Latest: &update}
+}
+
+// latestMultiHandler 一次返回多个频道的最新版本，无效或缺失的频道在结果中单独标记状态
+// GET /api/latest-multi?channels=stable,beta,dev&platform=windows
+func latestMultiHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	channels := Channels
+	if raw := query.Get("channels"); raw != "" {
+		channels = strings.Split(raw, ",")
+	}
+
+	response := MultiLatestResponse{
+		Platform: query.Get("platform"),
+		Channels: make(map[string]ChannelLatest),
+	}
+	for _, channel := range channels {
+		channel = strings.TrimSpace(channel)
+		if channel == "" {
+			continue
+		}
+		if _, seen := response.Channels[channel]; !seen {
+			response.Channels[channel] = channelLatest(channel, response.Platform)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(response); err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	etag := contentETag(buf.Bytes())
+
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// LimitsSchemaVersion /api/limits 响应结构版本，不兼容的变更时递增
+	LimitsSchemaVersion = 1
+	// MultipartFormMemory 上传表单中普通字段的总大小上限；文件部分直接流式写入磁盘，不在内存中缓冲
+	MultipartFormMemory = 64 << 10
+	// limitsCacheTTL 限制信息的缓存时间
+	limitsCacheTTL = 30 * time.Second
+)
+
+// ServerLimits 服务器配置的限制，不包含任何密钥
+type ServerLimits struct {
+	SchemaVersion  int                 `json:"schemaVersion"`
+	Upload         UploadLimits        `json:"upload"`
+	Downloads      DownloadLimits      `json:"downloads"`
+	ReleaseCadence map[string]Duration `json:"releaseCadence"`
+	HashAlgorithms []string            `json:"hashAlgorithms"`
+	Channels       []string            `json:"channels"`
+	Platforms      []string            `json:"platforms"`
+	Storage        StorageLimits       `json:"storage"`
+}
+
+// UploadLimits 上传限制
+type UploadLimits struct {
+	// MaxUploadSize 单次上传的最大字节数，0 表示不限制
+	MaxUploadSize    int64    `json:"maxUploadSize"`
+	MaxFormMemory    int64    `json:"maxFormMemory"`
+	MaxFilenameBytes int      `json:"maxFilenameBytes"`
+	ChunkedUploads   bool     `json:"chunkedUploads"`
+	UploadSessionTTL Duration `json:"uploadSessionTtl"`
+}
+
+// DownloadLimits 下载限制，0 表示不限制
+type DownloadLimits struct {
+	MaxConnectionsPerClient int      `json:"maxConnectionsPerClient"`
+	DownloadCooldown        Duration `json:"downloadCooldown"`
+}
+
+// StorageLimits 存储配额与剩余空间
+type StorageLimits struct {
+	// Quota 下载目录的存储配额，0 表示不限制
+	Quota int64 `json:"quota"`
+	Used  int64 `json:"used"`
+	// Remaining 配额剩余字节数，未配置配额时为 null
+	Remaining *int64 `json:"remaining"`
+	// DiskFree 磁盘剩余空间，无法获取时为 null
+	DiskFree *int64 `json:"diskFree"`
+}
+
+var (
+	limitsCache    ServerLimits
+	limitsCachedAt time.Time
+	limitsCacheMu  sync.Mutex
+)
+
+// currentLimits 返回服务器限制，短时间内复用缓存
+func currentLimits() ServerLimits {
+	limitsCacheMu.Lock()
+	defer limitsCacheMu.Unlock()
+	if !limitsCachedAt.IsZero() && time.Since(limitsCachedAt) < limitsCacheTTL {
+		return limitsCache
+	}
+
+	cadence := make(map[string]Duration)
+	for channel, interval := range config.ReleaseCadence {
+		if isValidChannel(channel) && interval.Duration > 0 {
+			cadence[channel] = interval
+		}
+	}
+
+	limits := ServerLimits{
+		SchemaVersion: LimitsSchemaVersion,
+		Upload: UploadLimits{
+			MaxUploadSize:    config.MaxUploadSize,
+			MaxFormMemory:    MultipartFormMemory,
+			MaxFilenameBytes: MaxFilenameBytes,
+			ChunkedUploads:   true,
+			UploadSessionTTL: Duration{uploadSessionTTL()},
+		},
+		Downloads: DownloadLimits{
+			MaxConnectionsPerClient: config.MaxConnectionsPerClient,
+			DownloadCooldown:        config.DownloadCooldown,
+		},
+		ReleaseCadence: cadence,
+		HashAlgorithms: []string{"sha256"},
+		Channels:       Channels,
+		Platforms:      manifestPlatforms(),
+		Storage:        storageLimits(),
+	}
+
+	limitsCache = limits
+	limitsCachedAt = time.Now()
+	return limits
+}
+
+// manifestPlatforms 返回所有清单中出现过的资源平台
+func manifestPlatforms() []string {
+	seen := make(map[string]bool)
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+		for _, update := range manifest.Updates {
+			for _, asset := range releaseAssets(update) {
+				if asset.Platform != "" {
+					seen[asset.Platform] = true
+				}
+			}
+		}
+	}
+
+	platforms := make([]string, 0, len(seen))
+	for platform := range seen {
+		platforms = append(platforms, platform)
+	}
+	sort.Strings(platforms)
+	return platforms
+}
+
+// storageLimits 返回存储配额的使用情况和磁盘剩余空间
+func storageLimits() StorageLimits {
+	used := statsStore.StorageUsage()
+	storage := StorageLimits{Quota: config.StorageQuota, Used: used}
+	if config.StorageQuota > 0 {
+		remaining := max(config.StorageQuota-used, 0)
+		storage.Remaining = &remaining
+	}
+	if free, err := diskFreeBytes(DownloadsDir); err == nil {
+		storage.DiskFree = &free
+	}
+	return storage
+}
+
+// withinStorageQuota 判断新增 delta 字节后是否仍在存储配额内，未配置配额时总是允许
+func withinStorageQuota(delta int64) bool {
+	return config.StorageQuota <= 0 || statsStore.StorageUsage()+delta <= config.StorageQuota
+}
+
+// limitsHandler 公开服务器限制，客户端据此选择上传方式等
+// GET /api/limits
+func limitsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(currentLimits())
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	// RequestIDHeader 请求ID头，客户端提供时沿用，否则由服务器生成
+	RequestIDHeader = "X-Request-Id"
+	// MaxTraceScanBytes 追踪查询从日志文件末尾向前扫描的最大字节数
+	MaxTraceScanBytes = 64 << 20
+	// MaxTraceLines 追踪查询返回的最大行数
+	MaxTraceLines = 500
+	// LogFormatEnv 访问日志格式的环境变量，"json" 时每个请求输出一行JSON
+	LogFormatEnv = "LOG_FORMAT"
+)
+
+// accessLogEntry JSON格式的访问日志
+type accessLogEntry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMs float64   `json:"duration_ms"`
+	RemoteIP   string    `json:"remote_ip"`
+	RequestID  string    `json:"requestId"`
+	Client     string    `json:"client,omitempty"`
+}
+
+// jsonAccessLog LOG_FORMAT=json 时的访问日志输出，不带 log 包的时间前缀，便于日志系统直接解析
+var jsonAccessLog *log.Logger
+
+// requestIDKey 请求上下文中保存请求ID的键
+type requestIDKey struct{}
+
+// LogTrace 按请求ID检索到的日志
+type LogTrace struct {
+	RequestID    string   `json:"requestId"`
+	Lines        []string `json:"lines"`
+	ScannedBytes int64    `json:"scannedBytes"`
+	Truncated    bool     `json:"truncated"`
+}
+
+// setupLogFile 配置 logFile 时日志同时写入文件
+func setupLogFile() {
+	if config.LogFile == "" {
+		return
+	}
+	file, err := os.OpenFile(config.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		log.Printf("Error opening log file %s: %v", config.LogFile, err)
+		return
+	}
+	log.SetOutput(io.MultiWriter(os.Stderr, file))
+}
+
+// setupLogFormat 按 LOG_FORMAT 选择访问日志格式，需在 setupLogFile 之后调用以使用相同的输出
+func setupLogFormat() {
+	switch format := os.Getenv(LogFormatEnv); format {
+	case "", "text":
+	case "json":
+		jsonAccessLog = log.New(log.Writer(), "", 0)
+	default:
+		log.Printf("WARNING: unknown %s %q, using text access logs", LogFormatEnv, format)
+	}
+}
+
+// writeJSONAccessLog 输出一行JSON访问日志
+func writeJSONAccessLog(r *http.Request, status int, bytes int64, duration time.Duration) {
+	entry := accessLogEntry{
+		Time:       time.Now().UTC(),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Status:     status,
+		Bytes:      bytes,
+		DurationMs: float64(duration.Microseconds()) / 1000,
+		RemoteIP:   clientIP(r),
+		RequestID:  requestID(r),
+	}
+	if config.LogExtendedFields {
+		entry.Client = clientVersion(r.UserAgent())
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Error encoding access log: %v", err)
+		return
+	}
+	jsonAccessLog.Println(string(line))
+}
+
+// isValidRequestID 检查客户端提供的请求ID，只接受较短的字母数字标识，防止日志注入
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// requestIDMiddleware 为每个请求分配请求ID并通过响应头返回
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(id) {
+			buf := make([]byte, 8)
+			rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID 返回请求的ID，日志中以 rid=<id> 记录
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// traceLogFile 从日志文件末尾向前最多扫描 MaxTraceScanBytes 字节，返回包含该请求ID的行
+func traceLogFile(path, id string) (LogTrace, error) {
+	trace := LogTrace{RequestID: id, Lines: []string{}}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return trace, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return trace, err
+	}
+	offset := max(info.Size()-MaxTraceScanBytes, 0)
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return trace, err
+	}
+	trace.ScannedBytes = info.Size() - offset
+
+	needle := []byte("rid=" + id)
+	// JSON访问日志中的请求ID带引号，不会前缀匹配
+	jsonNeedle := []byte(`"requestId":"` + id + `"`)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.Contains(line, jsonNeedle) {
+			if !bytes.Contains(line, needle) {
+				continue
+			}
+			// 避免前缀匹配到更长的ID
+			rest := line[bytes.Index(line, needle)+len(needle):]
+			if len(rest) > 0 && rest[0] != ' ' {
+				continue
+			}
+		}
+		if len(trace.Lines) == MaxTraceLines {
+			trace.Truncated = true
+			break
+		}
+		trace.Lines = append(trace.Lines, string(line))
+	}
+	return trace, scanner.Err()
+}
+
+// logTraceHandler 按请求ID检索日志
+// GET /api/logs/trace?requestId=X
+func logTraceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.URL.Query().Get("requestId")
+	if !isValidRequestID(id) {
+		http.Error(w, "Invalid requestId", http.StatusBadRequest)
+		return
+	}
+	if config.LogFile == "" {
+		writeJSONError(w, http.StatusServiceUnavailable, "File logging is not configured")
+		return
+	}
+
+	trace, err := traceLogFile(config.LogFile, id)
+	if err != nil {
+		http.Error(w, "Failed to read log file", http.StatusInternalServerError)
+		log.Printf("Error reading log file: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trace)
+}
@@ -2,11 +2,13 @@ package main
 
 import (
 	"crypto/sha256"
-	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -14,9 +16,12 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-const (
+// 监听端口与数据目录，可通过启动参数或环境变量修改，见 applyPathOverrides
+var (
 	Port          = "51000"
 	ManifestsDir  = "./manifests"
 	DownloadsDir  = "./downloads"
@@ -32,12 +37,24 @@ var (
 
 // Statistics 统计数据
 type Statistics struct {
-	TotalDownloads   int64            `json:"totalDownloads"`
-	FileDownloads    map[string]int64 `json:"fileDownloads"`
-	StorageUsage     int64            `json:"storageUsage"`
-	TotalFiles       int              `json:"totalFiles"`
-	LastUpdate       time.Time        `json:"lastUpdate"`
-	RecentActivities []ActivityLog    `json:"recentActivities"`
+	TotalDownloads int64            `json:"totalDownloads"`
+	FileDownloads  map[string]int64 `json:"fileDownloads"`
+	// ChannelDownloads 按频道统计的完整下载次数，下载时按清单归属，文件被多个频道引用时分别计入
+	ChannelDownloads map[string]int64 `json:"channelDownloads"`
+	// VersionDownloads 按频道和版本统计的完整下载次数（频道 → 版本 → 次数）
+	VersionDownloads map[string]map[string]int64 `json:"versionDownloads"`
+	// ModDownloads 按模组ID统计的完整下载次数
+	ModDownloads map[string]int64 `json:"modDownloads"`
+	// FileBytes 每个下载文件实际送出的字节数
+	FileBytes map[string]int64 `json:"fileBytes"`
+	// FileClients 每个文件下载客户端的去重计数草图，大小固定
+	FileClients         map[string]clientSketch `json:"fileClients"`
+	StorageUsage        int64                   `json:"storageUsage"`
+	StorageReconciledAt time.Time               `json:"storageReconciledAt"`
+	BytesServed         int64                   `json:"bytesServed"`
+	TotalFiles          int                     `json:"totalFiles"`
+	LastUpdate          time.Time               `json:"lastUpdate"`
+	RecentActivities    []ActivityLog           `json:"recentActivities"`
 }
 
 // ActivityLog 活动日志
@@ -45,13 +62,34 @@ type ActivityLog struct {
 	Timestamp time.Time `json:"timestamp"`
 	Action    string    `json:"action"`
 	Details   string    `json:"details"`
+	// 以下字段仅用于压缩后的下载汇总记录
+	Target string     `json:"target,omitempty"`
+	Count  int        `json:"count,omitempty"`
+	Until  *time.Time `json:"until,omitempty"`
 }
 
-var stats = &Statistics{
-	FileDownloads:    make(map[string]int64),
-	RecentActivities: make([]ActivityLog, 0),
+// newStatistics 创建完整初始化的统计数据
+func newStatistics() *Statistics {
+	return &Statistics{
+		FileDownloads:    make(map[string]int64),
+		ChannelDownloads: make(map[string]int64),
+		VersionDownloads: make(map[string]map[string]int64),
+		ModDownloads:     make(map[string]int64),
+		FileBytes:        make(map[string]int64),
+		FileClients:      make(map[string]clientSketch),
+		RecentActivities: make([]ActivityLog, 0),
+	}
 }
 
+const (
+	// statsWriteAttempts 统计数据单次保存的最大尝试次数
+	statsWriteAttempts = 4
+	// statsRetryBackoff 首次重试前的等待时间，之后逐次翻倍
+	statsRetryBackoff = 50 * time.Millisecond
+	// storageReconcileInterval 存储统计全量校准间隔
+	storageReconcileInterval = 10 * time.Minute
+)
+
 // UpdateManifest 更新清单结构
 type UpdateManifest struct {
 	ManifestVersion string       `json:"manifestVersion"`
@@ -61,6 +99,10 @@ type UpdateManifest struct {
 	LastUpdated     time.Time    `json:"lastUpdated"`
 	UpdateServerUrl string       `json:"updateServerUrl"`
 	Updates         []UpdateInfo `json:"updates"`
+	// ForceRedownload 频道级开关：已是当前版本的客户端也重新下载
+	ForceRedownload bool `json:"forceRedownload,omitempty"`
+	// ContentHash 清单内容的SHA256（不含本字段），发布时自动计算
+	ContentHash string `json:"contentHash,omitempty"`
 }
 
 // UpdateInfo 更新信息
@@ -76,52 +118,140 @@ type UpdateInfo struct {
 	MinimumCompatibleVersion string    `json:"minimumCompatibleVersion"`
 	Dependencies             []string  `json:"dependencies"`
 	ReleaseNotesUrl          string    `json:"releaseNotesUrl"`
+	RolloutPercentage        int       `json:"rolloutPercentage,omitempty"`
+	Yanked                   bool      `json:"yanked"`
+	YankedReason             string    `json:"yankedReason,omitempty"`
+	ForceRedownload          bool      `json:"forceRedownload,omitempty"`
+	Signature                string    `json:"signature,omitempty"`
+	SigningKeyId             string    `json:"signingKeyId,omitempty"`
+	// Assets 多文件发布（安装包、便携版、调试符号等），顶层下载字段始终为第一个文件
+	Assets []Asset `json:"assets,omitempty"`
+	// PatchFrom 差分补丁的起始版本，补丁应用于该版本的主文件后得到本版本的主文件
+	PatchFrom string `json:"patchFrom,omitempty"`
+	PatchUrl  string `json:"patchUrl,omitempty"`
+	PatchSize int64  `json:"patchSize,omitempty"`
+	PatchHash string `json:"patchHash,omitempty"`
 }
 
 // HealthResponse 健康检查响应
 type HealthResponse struct {
-	Status    string    `json:"status"`
-	Timestamp time.Time `json:"timestamp"`
-	Version   string    `json:"version"`
+	Status     string    `json:"status"`
+	Timestamp  time.Time `json:"timestamp"`
+	Version    string    `json:"version"`
+	StatsError string    `json:"statsError,omitempty"`
 }
 
 // FileInfo 文件信息
 type FileInfo struct {
-	Name     string    `json:"name"`
-	Size     int64     `json:"size"`
-	Hash     string    `json:"hash"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// Hash 文件列表仅在 withHash=true 时返回
+	Hash     string    `json:"hash,omitempty"`
 	Modified time.Time `json:"modified"`
+	// OriginalName 上传时的原始文件名，仅在规范化后发生变化时返回
+	OriginalName string `json:"originalName,omitempty"`
+	// PatchFrom 上传时开始在后台生成的差分补丁的起始版本
+	PatchFrom  string `json:"patchFrom,omitempty"`
+	PatchError string `json:"patchError,omitempty"`
+	// Staged 文件处于暂存状态，发布前不对外提供下载
+	Staged    bool       `json:"staged,omitempty"`
+	PublishAt *time.Time `json:"publishAt,omitempty"`
 }
 
 func main() {
+	flag.Parse()
+	applyPathOverrides()
+
 	// 创建必要的目录
 	createDirectories()
-
-	// 加载统计数据
-	loadStatistics()
+	validateDirectories()
+
+	// 加载配置与统计数据
+	loadConfig()
+	applyTLSOverrides()
+	applyBaseURLOverride()
+	loadUsers()
+	setupLogFile()
+	setupLogFormat()
+	openStatsStore()
+	loadHashCache()
+	loadSigningKeys()
+	loadWebAuthnCredentials()
+	updateStorageStats()
+	migrateManifests()
+	sweepUploadTempFiles(time.Now(), 0)
+	go flushStatisticsLoop()
+	go reconcileStorageLoop()
+	go schedulerLoop()
+	go activityCompactionLoop()
+	go integrityCheckLoop()
+	go uploadSessionReaperLoop()
 
 	// 注册路由
 	// 公开端点
 	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/ready", readyHandler)
+	http.Handle("/metrics", promhttp.Handler())
 	http.HandleFunc("/manifest-stable.json", manifestHandler("stable"))
 	http.HandleFunc("/manifest-beta.json", manifestHandler("beta"))
 	http.HandleFunc("/manifest-dev.json", manifestHandler("dev"))
+	for _, channel := range Channels {
+		http.HandleFunc("/latest-"+channel+".json", latestHandler(channel))
+	}
 	http.HandleFunc("/downloads/", downloadHandler)
+	http.HandleFunc("/patches/", patchHandler)
 	http.HandleFunc("/changelog/", changelogHandler)
 	http.HandleFunc("/mods/", modHandler)
+	http.HandleFunc("/api/heartbeat", heartbeatHandler)
+	http.HandleFunc("/api/client-config", clientConfigHandler)
+	http.HandleFunc("/api/check", checkHandler)
+	http.HandleFunc("/api/resolve", resolveHandler)
+	http.HandleFunc("/api/limits", limitsHandler)
+	http.HandleFunc("/api/changelog/diff", changelogDiffHandler)
+	http.HandleFunc("/api/latest-multi", latestMultiHandler)
+	http.HandleFunc("/pubkey", pubkeyHandler)
 
 	// 管理面板（需要认证）
-	http.HandleFunc("/admin", basicAuth(panelHandler))
-	http.HandleFunc("/admin/", basicAuth(servePanel))
-
-	// API端点（需要认证）
-	http.HandleFunc("/api/upload", basicAuth(uploadHandler))
-	http.HandleFunc("/api/manifests", basicAuth(manifestsAPIHandler))
-	http.HandleFunc("/api/manifests/", basicAuth(updateManifestHandler))
-	http.HandleFunc("/api/files", basicAuth(filesListHandler))
-	http.HandleFunc("/api/files/", basicAuth(deleteFileHandler))
-	http.HandleFunc("/api/statistics", basicAuth(statisticsHandler))
-	http.HandleFunc("/api/hash", basicAuth(hashHandler))
+	http.HandleFunc("/admin", panelAuth(panelHandler))
+	http.HandleFunc("/admin/", panelAuth(servePanel))
+	http.HandleFunc("/admin/login", loginHandler)
+	http.HandleFunc("/admin/logout", logoutHandler)
+	http.HandleFunc("/admin/webauthn/", webAuthnHandler)
+	http.HandleFunc("/admin/api/", panelAPI(http.DefaultServeMux))
+
+	// API端点（需要认证，Bearer 令牌或基础认证）
+	http.HandleFunc("/api/login", apiLoginHandler)
+	http.HandleFunc("/api/upload", jwtAuth(uploadHandler))
+	http.HandleFunc("/api/upload/", jwtAuth(uploadRouteHandler))
+	http.HandleFunc("/api/manifests", jwtAuth(manifestsAPIHandler))
+	http.HandleFunc("/api/manifests/", jwtAuth(manifestRouteHandler))
+	http.HandleFunc("/api/files", jwtAuth(filesListHandler))
+	http.HandleFunc("/api/files/", jwtAuth(filesRouteHandler))
+	http.HandleFunc("/api/statistics", jwtAuth(statisticsHandler))
+	http.HandleFunc("/api/statistics/by-channel", jwtAuth(channelStatisticsHandler))
+	http.HandleFunc("/api/hash", jwtAuth(hashHandler))
+	http.HandleFunc("/api/active-clients", jwtAuth(activeClientsHandler))
+	http.HandleFunc("/api/simulate-client", jwtAuth(simulateClientHandler))
+	http.HandleFunc("/api/signing/rotate", jwtAuth(rotateSigningKeyHandler))
+	http.HandleFunc("/api/openapi.json", jwtAuth(openAPIHandler))
+	http.HandleFunc("/api/compare", jwtAuth(compareHandler))
+	http.HandleFunc("/api/verify-signed-url", jwtAuth(verifySignedURLHandler))
+	http.HandleFunc("/api/mods", jwtAuth(modsAPIHandler))
+	http.HandleFunc("/api/mods/", jwtAuth(modsAPIHandler))
+	http.HandleFunc("/api/sign", jwtAuth(signURLHandler))
+	http.HandleFunc("/api/bundle", jwtAuth(bundleHandler))
+	http.HandleFunc("/api/transfers", jwtAuth(transfersHandler))
+	http.HandleFunc("/api/jobs", jwtAuth(jobsHandler))
+	http.HandleFunc("/api/jobs/", jwtAuth(jobsHandler))
+	http.HandleFunc("/api/integrity", jwtAuth(integrityHandler))
+	http.HandleFunc("/api/activities", jwtAuth(activitiesHandler))
+	http.HandleFunc("/api/cadence", jwtAuth(cadenceHandler))
+	http.HandleFunc("/api/rollback", jwtAuth(rollbackHandler))
+	http.HandleFunc("/api/diagnostics", jwtAuth(diagnosticsHandler))
+	http.HandleFunc("/api/logs/trace", jwtAuth(logTraceHandler))
+
+	// 未知的API路由返回JSON 404
+	http.HandleFunc("/api/", apiNotFoundHandler)
 
 	// 启动服务器
 	addr := ":" + Port
@@ -129,29 +259,79 @@ func main() {
 	log.Printf("   LizardClient Update Server v2.0")
 	log.Printf("==============================================")
 	log.Printf("")
-	log.Printf("Server starting on http://localhost:%s", Port)
+	log.Printf("Server starting on %s://localhost:%s", serverScheme(), Port)
+	if config.BaseURL != "" {
+		log.Printf("Public base URL: %s", config.BaseURL)
+	}
+	if mtlsEnabled() {
+		log.Printf("Mutual TLS enabled: admin API requires client certificates")
+	}
 	log.Printf("")
 	log.Printf("Public Endpoints:")
 	log.Printf("  - GET  /health                    服务器健康检查")
+	log.Printf("  - GET  /ready                     就绪检查")
+	log.Printf("  - GET  /metrics                   Prometheus 指标")
 	log.Printf("  - GET  /manifest-{channel}.json   获取更新清单")
+	log.Printf("  - GET  /latest-{channel}.json     获取最新版本")
 	log.Printf("  - GET  /downloads/<filename>      下载更新文件")
+	log.Printf("  - GET  /patches/{channel}/{version}?from=  下载差分补丁")
+	log.Printf("  - GET  /downloads/SHA256SUMS      文件校验和列表")
+	log.Printf("  - POST /api/heartbeat             客户端心跳")
+	log.Printf("  - GET  /api/client-config         客户端引导配置")
+	log.Printf("  - GET  /api/check                 轻量更新检查")
+	log.Printf("  - GET  /api/resolve               解析版本依赖")
+	log.Printf("  - GET  /api/limits                服务器限制与配额")
+	log.Printf("  - GET  /api/changelog/diff        两个版本之间的更新日志")
+	log.Printf("  - GET  /api/latest-multi          多个频道的最新版本")
+	log.Printf("  - GET  /pubkey                    签名公钥")
 	log.Printf("")
 	log.Printf("Admin Panel:")
 	log.Printf("  - GET  /admin                     管理面板")
-	log.Printf("  - Username: %s", AdminUsername)
+	if _, ok := lookupUser(AdminUsername); ok {
+		log.Printf("  - Username: %s", AdminUsername)
+	}
 	log.Printf("")
 	log.Printf("API Endpoints (需要认证):")
+	log.Printf("  - POST /api/login                 签发JWT令牌（无需认证）")
 	log.Printf("  - POST /api/upload                上传文件")
+	log.Printf("  - GET  /api/upload/sessions       进行中的分块上传")
+	log.Printf("  - POST /api/upload/{id}/complete  完成分块上传")
 	log.Printf("  - GET  /api/manifests             获取所有清单")
 	log.Printf("  - PUT  /api/manifests/{channel}   更新清单")
+	log.Printf("  - POST /api/manifests/{channel}/query  查询指定版本")
+	log.Printf("  - GET  /api/manifests/{channel}/effective  预览客户端收到的清单")
+	log.Printf("  - POST /api/manifests/{channel}/updates  追加单个版本")
+	log.Printf("  - DELETE /api/manifests/{channel}/updates/{version}  删除版本")
+	log.Printf("  - POST /api/manifests/{channel}/updates/{version}/yank  撤回版本")
+	log.Printf("  - POST /api/manifests/{channel}/force-redownload  强制重新下载")
+	log.Printf("  - POST /api/rollback              将频道回滚到旧版本")
 	log.Printf("  - GET  /api/files                 文件列表")
 	log.Printf("  - DEL  /api/files/{filename}      删除文件")
+	log.Printf("  - POST /api/files/{filename}/publish 发布暂存文件")
 	log.Printf("  - GET  /api/statistics            统计数据")
+	log.Printf("  - GET  /api/statistics/by-channel 按频道统计")
+	log.Printf("  - GET  /api/active-clients        活跃客户端")
+	log.Printf("  - GET  /api/simulate-client       模拟客户端更新检查")
+	log.Printf("  - POST /api/signing/rotate        轮换签名密钥")
+	log.Printf("  - GET  /api/openapi.json          API描述")
+	log.Printf("  - POST /api/compare               比较两个文件的哈希")
+	log.Printf("  - POST /api/verify-signed-url     校验签名下载链接")
+	log.Printf("  - GET  /api/mods                  模组列表")
+	log.Printf("  - POST /api/mods/{id}             上传模组版本")
+	log.Printf("  - POST /api/sign                  签发限时下载链接")
+	log.Printf("  - GET  /api/bundle                离线安装包")
+	log.Printf("  - GET  /api/transfers             正在进行的下载")
+	log.Printf("  - GET  /api/jobs                  后台任务状态")
+	log.Printf("  - GET  /api/integrity             文件完整性检查结果")
+	log.Printf("  - GET  /api/activities            查询活动日志")
+	log.Printf("  - GET  /api/cadence               频道发布节奏")
+	log.Printf("  - GET  /api/diagnostics           运行诊断信息")
+	log.Printf("  - GET  /api/logs/trace            按请求ID检索日志")
 	log.Printf("")
 	log.Printf("==============================================")
 	log.Printf("")
 
-	if err := http.ListenAndServe(addr, logMiddleware(http.DefaultServeMux)); err != nil {
+	if err := listenAndServe(addr, requestIDMiddleware(logMiddleware(corsMiddleware(rateLimitMiddleware(gzipMiddleware(cacheControlMiddleware(http.DefaultServeMux))))))); err != nil {
 		log.Fatalf("Server failed to start: %v", err)
 	}
 }
@@ -165,11 +345,33 @@ func createDirectories() {
 		}
 	}
 	log.Printf("Directories initialized: %v", dirs)
+
+	removeStaleTempFiles(append([]string{".", ManifestsDir, filepath.Dir(SigningKeysFile)}, channelHistoryDirs()...)...)
 }
 
 // basicAuth HTTP基础认证中间件
+// 已登录面板的会话Cookie同样有效，便于面板调用管理API
 func basicAuth(handler http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// 启用双向TLS后管理API只接受客户端证书
+		if mtlsEnabled() && isAPIPath(r.URL.Path) {
+			mtlsAuth(w, r, handler)
+			return
+		}
+
+		// 经 /admin/api/ 转发的请求已由 panelAPI 校验会话；
+		// 会话Cookie只在面板路径下有效，/api/ 路由需使用基础认证、JWT 或客户端证书
+		if _, ok := currentUser(r); ok {
+			handler(w, r)
+			return
+		}
+		if isPanelPath(r.URL.Path) {
+			if user, ok := sessionUser(r); ok {
+				handler(w, withAdminUser(r, user))
+				return
+			}
+		}
+
 		username, password, ok := r.BasicAuth()
 		if !ok {
 			w.Header().Set("WWW-Authenticate", `Basic realm="Admin Panel"`)
@@ -177,29 +379,128 @@ func basicAuth(handler http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
-		// 使用constant-time比较防止时序攻击
-		usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(AdminUsername)) == 1
-		passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(AdminPassword)) == 1
-
-		if !usernameMatch || !passwordMatch {
+		user, ok := checkAdminCredentials(username, password)
+		if !ok {
 			w.Header().Set("WWW-Authenticate", `Basic realm="Admin Panel"`)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
+		rememberAdminBasicAuth(username, password)
+
+		handler(w, withAdminUser(r, user))
+	}
+}
 
-		handler(w, r)
+// panelAuth 管理面板认证：已有会话直接放行；未携带凭据的浏览器跳转到登录页，
+// 携带基础认证头时按基础认证校验
+func panelAuth(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/admin/style.css" {
+			handler(w, r)
+			return
+		}
+		if user, ok := sessionUser(r); ok {
+			handler(w, withAdminUser(r, user))
+			return
+		}
+		if r.Header.Get("Authorization") == "" {
+			http.Redirect(w, r, "/admin/login", http.StatusFound)
+			return
+		}
+		basicAuth(handler)(w, r)
 	}
 }
 
+// loginPageHandler 管理面板登录页
+func loginPageHandler(w http.ResponseWriter, r *http.Request) {
+	servePanelFile(w, r, "login.html")
+}
+
 // logMiddleware 日志中间件
 func logMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
-		log.Printf("%s %s %s", r.Method, r.RequestURI, time.Since(start))
+		cw := &countingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(cw, r)
+
+		// 字节数只在包装器中统计，处理器自行流式输出时不会重复计数
+		statsStore.AddBytesServed(cw.bytes)
+		observeRequest(r, cw.statusCode(), cw.bytes, time.Since(start))
+
+		if jsonAccessLog != nil {
+			writeJSONAccessLog(r, cw.statusCode(), cw.bytes, time.Since(start))
+			return
+		}
+		if config.LogExtendedFields {
+			log.Printf("%s %s %d %s %d bytes client=%s rid=%s", r.Method, r.RequestURI, cw.statusCode(), time.Since(start), cw.bytes, clientVersion(r.UserAgent()), requestID(r))
+			return
+		}
+		log.Printf("%s %s %d %s %d bytes rid=%s", r.Method, r.RequestURI, cw.statusCode(), time.Since(start), cw.bytes, requestID(r))
 	})
 }
 
+// countingResponseWriter 记录响应状态码与字节数的ResponseWriter包装
+type countingResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader 记录最终状态码；1xx 信息响应之后还会有最终响应，不记录
+func (w *countingResponseWriter) WriteHeader(status int) {
+	if w.status == 0 && status >= http.StatusOK {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *countingResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// statusCode 返回响应状态码；处理器未写出任何内容时 net/http 默认返回 200
+func (w *countingResponseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Flush 透传流式刷新
+func (w *countingResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问底层ResponseWriter
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// clientVersion 从User-Agent中解析客户端版本，如 "LizardClient/1.2.0 (Windows)"
+func clientVersion(userAgent string) string {
+	const prefix = "LizardClient/"
+	idx := strings.Index(userAgent, prefix)
+	if idx < 0 {
+		return "-"
+	}
+
+	version := userAgent[idx+len(prefix):]
+	if end := strings.IndexAny(version, " ;()"); end >= 0 {
+		version = version[:end]
+	}
+	if version == "" {
+		return "-"
+	}
+	return version
+}
+
 // healthHandler 健康检查处理器
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	response := HealthResponse{
@@ -208,6 +509,12 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 		Version:   "2.0.0",
 	}
 
+	// 统计数据持续写入失败时报告降级
+	if err := statsStore.Health(); err != nil {
+		response.Status = "degraded"
+		response.StatsError = err.Error()
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
@@ -220,29 +527,42 @@ func manifestHandler(channel string) http.HandlerFunc {
 		// 如果清单文件不存在，创建默认清单
 		if _, err := os.Stat(manifestPath); os.IsNotExist(err) {
 			log.Printf("Manifest not found, creating default: %s", manifestPath)
-			createDefaultManifest(manifestPath, channel)
+			createDefaultManifest(manifestPath, channel, persistentBaseURL())
 		}
 
-		// 读取清单文件
-		data, err := os.ReadFile(manifestPath)
+		// 携带 platform/clientId 的客户端收到过滤后的清单
+		if filters := manifestFiltersFromQuery(r); hasManifestFilters(filters) {
+			writeFilteredManifest(w, r, channel, filters)
+			return
+		}
+
+		// 需要改写地址时解析后重新编码，否则原样返回清单文件
+		var data []byte
+		var err error
+		if base, ok := servedManifestBaseURL(r); ok {
+			var manifest *UpdateManifest
+			if manifest, err = loadManifest(channel); err == nil {
+				rewriteManifestURLs(manifest, base)
+				data, err = json.MarshalIndent(manifest, "", "  ")
+			}
+		} else {
+			data, err = readManifestData(channel)
+		}
 		if err != nil {
 			http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
 			log.Printf("Error reading manifest: %v", err)
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Write(data)
+		writeWithETag(w, r, "application/json", data)
 	}
 }
 
-// createDefaultManifest 创建默认清单
-func createDefaultManifest(path string, channel string) {
-	serverUrl := fmt.Sprintf("http://localhost:%s", Port)
+// createDefaultManifest 创建默认清单，地址以 serverUrl 为前缀
+func createDefaultManifest(path string, channel string, serverUrl string) {
 
 	manifest := UpdateManifest{
-		ManifestVersion: "1.0.0",
+		ManifestVersion: CurrentManifestVersion,
 		LatestVersion:   "1.0.0",
 		MinimumVersion:  "1.0.0",
 		Channel:         channel,
@@ -271,20 +591,40 @@ func createDefaultManifest(path string, channel string) {
 		return
 	}
 
-	if err := os.WriteFile(path, data, 0644); err != nil {
+	if err := atomicWriteFile(path, data, 0644); err != nil {
 		log.Printf("Error writing default manifest: %v", err)
 	}
 }
 
 // downloadHandler 下载处理器
 func downloadHandler(w http.ResponseWriter, r *http.Request) {
-	filename := filepath.Base(r.URL.Path)
-	if filename == "downloads" || filename == "" {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requested := strings.TrimPrefix(r.URL.Path, "/downloads/")
+	if requested == "" || requested == r.URL.Path {
 		http.Error(w, "Filename required", http.StatusBadRequest)
 		return
 	}
 
-	filePath := filepath.Join(DownloadsDir, filename)
+	// 下载目录是扁平的，只接受 downloads 下的直接文件
+	filePath, err := safeJoin(DownloadsDir, requested)
+	if err != nil || filepath.Dir(filePath) != filepath.Clean(DownloadsDir) {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		log.Printf("Rejected download path: %q", requested)
+		return
+	}
+	filename := filepath.Base(filePath)
+
+	if filename == ChecksumsFilename {
+		checksumsHandler(w, r)
+		return
+	}
+	if !authorizeDownload(w, r) {
+		return
+	}
 
 	fileInfo, err := os.Stat(filePath)
 	if os.IsNotExist(err) {
@@ -293,6 +633,12 @@ func downloadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 暂存中的文件在发布前对外不可见
+	if _, staged := stagedFile(filename); staged {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		http.Error(w, "Failed to open file", http.StatusInternalServerError)
@@ -301,40 +647,101 @@ func downloadHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer file.Close()
 
-	// 更新统计
-	stats.FileDownloads[filename]++
-	stats.TotalDownloads++
-	addActivity("download", fmt.Sprintf("Downloaded: %s", filename))
-	saveStatistics()
+	rule := contentTypeFor(filename)
+	w.Header().Set("Content-Type", rule.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%s", rule.Disposition, filename))
+	w.Header().Set("ETag", downloadETag(fileInfo))
+	if config.DownloadLinkHeaders {
+		w.Header().Set("Link", downloadLinkHeader(filename))
+	}
+
+	// HEAD 供客户端下载前探测大小、类型与哈希，不占用下载连接、不受冷却限制、不计入下载
+	if r.Method == http.MethodHead {
+		if hash, err := cachedFileHash(filePath); err == nil {
+			w.Header().Set(FileHashHeader, hash)
+		} else {
+			log.Printf("Error hashing %s: %v", filename, err)
+		}
+		http.ServeContent(w, r, filename, fileInfo.ModTime(), file)
+		return
+	}
+
+	// 限制单个客户端IP的并发下载连接，传输结束或客户端断开时释放；X-Client-Id 由客户端自报，不能用来绕过上限
+	ip := clientIP(r)
+	if !acquireDownloadSlot(ip) {
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "Too many concurrent downloads", http.StatusTooManyRequests)
+		return
+	}
+	defer releaseDownloadSlot(ip)
+
+	// 冷却期内拒绝同一IP重复的完整下载，断点续传不受限制
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader == "" {
+		if wait := downloadCooldownRemaining(ip, filename); wait > 0 {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(wait.Seconds())+1))
+			http.Error(w, "Download cooldown in effect", http.StatusTooManyRequests)
+			return
+		}
+	}
 
-	w.Header().Set("Content-Type", "application/zip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", fileInfo.Size()))
-	w.Header().Set("Accept-Ranges", "bytes")
+	// 完整下载与 Range、If-Range 断点续传统一由 ServeContent 处理，
+	// Content-Length、Content-Range、Accept-Ranges 与 304/416 响应均与实际送出的内容一致
+	transfer := startTransfer(r, filename, fileInfo.Size())
+	defer endTransfer(transfer)
+	cw := &countingResponseWriter{ResponseWriter: &progressWriter{ResponseWriter: w, transfer: transfer}}
+	defer func() { recordFileTransfer(filename, ip, cw.bytes) }()
+	http.ServeContent(cw, r, filename, fileInfo.ModTime(), file)
 
-	if r.Header.Get("Range") != "" {
-		http.ServeFile(w, r, filePath)
+	if cw.status != http.StatusOK && cw.status != http.StatusPartialContent {
 		return
 	}
+	// 送达文件末尾的传输（含断点续传补完）开始下载冷却
+	if transferCompletesFile(rangeHeader, fileInfo.Size(), cw.bytes) {
+		markDownloadCompleted(ip, filename)
+	} else if cw.status == http.StatusOK {
+		log.Printf("Download interrupted: %s (%d/%d bytes) rid=%s", filename, cw.bytes, fileInfo.Size(), requestID(r))
+		metricDownloadsInterrupted.WithLabelValues(filename).Inc()
+	}
 
-	io.Copy(w, file)
-	log.Printf("File downloaded: %s (%d bytes)", filename, fileInfo.Size())
+	// 同一会话（或客户端当天）的各次传输合计送达整个文件时才计为下载：中断后续传补完的计一次，只取部分内容的探测不计入
+	if isAdminDownload(r) {
+		log.Printf("Admin download not counted: %s rid=%s", filename, requestID(r))
+		return
+	}
+	key := downloadCountKey(r, filename)
+	if !deliveryCompletesFile(key, downloadETag(fileInfo), rangeHeader, cw.status, fileInfo.Size(), cw.bytes) {
+		return
+	}
+	recordDownload(filename, key)
+	log.Printf("File downloaded: %s (%d bytes) rid=%s", filename, fileInfo.Size(), requestID(r))
 }
 
 // changelogHandler 更新日志处理器
 func changelogHandler(w http.ResponseWriter, r *http.Request) {
-	filename := filepath.Base(r.URL.Path)
-	if filename == "changelog" || filename == "" {
+	filename := strings.TrimPrefix(r.URL.Path, "/changelog/")
+	if filename == "" || filename == r.URL.Path {
 		http.Error(w, "Version required", http.StatusBadRequest)
 		return
 	}
 
-	changelogPath := filepath.Join(ChangelogsDir, filename)
+	changelogPath, err := safeJoin(ChangelogsDir, filename)
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		log.Printf("Rejected changelog path: %q", filename)
+		return
+	}
 
 	if _, err := os.Stat(changelogPath); os.IsNotExist(err) {
+		// 没有单独的文件时使用清单中该版本的 changelog 字段
+		version := strings.TrimSuffix(filename, ".md")
+		if changelog, ok := manifestChangelog(version); ok {
+			rendered := fmt.Sprintf("# Version %s\n\n%s\n", version, strings.TrimRight(changelog, "\n"))
+			writeWithETag(w, r, "text/markdown; charset=utf-8", []byte(rendered))
+			return
+		}
 		defaultChangelog := fmt.Sprintf("# Version %s\n\nNo changelog available.\n", filename)
-		w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
-		w.Write([]byte(defaultChangelog))
+		writeWithETag(w, r, "text/markdown; charset=utf-8", []byte(defaultChangelog))
 		return
 	}
 
@@ -345,8 +752,7 @@ func changelogHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "text/markdown; charset=utf-8")
-	w.Write(data)
+	writeWithETag(w, r, "text/markdown; charset=utf-8", data)
 }
 
 // modHandler 模组信息处理器
@@ -354,20 +760,52 @@ func modHandler(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path[len("/mods/"):]
 	parts := strings.Split(path, "/")
 
-	if len(parts) < 2 {
+	if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
 		http.Error(w, "Invalid mod URL", http.StatusBadRequest)
 		return
 	}
+	// /mods/{id}/{version}/{filename} 为通过 /api/mods 上传的模组文件
+	if len(parts) == 3 {
+		serveModFile(w, r, parts[0], parts[1], parts[2])
+		return
+	}
 
 	modId := parts[0]
-	modInfoPath := filepath.Join(DownloadsDir, "mods", modId, "latest.json")
+	modDir, err := safeJoin(filepath.Join(DownloadsDir, "mods"), modId)
+	if err != nil {
+		http.Error(w, "Invalid mod ID", http.StatusBadRequest)
+		log.Printf("Rejected mod path: %q", modId)
+		return
+	}
+	modInfoPath := filepath.Join(modDir, "latest.json")
 
 	if _, err := os.Stat(modInfoPath); os.IsNotExist(err) {
-		http.Error(w, "Mod not found", http.StatusNotFound)
+		var suggestions []string
+		if config.ModSuggestionLimit > 0 {
+			suggestions = suggestModIDs(modId, availableModIDs(), config.ModSuggestionLimit)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(ModNotFoundResponse{
+			Error:       "Mod not found",
+			ModID:       modId,
+			Suggestions: append([]string{}, suggestions...),
+		})
 		log.Printf("Mod not found: %s", modId)
 		return
 	}
 
+	// /mods/{id}/versions 列出全部版本，/mods/{id}/{version} 为指定版本的 info.json
+	switch parts[1] {
+	case "latest.json":
+	case "versions":
+		modVersionsHandler(w, r, modId)
+		return
+	default:
+		modVersionHandler(w, r, modId, parts[1])
+		return
+	}
+
 	data, err := os.ReadFile(modInfoPath)
 	if err != nil {
 		http.Error(w, "Failed to read mod info", http.StatusInternalServerError)
@@ -375,26 +813,17 @@ func modHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Write(data)
+	writeWithETag(w, r, "application/json", data)
 }
 
 // panelHandler 管理面板主页
 func panelHandler(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, filepath.Join(PanelDir, "index.html"))
+	servePanelFile(w, r, "index.html")
 }
 
 // servePanel 提供面板静态文件
 func servePanel(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Path[len("/admin/"):]
-	if path == "" {
-		http.ServeFile(w, r, filepath.Join(PanelDir, "index.html"))
-		return
-	}
-
-	filePath := filepath.Join(PanelDir, path)
-	http.ServeFile(w, r, filePath)
+	servePanelFile(w, r, r.URL.Path[len("/admin/"):])
 }
 
 // uploadHandler 文件上传处理器
@@ -404,41 +833,109 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 解析multipart表单（最大32MB）
-	if err := r.ParseMultipartForm(32 << 20); err != nil {
-		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+	if !requireContentType(w, r, "multipart/form-data") {
 		return
 	}
 
-	file, header, err := r.FormFile("file")
-	if err != nil {
-		http.Error(w, "Failed to get file", http.StatusBadRequest)
+	// 表单额外开销按1MB计，超出 maxUploadSize 的请求体直接中断
+	if config.MaxUploadSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, config.MaxUploadSize+1<<20)
+	}
+
+	// 文件部分直接流式写入临时文件，处理结束时未移入下载目录的临时文件都会被删除
+	upload, ok := receiveUpload(w, r)
+	if !ok {
 		return
 	}
-	defer file.Close()
+	defer upload.discard()
 
-	// 创建目标文件
-	filename := header.Filename
+	publishUpload(w, upload)
+}
+
+// publishUpload 将已完整接收的文件移入下载目录：校验 expectedHash、暂存、扫描，更新哈希缓存与存储统计，
+// 按 channel 字段生成差分补丁并写入响应；同名文件已存在时除非 overwrite 字段为 true，否则返回 409；
+// multipart 上传与分块上传会话的完成请求共用
+func publishUpload(w http.ResponseWriter, upload *receivedUpload) {
+	filename := upload.Filename
+	size := upload.Size
+
+	// 提供 expectedHash 时校验，不一致说明传输中被截断或损坏，临时文件不会移入下载目录
+	if expected := strings.ToLower(strings.TrimSpace(upload.Fields["expectedHash"])); expected != "" && expected != upload.Hash {
+		log.Printf("Upload hash mismatch: %s (expected %s, got %s)", filename, expected, upload.Hash)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":    "Uploaded file does not match expectedHash",
+			"expected": expected,
+			"actual":   upload.Hash,
+		})
+		return
+	}
 	destPath := filepath.Join(DownloadsDir, filename)
 
-	dest, err := os.Create(destPath)
-	if err != nil {
-		http.Error(w, "Failed to create file", http.StatusInternalServerError)
+	// 覆盖已有文件时按差值调整存储统计
+	var previousSize int64 = -1
+	if info, err := os.Stat(destPath); err == nil {
+		previousSize = info.Size()
+	}
+
+	// 已发布的文件只能显式覆盖，避免同名上传误替换线上版本；按 immutable 缓存的文件名不允许覆盖
+	overwrite := upload.Fields["overwrite"] == "true" && !immutableDownloadName(filename)
+	if previousSize >= 0 && !overwrite {
+		writeFileExistsConflict(w, filename)
 		return
 	}
-	defer dest.Close()
 
-	// 复制文件并计算哈希
-	hash := sha256.New()
-	writer := io.MultiWriter(dest, hash)
+	if !withinStorageQuota(size - max(previousSize, 0)) {
+		http.Error(w, "Storage quota exceeded", http.StatusInsufficientStorage)
+		return
+	}
 
-	size, err := io.Copy(writer, file)
-	if err != nil {
+	// 扫描仍在上传暂存目录中的临时文件，通过后才移入下载目录，未通过时已发布的同名文件保持不变
+	if result, ok := scanBeforePublish(w, upload.TempPath, filename); !ok {
+		addActivity("quarantine", fmt.Sprintf("Quarantined: %s (%s)", filename, result))
+		return
+	}
+
+	// 移入下载目录前先置为暂存，发布前文件不会被下载；移入失败时恢复原来的暂存状态
+	var staged *StagedFile
+	previousStaged, wasStaged := stagedFile(filename)
+	if config.UploadStaging.Enabled {
+		entry, err := stageFile(filename)
+		if err != nil {
+			http.Error(w, "Failed to stage file", http.StatusInternalServerError)
+			log.Printf("Error staging %s: %v", filename, err)
+			return
+		}
+		staged = &entry
+	}
+
+	// 未要求覆盖时以独占方式移入，检查之后并发上传的同名文件不会被替换
+	move := moveFile
+	if !overwrite {
+		move = moveFileExclusive
+	}
+	if err := move(upload.TempPath, destPath); err != nil {
+		if staged != nil {
+			restoreStagedFile(filename, previousStaged, wasStaged)
+		}
+		if errors.Is(err, fs.ErrExist) {
+			writeFileExistsConflict(w, filename)
+			return
+		}
 		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		log.Printf("Error moving upload %s into place: %v", filename, err)
 		return
 	}
 
-	hashString := hex.EncodeToString(hash.Sum(nil))
+	// 上传时已边接收边计算哈希，直接写入缓存
+	hashString := upload.Hash
+	if info, err := os.Stat(destPath); err == nil {
+		storeFileHash(destPath, info, hashString)
+	} else {
+		invalidateFileHash(destPath)
+	}
+	invalidateChecksums()
 
 	// 返回文件信息
 	response := FileInfo{
@@ -447,9 +944,34 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 		Hash:     hashString,
 		Modified: time.Now(),
 	}
+	if filename != upload.OriginalName {
+		response.OriginalName = upload.OriginalName
+	}
+	if staged != nil {
+		response.Staged = true
+		response.PublishAt = staged.PublishAt
+	}
 
-	addActivity("upload", fmt.Sprintf("Uploaded: %s (%d bytes)", filename, size))
-	updateStorageStats()
+	// 指定频道时在后台生成从该频道当前版本（或 patchFrom 版本）出发的差分补丁
+	if channel := upload.Fields["channel"]; channel != "" {
+		if !isValidChannel(channel) {
+			response.PatchError = "Invalid channel"
+		} else if from, err := startPatchGeneration(channel, upload.Fields["patchFrom"], filename); err != nil {
+			response.PatchError = err.Error()
+		} else {
+			response.PatchFrom = from
+		}
+	}
+
+	if previousSize >= 0 {
+		adjustStorageStats(size-previousSize, 0)
+		addActivity("upload", fmt.Sprintf("Uploaded: %s (%d bytes, replaced previous %d bytes)", filename, size, previousSize))
+		log.Printf("Replaced existing file: %s (%d bytes -> %d bytes)", filename, previousSize, size)
+	} else {
+		adjustStorageStats(size, 1)
+		addActivity("upload", fmt.Sprintf("Uploaded: %s (%d bytes)", filename, size))
+	}
+	observeUpload("release")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
@@ -457,6 +979,15 @@ func uploadHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("File uploaded: %s (%d bytes, hash: %s)", filename, size, hashString)
 }
 
+// writeFileExistsConflict 同名文件已存在时返回 409；按 immutable 缓存的文件名即使带 overwrite 也不能覆盖
+func writeFileExistsConflict(w http.ResponseWriter, filename string) {
+	if immutableDownloadName(filename) {
+		writeJSONError(w, http.StatusConflict, fmt.Sprintf("File already exists: %s (versioned files are cached as immutable and cannot be overwritten, upload under a new name)", filename))
+		return
+	}
+	writeJSONError(w, http.StatusConflict, fmt.Sprintf("File already exists: %s (set overwrite=true to replace it)", filename))
+}
+
 // manifestsAPIHandler 获取所有清单
 func manifestsAPIHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -465,15 +996,9 @@ func manifestsAPIHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	manifests := make(map[string]interface{})
-	channels := []string{"stable", "beta", "dev"}
-
-	for _, channel := range channels {
-		manifestPath := filepath.Join(ManifestsDir, fmt.Sprintf("manifest-%s.json", channel))
-		if data, err := os.ReadFile(manifestPath); err == nil {
-			var manifest UpdateManifest
-			if err := json.Unmarshal(data, &manifest); err == nil {
-				manifests[channel] = manifest
-			}
+	for _, channel := range Channels {
+		if manifest, err := loadManifest(channel); err == nil {
+			manifests[channel] = manifest
 		}
 	}
 
@@ -489,28 +1014,36 @@ func updateManifestHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	channel := filepath.Base(r.URL.Path)
-	if channel != "stable" && channel != "beta" && channel != "dev" {
+	if !isValidChannel(channel) {
 		http.Error(w, "Invalid channel", http.StatusBadRequest)
 		return
 	}
 
 	var manifest UpdateManifest
+	if !requireJSON(w, r) {
+		return
+	}
 	if err := json.NewDecoder(r.Body).Decode(&manifest); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
-
-	manifest.LastUpdated = time.Now()
-	manifestPath := filepath.Join(ManifestsDir, fmt.Sprintf("manifest-%s.json", channel))
-
-	data, err := json.MarshalIndent(manifest, "", "  ")
-	if err != nil {
-		http.Error(w, "Failed to encode manifest", http.StatusInternalServerError)
+	// 版本号格式错误会让客户端的版本比较失效，发布前逐字段校验
+	if err := validateManifestVersions(&manifest); err != nil {
+		writeVersionFieldError(w, err)
+		return
+	}
+	if !enforceCadence(w, r, channel) {
 		return
 	}
 
-	if err := os.WriteFile(manifestPath, data, 0644); err != nil {
+	err := publishManifest(channel, &manifest)
+	if errors.Is(err, errInvalidManifest) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
 		http.Error(w, "Failed to save manifest", http.StatusInternalServerError)
+		log.Printf("Error saving manifest: %v", err)
 		return
 	}
 
@@ -522,13 +1055,36 @@ func updateManifestHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("Manifest updated: %s", channel)
 }
 
-// filesListHandler 获取文件列表
+// filesListHandler 获取文件列表，支持按文件名过滤、排序和分页，哈希按需计算
+// GET /api/files?q=&sort=name|size|modified&order=asc|desc&withHash=true&limit=&offset=
 func filesListHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
+	query := r.URL.Query()
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = "modified"
+	}
+	desc, ok := fileListSorts[sortBy]
+	if !ok {
+		http.Error(w, "Invalid sort (expected name, size or modified)", http.StatusBadRequest)
+		return
+	}
+	switch query.Get("order") {
+	case "":
+	case "asc":
+		desc = false
+	case "desc":
+		desc = true
+	default:
+		http.Error(w, "Invalid order (expected asc or desc)", http.StatusBadRequest)
+		return
+	}
+	search := strings.ToLower(query.Get("q"))
+
 	files, err := os.ReadDir(DownloadsDir)
 	if err != nil {
 		http.Error(w, "Failed to read directory", http.StatusInternalServerError)
@@ -537,7 +1093,7 @@ func filesListHandler(w http.ResponseWriter, r *http.Request) {
 
 	var fileList []FileInfo
 	for _, file := range files {
-		if file.IsDir() {
+		if file.IsDir() || !strings.Contains(strings.ToLower(file.Name()), search) {
 			continue
 		}
 
@@ -546,26 +1102,47 @@ func filesListHandler(w http.ResponseWriter, r *http.Request) {
 			continue
 		}
 
-		filePath := filepath.Join(DownloadsDir, file.Name())
-		hash, _ := calculateFileHash(filePath)
-
-		fileList = append(fileList, FileInfo{
+		entry := FileInfo{
 			Name:     file.Name(),
 			Size:     info.Size(),
-			Hash:     hash,
 			Modified: info.ModTime(),
-		})
+		}
+		if staged, ok := stagedFile(file.Name()); ok {
+			entry.Staged = true
+			entry.PublishAt = staged.PublishAt
+		}
+		fileList = append(fileList, entry)
 	}
 
-	// 按修改时间降序排序
-	sort.Slice(fileList, func(i, j int) bool {
-		return fileList[i].Modified.After(fileList[j].Modified)
+	sort.SliceStable(fileList, func(i, j int) bool {
+		a, b := fileList[i], fileList[j]
+		if desc {
+			a, b = b, a
+		}
+		switch {
+		case sortBy == "size" && a.Size != b.Size:
+			return a.Size < b.Size
+		case sortBy == "modified" && !a.Modified.Equal(b.Modified):
+			return a.Modified.Before(b.Modified)
+		}
+		return a.Name < b.Name
 	})
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(fileList)
+	// 哈希只为返回的这一页计算，且优先使用缓存
+	var fill func(page []FileInfo)
+	if query.Get("withHash") == "true" {
+		fill = func(page []FileInfo) {
+			for i := range page {
+				page[i].Hash, _ = cachedFileHash(filepath.Join(DownloadsDir, page[i].Name))
+			}
+		}
+	}
+	writeListPage(w, r, fileList, fill)
 }
 
+// fileListSorts /api/files 支持的排序字段及其默认方向（true 为降序）
+var fileListSorts = map[string]bool{"name": false, "size": true, "modified": true}
+
 // deleteFileHandler 删除文件
 func deleteFileHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
@@ -573,16 +1150,48 @@ func deleteFileHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	filename := filepath.Base(r.URL.Path)
-	filePath := filepath.Join(DownloadsDir, filename)
+	parts, ok := splitSubpath(r.URL.Path, "/api/files/")
+	if !ok || len(parts) != 1 {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
 
+	filename := parts[0]
+	filePath, err := safeJoin(DownloadsDir, filename)
+	if err != nil {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+
+	// 试运行只返回影响评估；被清单引用的文件需要 force=true 才会删除
+	query := r.URL.Query()
+	dryRun := query.Get("dryRun") == "true"
+	force := query.Get("force") == "true"
+	if dryRun || !force {
+		impact := deleteImpact(filename)
+		if dryRun || len(impact.References) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			if !dryRun {
+				w.WriteHeader(http.StatusConflict)
+			}
+			json.NewEncoder(w).Encode(impact)
+			return
+		}
+	}
+
+	info, statErr := os.Stat(filePath)
 	if err := os.Remove(filePath); err != nil {
 		http.Error(w, "Failed to delete file", http.StatusInternalServerError)
 		return
 	}
+	invalidateFileHash(filePath)
+	invalidateChecksums()
 
+	if statErr == nil && !info.IsDir() {
+		adjustStorageStats(-info.Size(), -1)
+	}
+	unstageFile(filename)
 	addActivity("delete", fmt.Sprintf("Deleted: %s", filename))
-	updateStorageStats()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
@@ -597,10 +1206,9 @@ func statisticsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updateStorageStats()
-
+	// 存储统计由上传/删除增量维护，并定期全量校准，这里不再扫描目录
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
+	json.NewEncoder(w).Encode(statsStore.Snapshot())
 }
 
 // hashHandler 计算文件哈希
@@ -614,13 +1222,23 @@ func hashHandler(w http.ResponseWriter, r *http.Request) {
 		Filename string `json:"filename"`
 	}
 
+	if !requireJSON(w, r) {
+		return
+	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
 
-	filePath := filepath.Join(DownloadsDir, req.Filename)
-	hash, err := calculateFileHash(filePath)
+	// 与下载相同，只接受 downloads 下的直接文件
+	filePath, err := safeJoin(DownloadsDir, req.Filename)
+	if err != nil || filepath.Dir(filePath) != filepath.Clean(DownloadsDir) {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		log.Printf("Rejected hash path: %q", req.Filename)
+		return
+	}
+
+	hash, err := cachedFileHash(filePath)
 	if err != nil {
 		http.Error(w, "Failed to calculate hash", http.StatusInternalServerError)
 		return
@@ -646,7 +1264,7 @@ func calculateFileHash(filePath string) (string, error) {
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
-// addActivity 添加活动日志
+// addActivity 添加活动日志：先同步写入归档，再加入统计中的最近活动窗口
 func addActivity(action, details string) {
 	activity := ActivityLog{
 		Timestamp: time.Now(),
@@ -654,15 +1272,11 @@ func addActivity(action, details string) {
 		Details:   details,
 	}
 
-	stats.RecentActivities = append([]ActivityLog{activity}, stats.RecentActivities...)
-	if len(stats.RecentActivities) > 50 {
-		stats.RecentActivities = stats.RecentActivities[:50]
-	}
-
-	saveStatistics()
+	archiveActivity(activity)
+	statsStore.AddActivity(activity)
 }
 
-// updateStorageStats 更新存储统计
+// updateStorageStats 全量扫描下载目录，校准存储统计
 func updateStorageStats() {
 	var totalSize int64
 	var fileCount int
@@ -686,35 +1300,20 @@ func updateStorageStats() {
 		fileCount++
 	}
 
-	stats.StorageUsage = totalSize
-	stats.TotalFiles = fileCount
-	stats.LastUpdate = time.Now()
+	statsStore.SetStorage(totalSize, fileCount, time.Now())
 }
 
-// loadStatistics 加载统计数据
-func loadStatistics() {
-	statsPath := "./stats.json"
-	data, err := os.ReadFile(statsPath)
-	if err != nil {
-		log.Printf("No existing statistics found, starting fresh")
-		return
-	}
-
-	if err := json.Unmarshal(data, stats); err != nil {
-		log.Printf("Error loading statistics: %v", err)
-	}
+// adjustStorageStats 按增量更新存储统计，避免每次变更都扫描目录
+func adjustStorageStats(sizeDelta int64, fileDelta int) {
+	statsStore.AdjustStorage(sizeDelta, fileDelta)
 }
 
-// saveStatistics 保存统计数据
-func saveStatistics() {
-	statsPath := "./stats.json"
-	data, err := json.MarshalIndent(stats, "", "  ")
-	if err != nil {
-		log.Printf("Error encoding statistics: %v", err)
-		return
-	}
+// reconcileStorageLoop 定期全量校准存储统计，修正增量维护产生的偏差
+func reconcileStorageLoop() {
+	ticker := time.NewTicker(storageReconcileInterval)
+	defer ticker.Stop()
 
-	if err := os.WriteFile(statsPath, data, 0644); err != nil {
-		log.Printf("Error saving statistics: %v", err)
+	for range ticker.C {
+		updateStorageStats()
 	}
 }
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// manifestMu 串行化清单的读-改-写操作
+var manifestMu sync.Mutex
+
+// Channels 支持的发布频道
+var Channels = []string{"stable", "beta", "dev"}
+
+// isValidChannel 检查频道名是否受支持
+func isValidChannel(channel string) bool {
+	for _, c := range Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestPath 返回频道清单文件路径
+func manifestPath(channel string) string {
+	return filepath.Join(ManifestsDir, fmt.Sprintf("manifest-%s.json", channel))
+}
+
+// loadManifest 读取并解析频道清单；结构版本较旧时在内存中迁移，并在后台写回磁盘
+func loadManifest(channel string) (*UpdateManifest, error) {
+	data, err := os.ReadFile(manifestPath(channel))
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, migrated, err := decodeManifest(channel, data)
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		scheduleManifestRewrite(channel)
+	}
+	return manifest, nil
+}
+
+// writeManifestFile 写入清单文件，测试中替换以模拟写入失败或写坏
+var writeManifestFile = atomicWriteFile
+
+// errInvalidManifest 清单未通过发布前校验
+var errInvalidManifest = errors.New("invalid manifest")
+
+// saveManifest 以事务方式发布频道清单：
+// 规范化派生字段 → 校验 → 归档旧清单 → 原子写入 → 回读校验 → 发送通知 → 清理旧归档、刷新缓存
+// 任一步骤失败都会恢复写入前的清单并删除本次归档，频道和历史都不会留下未提交的内容。调用方需持有 manifestMu
+func saveManifest(channel string, manifest *UpdateManifest) error {
+	normalizeManifest(manifest)
+	if err := validateManifest(channel, manifest); err != nil {
+		return fmt.Errorf("%w: %v", errInvalidManifest, err)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	path := manifestPath(channel)
+	previous, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read current manifest: %w", err)
+	}
+
+	archived, err := archiveManifest(channel, previous)
+	if err != nil {
+		return fmt.Errorf("archive manifest: %w", err)
+	}
+
+	if err := writeManifestFile(path, data, 0644); err != nil {
+		discardArchive(archived)
+		return fmt.Errorf("write manifest: %w", err)
+	}
+
+	if err := verifyStoredManifest(channel, manifest.ContentHash); err != nil {
+		rollbackSave(channel, path, previous, archived)
+		return fmt.Errorf("verify manifest: %w", err)
+	}
+
+	if err := notifyManifestCommitted(channel, manifest); err != nil {
+		rollbackSave(channel, path, previous, archived)
+		return fmt.Errorf("notify manifest: %w", err)
+	}
+
+	// 清理失败不影响本次提交，下次保存时重试
+	if err := pruneManifestHistory(channel, time.Now()); err != nil {
+		log.Printf("Error pruning manifest history %s: %v", channel, err)
+	}
+	invalidateChecksums()
+	log.Printf("Manifest committed: %s (%s)", channel, manifest.ContentHash)
+	return nil
+}
+
+// publishManifest 发布管理员提交的完整清单：应用保留策略后事务写入（签名在规范化时补全）
+func publishManifest(channel string, manifest *UpdateManifest) error {
+	manifest.LastUpdated = time.Now()
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	pruned := applyRetention(channel, manifest)
+	if err := saveManifest(channel, manifest); err != nil {
+		return err
+	}
+	finishPrune(channel, pruned)
+	recordPublish(channel, manifest.LastUpdated)
+	return nil
+}
+
+// normalizeManifest 更新派生字段：写入当前结构版本、补签缺失或失效的签名、版本按从新到旧排序、补全最新版本、计算内容哈希
+func normalizeManifest(manifest *UpdateManifest) {
+	manifest.ManifestVersion = CurrentManifestVersion
+	for i := range manifest.Updates {
+		syncPrimaryAsset(&manifest.Updates[i])
+	}
+	signReleases(manifest)
+
+	sort.SliceStable(manifest.Updates, func(i, j int) bool {
+		return compareVersions(manifest.Updates[i].Version, manifest.Updates[j].Version) > 0
+	})
+
+	if manifest.LatestVersion == "" {
+		if latest := latestUpdate(manifest); latest != nil {
+			manifest.LatestVersion = latest.Version
+		}
+	}
+
+	manifest.ContentHash = manifestContentHash(manifest)
+}
+
+// manifestContentHash 计算清单内容哈希（不含 contentHash 字段本身）
+func manifestContentHash(manifest *UpdateManifest) string {
+	unhashed := *manifest
+	unhashed.ContentHash = ""
+
+	data, _ := json.Marshal(unhashed)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// verifyStoredManifest 回读已写入的清单并核对内容哈希
+func verifyStoredManifest(channel, contentHash string) error {
+	stored, err := loadManifest(channel)
+	if err != nil {
+		return err
+	}
+	if manifestContentHash(stored) != contentHash {
+		return errors.New("content hash mismatch after write")
+	}
+	return nil
+}
+
+// rollbackSave 撤销已写入的清单：恢复写入前的内容并删除本次归档
+func rollbackSave(channel, path string, previous []byte, archived string) {
+	if err := rollbackManifest(path, previous); err != nil {
+		log.Printf("Error rolling back manifest %s: %v", channel, err)
+	}
+	discardArchive(archived)
+}
+
+// discardArchive 删除未提交的保存产生的归档，path 为空时不做任何操作
+func discardArchive(path string) {
+	if path == "" {
+		return
+	}
+	if err := os.Remove(path); err != nil {
+		log.Printf("Error removing uncommitted manifest archive %s: %v", path, err)
+	}
+}
+
+// rollbackManifest 恢复写入前的清单，原先不存在时删除新文件
+func rollbackManifest(path string, previous []byte) error {
+	if previous == nil {
+		return os.Remove(path)
+	}
+	return atomicWriteFile(path, previous, 0644)
+}
+
+// findUpdate 按版本号查找更新条目
+func findUpdate(manifest *UpdateManifest, version string) *UpdateInfo {
+	for i := range manifest.Updates {
+		if manifest.Updates[i].Version == version {
+			return &manifest.Updates[i]
+		}
+	}
+	return nil
+}
+
+// latestUpdate 返回最新的未撤回版本，没有可用版本时返回nil
+func latestUpdate(manifest *UpdateManifest) *UpdateInfo {
+	var latest *UpdateInfo
+	for i := range manifest.Updates {
+		update := &manifest.Updates[i]
+		if update.Yanked {
+			continue
+		}
+		if latest == nil || compareVersions(update.Version, latest.Version) > 0 {
+			latest = update
+		}
+	}
+	return latest
+}
+
+// currentRelease 返回频道当前发布的版本
+// 优先使用清单中的 LatestVersion（允许回滚到旧版本），该版本缺失或已撤回时回退到最新的未撤回版本
+func currentRelease(manifest *UpdateManifest) *UpdateInfo {
+	if update := findUpdate(manifest, manifest.LatestVersion); update != nil && !update.Yanked {
+		return update
+	}
+	return latestUpdate(manifest)
+}
+
+// referencedFiles 返回所有频道清单引用的文件名及引用它们的频道
+func referencedFiles() map[string]map[string]bool {
+	refs := make(map[string]map[string]bool)
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+		for _, update := range manifest.Updates {
+			for _, name := range releaseFiles(update) {
+				if refs[name] == nil {
+					refs[name] = make(map[string]bool)
+				}
+				refs[name][channel] = true
+			}
+		}
+	}
+	return refs
+}
+
+// downloadFilename 从下载地址中提取文件名
+func downloadFilename(downloadUrl string) string {
+	if downloadUrl == "" {
+		return ""
+	}
+	return filepath.Base(filepath.ToSlash(downloadUrl))
+}
+
+// manifestRouteHandler 分发 /api/manifests/ 下的子路由
+// GET  /api/manifests/verify
+// PUT  /api/manifests/{channel}
+// DELETE /api/manifests/{channel}/updates/{version}
+// POST /api/manifests/{channel}/updates/{version}/yank
+// POST /api/manifests/{channel}/updates/{version}/unyank
+// GET  /api/manifests/{channel}/history[/{id}[/restore]]
+func manifestRouteHandler(w http.ResponseWriter, r *http.Request) {
+	parts, ok := splitSubpath(r.URL.Path, "/api/manifests/")
+	if !ok {
+		http.Error(w, "Invalid path", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] == "verify":
+		verifyManifestsHandler(w, r)
+	case len(parts) == 1 && parts[0] == "export":
+		exportManifestsHandler(w, r)
+	case len(parts) == 1 && parts[0] == "import":
+		importManifestsHandler(w, r)
+	case len(parts) == 1 && parts[0] == "missing-hashes":
+		missingHashesHandler(w, r)
+	case len(parts) == 1 && parts[0] == "fill-hashes":
+		fillHashesHandler(w, r)
+	case len(parts) >= 1 && parts[0] == "scheduled":
+		scheduledHandler(w, r, parts[1:])
+	case len(parts) == 1:
+		updateManifestHandler(w, r)
+	case len(parts) == 2 && parts[1] == "force-redownload":
+		forceRedownloadHandler(w, r, parts[0], "")
+	case len(parts) == 4 && parts[1] == "updates" && parts[3] == "force-redownload":
+		forceRedownloadHandler(w, r, parts[0], parts[2])
+	case len(parts) == 2 && parts[1] == "effective":
+		effectiveManifestHandler(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "query":
+		queryManifestHandler(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "schedule":
+		scheduleHandler(w, r, parts[0])
+	case len(parts) >= 2 && parts[1] == "history":
+		historyHandler(w, r, parts[0], parts[2:])
+	case len(parts) == 2 && parts[1] == "updates":
+		appendUpdateHandler(w, r, parts[0])
+	case len(parts) == 3 && parts[1] == "updates":
+		deleteUpdateHandler(w, r, parts[0], parts[2])
+	case len(parts) == 4 && parts[1] == "updates" && (parts[3] == "yank" || parts[3] == "unyank"):
+		yankHandler(w, r, parts[0], parts[2], parts[3] == "yank")
+	default:
+		apiNotFoundHandler(w, r)
+	}
+}
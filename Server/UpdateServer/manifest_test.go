@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupManifestTest 在临时目录中准备清单目录，并写入一个已提交的 stable 清单
+func setupManifestTest(t *testing.T) []byte {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	oldDir, oldConfig, oldStore, oldWrite := ManifestsDir, config, statsStore, writeManifestFile
+	t.Cleanup(func() {
+		ManifestsDir, config, statsStore, writeManifestFile = oldDir, oldConfig, oldStore, oldWrite
+	})
+	ManifestsDir = "manifests"
+	config = defaultConfig()
+	config.ActivityArchive.RotateSize = 0
+	statsStore = newJSONStatsStore("stats.json")
+	// 读取旧结构清单会在后台写回，须在恢复全局状态和工作目录之前等待写回结束
+	t.Cleanup(manifestRewrites.Wait)
+	if err := os.MkdirAll(ManifestsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := saveManifest("stable", testManifest("1.0.0")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(manifestPath("stable"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// testManifest 返回只含一个版本的 stable 清单
+func testManifest(version string) *UpdateManifest {
+	return &UpdateManifest{
+		Channel:       "stable",
+		LatestVersion: version,
+		Updates: []UpdateInfo{{
+			Version:     version,
+			DownloadUrl: "http://localhost:51000/downloads/LizardClient-" + version + ".zip",
+		}},
+	}
+}
+
+func TestSaveManifestRollsBackOnFailure(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(t *testing.T)
+		edit  func(m *UpdateManifest)
+	}{
+		{
+			name: "validate",
+			edit: func(m *UpdateManifest) { m.LatestVersion = "9.9.9" },
+		},
+		{
+			name: "archive",
+			setup: func(t *testing.T) {
+				// 历史目录位置被普通文件占用，归档无法创建目录
+				os.MkdirAll(filepath.Dir(historyDir("stable")), 0755)
+				if err := os.WriteFile(historyDir("stable"), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "write",
+			setup: func(t *testing.T) {
+				writeManifestFile = func(string, []byte, os.FileMode) error {
+					return errors.New("disk full")
+				}
+			},
+		},
+		{
+			name: "verify",
+			setup: func(t *testing.T) {
+				// 写入的内容与提交的清单不一致，回读核对失败
+				writeManifestFile = func(path string, data []byte, perm os.FileMode) error {
+					return atomicWriteFile(path, bytes.Replace(data, []byte("2.0.0"), []byte("2.0.1"), 1), perm)
+				}
+			},
+		},
+		{
+			name: "notify",
+			setup: func(t *testing.T) {
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusBadGateway)
+				}))
+				t.Cleanup(server.Close)
+				config.ManifestWebhook.URL = server.URL
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			previous := setupManifestTest(t)
+			if tt.setup != nil {
+				tt.setup(t)
+			}
+
+			manifest := testManifest("2.0.0")
+			if tt.edit != nil {
+				tt.edit(manifest)
+			}
+			if err := saveManifest("stable", manifest); err == nil {
+				t.Fatal("saveManifest succeeded, want error")
+			}
+
+			current, err := os.ReadFile(manifestPath("stable"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(current, previous) {
+				t.Errorf("manifest was not rolled back:\n%s", current)
+			}
+			// 回滚的保存不留下归档
+			if entries, _ := listManifestHistory("stable"); len(entries) != 0 {
+				t.Errorf("rolled back save left %d history entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestSaveManifestRollbackRemovesNewChannel(t *testing.T) {
+	setupManifestTest(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	config.ManifestWebhook.URL = server.URL
+
+	manifest := testManifest("1.0.0")
+	manifest.Channel = "beta"
+	if err := saveManifest("beta", manifest); err == nil {
+		t.Fatal("saveManifest succeeded, want error")
+	}
+	if _, err := os.Stat(manifestPath("beta")); !os.IsNotExist(err) {
+		t.Errorf("new channel manifest left behind after rollback: %v", err)
+	}
+}
+
+func TestSaveManifestNotifies(t *testing.T) {
+	setupManifestTest(t)
+
+	var got ManifestNotification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+	config.ManifestWebhook.URL = server.URL
+
+	manifest := testManifest("2.0.0")
+	if err := saveManifest("stable", manifest); err != nil {
+		t.Fatal(err)
+	}
+	if got.Channel != "stable" || got.LatestVersion != "2.0.0" || got.ContentHash != manifest.ContentHash {
+		t.Errorf("notification = %+v", got)
+	}
+
+	stored, err := loadManifest("stable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.LatestVersion != "2.0.0" {
+		t.Errorf("stored latestVersion = %q, want 2.0.0", stored.LatestVersion)
+	}
+}
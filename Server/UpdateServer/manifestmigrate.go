@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// CurrentManifestVersion 当前代码使用的清单结构版本，发布清单时写入 manifestVersion
+const CurrentManifestVersion = "1.1.0"
+
+// legacyManifestVersion 缺少 manifestVersion 字段的清单视为最早的结构版本
+const legacyManifestVersion = "1.0.0"
+
+// manifestMigration 将清单结构从低于 To 的版本升级到 To。迁移在解析为 UpdateManifest 之前作用于原始JSON，
+// 字段改名或结构调整时旧字段不会在解析中丢失
+type manifestMigration struct {
+	To      string
+	Migrate func(raw map[string]any, channel string)
+}
+
+// manifestMigrations 按版本升序排列；修改清单结构时在末尾追加迁移并更新 CurrentManifestVersion
+var manifestMigrations = []manifestMigration{
+	{To: "1.1.0", Migrate: migrateManifestV1_1},
+}
+
+var (
+	// manifestRewriting 正在后台写回迁移结果的频道
+	manifestRewriting = make(map[string]bool)
+	// manifestRewriteFailed 写回失败时清单文件的修改时间，文件未再变化前不重复尝试
+	manifestRewriteFailed = make(map[string]time.Time)
+	manifestRewriteMu     sync.Mutex
+	// manifestRewrites 尚未结束的后台写回，测试中等待其完成后再清理临时目录
+	manifestRewrites sync.WaitGroup
+)
+
+// migrateManifestV1_1 1.1.0 起清单记录所属频道，各版本的 dependencies 总是数组；
+// 多文件、补丁、签名等新增字段缺省即为旧行为，由 normalizeManifest 在发布时补全
+func migrateManifestV1_1(raw map[string]any, channel string) {
+	if value, _ := raw["channel"].(string); value == "" {
+		raw["channel"] = channel
+	}
+	updates, _ := raw["updates"].([]any)
+	for _, item := range updates {
+		update, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		if _, ok := update["dependencies"].([]any); !ok {
+			update["dependencies"] = []any{}
+		}
+	}
+}
+
+// manifestSchemaVersion 返回清单文件的结构版本
+func manifestSchemaVersion(data []byte) string {
+	var header struct {
+		ManifestVersion string `json:"manifestVersion"`
+	}
+	if json.Unmarshal(data, &header) != nil || header.ManifestVersion == "" {
+		return legacyManifestVersion
+	}
+	return header.ManifestVersion
+}
+
+// manifestNeedsMigration 清单结构版本是否低于当前版本
+func manifestNeedsMigration(data []byte) bool {
+	return compareVersions(manifestSchemaVersion(data), CurrentManifestVersion) < 0
+}
+
+// decodeManifest 解析清单，结构版本较旧时依次执行迁移，返回是否发生了迁移；
+// 版本高于当前代码的清单按原样解析，新增字段会被忽略
+func decodeManifest(channel string, data []byte) (*UpdateManifest, bool, error) {
+	version := manifestSchemaVersion(data)
+	if compareVersions(version, CurrentManifestVersion) > 0 {
+		log.Printf("WARNING: manifest %s has schema version %s, newer than supported %s", channel, version, CurrentManifestVersion)
+	}
+	if compareVersions(version, CurrentManifestVersion) >= 0 {
+		var manifest UpdateManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, false, err
+		}
+		return &manifest, false, nil
+	}
+
+	// 数字保留原文，避免大整数经 float64 往返丢失精度
+	var raw map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, false, err
+	}
+	if raw == nil {
+		return nil, false, errors.New("manifest is not a JSON object")
+	}
+	for _, migration := range manifestMigrations {
+		if compareVersions(version, migration.To) < 0 {
+			migration.Migrate(raw, channel)
+			version = migration.To
+		}
+	}
+	raw["manifestVersion"] = version
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	var manifest UpdateManifest
+	if err := json.Unmarshal(migrated, &manifest); err != nil {
+		return nil, false, err
+	}
+	return &manifest, true, nil
+}
+
+// readManifestData 读取频道清单原文；结构版本较旧时返回迁移后的清单
+func readManifestData(channel string) ([]byte, error) {
+	data, err := os.ReadFile(manifestPath(channel))
+	if err != nil || !manifestNeedsMigration(data) {
+		return data, err
+	}
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(manifest, "", "  ")
+}
+
+// scheduleManifestRewrite 在后台将迁移后的清单写回磁盘；读取清单的调用方可能持有 manifestMu，不能同步写入
+func scheduleManifestRewrite(channel string) {
+	manifestRewriteMu.Lock()
+	defer manifestRewriteMu.Unlock()
+	if manifestRewriting[channel] {
+		return
+	}
+	if failedAt, ok := manifestRewriteFailed[channel]; ok {
+		if info, err := os.Stat(manifestPath(channel)); err == nil && info.ModTime().Equal(failedAt) {
+			return
+		}
+	}
+	manifestRewriting[channel] = true
+	manifestRewrites.Add(1)
+	go func() {
+		defer manifestRewrites.Done()
+		rewriteMigratedManifest(channel)
+		manifestRewriteMu.Lock()
+		delete(manifestRewriting, channel)
+		manifestRewriteMu.Unlock()
+	}()
+}
+
+// rewriteMigratedManifest 重新读取清单，仍为旧结构时迁移并以发布事务写回（旧文件归档到清单历史）
+func rewriteMigratedManifest(channel string) {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	path := manifestPath(channel)
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || !manifestNeedsMigration(data) {
+		return
+	}
+	from := manifestSchemaVersion(data)
+	manifest, _, err := decodeManifest(channel, data)
+	if err == nil {
+		err = saveManifest(channel, manifest)
+	}
+	if err != nil {
+		log.Printf("Error migrating manifest %s from schema %s: %v (serving the migrated manifest from memory)", channel, from, err)
+		manifestRewriteMu.Lock()
+		manifestRewriteFailed[channel] = info.ModTime()
+		manifestRewriteMu.Unlock()
+		return
+	}
+	addActivity("manifest", fmt.Sprintf("Migrated %s manifest from schema %s to %s", channel, from, CurrentManifestVersion))
+	log.Printf("Manifest migrated: %s schema %s -> %s", channel, from, CurrentManifestVersion)
+}
+
+// migrateManifests 启动时将所有频道的旧结构清单迁移并写回
+func migrateManifests() {
+	for _, channel := range Channels {
+		rewriteMigratedManifest(channel)
+	}
+}
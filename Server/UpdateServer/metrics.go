@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// 指标注册到 client_golang 的默认注册表，/metrics 同时输出 Go 运行时与进程指标
+var (
+	metricDownloads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lizard_downloads_total",
+		Help: "Completed downloads by file and channel.",
+	}, []string{"file", "channel"})
+
+	// 与 lizard_downloads_total 对比即可得到下载失败率
+	metricDownloadsInterrupted = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lizard_downloads_interrupted_total",
+		Help: "Full-file downloads that ended before the last byte was sent.",
+	}, []string{"file"})
+
+	metricUploads = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lizard_uploads_total",
+		Help: "Completed uploads by kind (release or mod).",
+	}, []string{"kind"})
+
+	metricBytesServed = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lizard_bytes_served_total",
+		Help: "Response body bytes written to clients.",
+	})
+
+	// 下载可能持续数分钟，桶的上限高于默认值
+	metricRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lizard_http_request_duration_seconds",
+		Help:    "HTTP request durations by route, method and status code.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"route", "method", "code"})
+
+	_ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "lizard_storage_bytes",
+		Help: "Bytes stored in the downloads directory, reconciled by updateStorageStats.",
+	}, func() float64 { return float64(statsStore.StorageUsage()) })
+)
+
+// observeDownload 记录一次完整下载；文件不属于任何频道（如模组文件）时 channel 为空
+func observeDownload(filename string, attribution DownloadAttribution) {
+	channels := attribution.channels()
+	if len(channels) == 0 {
+		metricDownloads.WithLabelValues(filename, "").Inc()
+		return
+	}
+	for _, channel := range channels {
+		metricDownloads.WithLabelValues(filename, channel).Inc()
+	}
+}
+
+// observeUpload 记录一次完成的上传
+func observeUpload(kind string) {
+	metricUploads.WithLabelValues(kind).Inc()
+}
+
+// metricsRoute 返回请求匹配的路由模式，未注册的路径归为 "other"，避免标签基数随请求路径增长
+func metricsRoute(r *http.Request) string {
+	if _, pattern := http.DefaultServeMux.Handler(r); pattern != "" {
+		return pattern
+	}
+	return "other"
+}
+
+// observeRequest 记录请求耗时与送出的字节数，由 logMiddleware 调用
+func observeRequest(r *http.Request, status int, bytes int64, duration time.Duration) {
+	metricBytesServed.Add(float64(bytes))
+	metricRequestDuration.WithLabelValues(metricsRoute(r), r.Method, strconv.Itoa(status)).Observe(duration.Seconds())
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModInfo 模组的 latest.json，由 POST /api/mods/{id} 写入
+type ModInfo struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name,omitempty"`
+	Version      string    `json:"version"`
+	DownloadUrl  string    `json:"downloadUrl"`
+	FileHash     string    `json:"fileHash"`
+	FileSize     int64     `json:"fileSize"`
+	Dependencies []string  `json:"dependencies"`
+	Changelog    string    `json:"changelog,omitempty"`
+	ReleaseDate  time.Time `json:"releaseDate"`
+}
+
+// ModSummary 模组列表条目
+type ModSummary struct {
+	ModInfo
+	// Versions 已上传的全部版本，按版本号升序
+	Versions []string `json:"versions"`
+}
+
+// ModVersionList GET /mods/{id}/versions 的响应
+type ModVersionList struct {
+	ID            string `json:"id"`
+	LatestVersion string `json:"latestVersion"`
+	// Versions 各版本的 info.json，按版本号升序
+	Versions []ModInfo `json:"versions"`
+}
+
+// ModInfoFile 每个版本目录下记录该版本信息的文件，latest.json 是最新版本 info.json 的副本
+const ModInfoFile = "info.json"
+
+// modsMu 保证同一时间只有一个模组发布在检查版本与写入 latest.json
+var modsMu sync.Mutex
+
+// modsDir 模组文件目录
+func modsDir() string {
+	return filepath.Join(DownloadsDir, "mods")
+}
+
+// invalidateModIDs 新模组注册后丢弃模组ID缓存
+func invalidateModIDs() {
+	modIDCacheMu.Lock()
+	modIDCache = nil
+	modIDCacheMu.Unlock()
+}
+
+// modVersions 返回模组目录下已上传的版本，按版本号升序
+func modVersions(id string) []string {
+	entries, err := os.ReadDir(filepath.Join(modsDir(), id))
+	if err != nil {
+		return []string{}
+	}
+	versions := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			versions = append(versions, entry.Name())
+		}
+	}
+	slices.SortFunc(versions, compareVersions)
+	return versions
+}
+
+// readModInfo 读取模组某一版本的 info.json，version 为空时读取 latest.json；
+// 手动放置、只有 latest.json 的模组仍可按其中的版本号读取
+func readModInfo(id, version string) ([]byte, error) {
+	modDir, err := safeJoin(modsDir(), id)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+	latestPath := filepath.Join(modDir, "latest.json")
+	if version == "" {
+		return os.ReadFile(latestPath)
+	}
+	versionDir, err := safeJoin(modDir, version)
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+
+	data, err := os.ReadFile(filepath.Join(versionDir, ModInfoFile))
+	if !errors.Is(err, os.ErrNotExist) {
+		return data, err
+	}
+	latest, latestErr := os.ReadFile(latestPath)
+	if latestErr != nil {
+		return nil, err
+	}
+	var info ModInfo
+	if json.Unmarshal(latest, &info) == nil && info.Version == version {
+		return latest, nil
+	}
+	return nil, err
+}
+
+// loadModInfo 解析模组某一版本的信息，version 为空时为最新版本；不存在时返回 os.ErrNotExist
+func loadModInfo(id, version string) (*ModInfo, error) {
+	data, err := readModInfo(id, version)
+	if err != nil {
+		return nil, err
+	}
+	var info ModInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("parse mod %s: %w", id, err)
+	}
+	// 手动放置的旧格式 latest.json 可能没有 id 字段
+	info.ID = id
+	return &info, nil
+}
+
+// modVersionInfos 返回模组全部版本的信息，按版本号升序；没有 info.json 的版本目录被跳过
+func modVersionInfos(id string) []ModInfo {
+	infos := []ModInfo{}
+	seen := make(map[string]bool)
+	for _, version := range modVersions(id) {
+		if info, err := loadModInfo(id, version); err == nil {
+			infos = append(infos, *info)
+			seen[info.Version] = true
+		}
+	}
+	// 只有 latest.json 的模组至少列出其最新版本
+	if latest, err := loadModInfo(id, ""); err == nil && !seen[latest.Version] {
+		infos = append(infos, *latest)
+		slices.SortFunc(infos, func(a, b ModInfo) int { return compareVersions(a.Version, b.Version) })
+	}
+	return infos
+}
+
+// parseDependencyList 解析表单中逗号分隔的依赖项
+func parseDependencyList(value string) ([]string, error) {
+	deps := []string{}
+	for _, dep := range strings.Split(value, ",") {
+		dep = strings.TrimSpace(dep)
+		if dep == "" {
+			continue
+		}
+		if _, err := parseDependency(dep); err != nil {
+			return nil, fmt.Errorf("dependency %q: %v", dep, err)
+		}
+		deps = append(deps, dep)
+	}
+	return deps, nil
+}
+
+// modsAPIHandler 分发模组注册表路由
+// GET  /api/mods
+// GET  /api/mods/{id}
+// POST /api/mods/{id}  multipart: file, version, name, changelog, dependencies, expectedHash
+func modsAPIHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/api/mods" {
+		listModsHandler(w, r)
+		return
+	}
+	parts, ok := splitSubpath(r.URL.Path, "/api/mods/")
+	if !ok || len(parts) != 1 || !isValidModID(parts[0]) {
+		http.Error(w, "Invalid mod ID", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		summary, err := modSummary(parts[0])
+		if errors.Is(err, os.ErrNotExist) {
+			writeJSONError(w, http.StatusNotFound, "Mod not found")
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to read mod info", http.StatusInternalServerError)
+			log.Printf("Error reading mod %s: %v", parts[0], err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	case http.MethodPost:
+		publishModHandler(w, r, parts[0])
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// modSummary 读取模组的 latest.json 与版本列表
+func modSummary(id string) (ModSummary, error) {
+	latest, err := loadModInfo(id, "")
+	if err != nil {
+		return ModSummary{}, err
+	}
+	summary := ModSummary{ModInfo: *latest, Versions: []string{}}
+	for _, info := range modVersionInfos(id) {
+		summary.Versions = append(summary.Versions, info.Version)
+	}
+	return summary, nil
+}
+
+// modVersionsHandler 列出模组的全部版本，客户端据此固定或回退到旧版本
+// GET /mods/{id}/versions
+func modVersionsHandler(w http.ResponseWriter, r *http.Request, id string) {
+	latest, err := loadModInfo(id, "")
+	if err != nil {
+		http.Error(w, "Failed to read mod info", http.StatusInternalServerError)
+		log.Printf("Error reading mod info: %v", err)
+		return
+	}
+	data, err := json.Marshal(ModVersionList{
+		ID:            id,
+		LatestVersion: latest.Version,
+		Versions:      modVersionInfos(id),
+	})
+	if err != nil {
+		http.Error(w, "Failed to encode versions", http.StatusInternalServerError)
+		return
+	}
+	writeWithETag(w, r, "application/json", data)
+}
+
+// modVersionHandler 返回模组指定版本的 info.json
+// GET /mods/{id}/{version}
+func modVersionHandler(w http.ResponseWriter, r *http.Request, id, version string) {
+	if validateVersion("version", version) != nil {
+		writeJSONError(w, http.StatusBadRequest, "Invalid version")
+		return
+	}
+	data, err := readModInfo(id, version)
+	if errors.Is(err, os.ErrNotExist) {
+		writeJSONError(w, http.StatusNotFound, "Mod version not found")
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to read mod info", http.StatusInternalServerError)
+		log.Printf("Error reading mod info for %s %s: %v", id, version, err)
+		return
+	}
+	writeWithETag(w, r, "application/json", data)
+}
+
+// listModsHandler 列出所有已注册的模组
+// GET /api/mods
+func listModsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mods := []ModSummary{}
+	for _, id := range availableModIDs() {
+		summary, err := modSummary(id)
+		if err != nil {
+			log.Printf("Skipping mod %s: %v", id, err)
+			continue
+		}
+		mods = append(mods, summary)
+	}
+	slices.SortFunc(mods, func(a, b ModSummary) int { return strings.Compare(a.ID, b.ID) })
+	writeList(w, r, mods)
+}
+
+// publishModHandler 上传模组的一个版本，文件保存到 mods/{id}/{version}/，
+// 版本高于当前最新版本时更新 latest.json；同一版本不能重复上传
+func publishModHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if !requireContentType(w, r, "multipart/form-data") {
+		return
+	}
+	if config.MaxUploadSize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, config.MaxUploadSize+1<<20)
+	}
+
+	upload, ok := receiveUpload(w, r)
+	if !ok {
+		return
+	}
+	defer upload.discard()
+
+	version := strings.TrimSpace(upload.Fields["version"])
+	if err := validateVersion("version", version); err != nil {
+		writeVersionFieldError(w, err)
+		return
+	}
+	deps, err := parseDependencyList(upload.Fields["dependencies"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if expected := strings.ToLower(strings.TrimSpace(upload.Fields["expectedHash"])); expected != "" && expected != upload.Hash {
+		writeJSONError(w, http.StatusUnprocessableEntity, "Uploaded file does not match expectedHash")
+		return
+	}
+	if !withinStorageQuota(upload.Size) {
+		http.Error(w, "Storage quota exceeded", http.StatusInsufficientStorage)
+		return
+	}
+
+	modsMu.Lock()
+	defer modsMu.Unlock()
+
+	versionDir := filepath.Join(modsDir(), id, version)
+	if _, err := os.Stat(versionDir); err == nil {
+		writeJSONError(w, http.StatusConflict, "Mod version already exists")
+		return
+	}
+
+	// 扫描通过后才创建版本目录，未通过的文件不会出现在 /mods 下
+	if result, ok := scanBeforePublish(w, upload.TempPath, upload.Filename); !ok {
+		addActivity("quarantine", fmt.Sprintf("Quarantined mod %s %s: %s (%s)", id, version, upload.Filename, result))
+		return
+	}
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		log.Printf("Error creating mod directory %s: %v", versionDir, err)
+		return
+	}
+	destPath := filepath.Join(versionDir, upload.Filename)
+	if err := moveFile(upload.TempPath, destPath); err != nil {
+		os.Remove(versionDir)
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		log.Printf("Error moving mod upload %s into place: %v", destPath, err)
+		return
+	}
+	if info, err := os.Stat(destPath); err == nil {
+		storeFileHash(destPath, info, upload.Hash)
+	}
+
+	info := ModInfo{
+		ID:           id,
+		Name:         strings.TrimSpace(upload.Fields["name"]),
+		Version:      version,
+		DownloadUrl:  fmt.Sprintf("%s/mods/%s/%s/%s", persistentBaseURL(), id, url.PathEscape(version), url.PathEscape(upload.Filename)),
+		FileHash:     upload.Hash,
+		FileSize:     upload.Size,
+		Dependencies: deps,
+		Changelog:    upload.Fields["changelog"],
+		ReleaseDate:  time.Now(),
+	}
+	current, currentErr := loadModInfo(id, "")
+	if info.Name == "" && currentErr == nil {
+		info.Name = current.Name
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err == nil {
+		err = atomicWriteFile(filepath.Join(versionDir, ModInfoFile), data, 0644)
+	}
+	if err != nil {
+		os.RemoveAll(versionDir)
+		http.Error(w, "Failed to save mod info", http.StatusInternalServerError)
+		log.Printf("Error writing %s for mod %s %s: %v", ModInfoFile, id, version, err)
+		return
+	}
+
+	// 上传旧版本的补丁时保留 latest.json 指向的最新版本
+	isLatest := currentErr != nil || compareVersions(version, current.Version) > 0
+	if isLatest {
+		if err := atomicWriteFile(filepath.Join(modsDir(), id, "latest.json"), data, 0644); err != nil {
+			http.Error(w, "Failed to save mod info", http.StatusInternalServerError)
+			log.Printf("Error writing latest.json for mod %s: %v", id, err)
+			return
+		}
+		invalidateModIDs()
+	}
+
+	publishedBy := "unknown"
+	if user, ok := currentUser(r); ok {
+		publishedBy = user.Username
+	}
+	observeUpload("mod")
+	addActivity("mod", fmt.Sprintf("Published mod %s %s: %s (%d bytes, by %s)", id, version, upload.Filename, upload.Size, publishedBy))
+	log.Printf("Mod published: %s %s (%d bytes, hash: %s, latest: %t)", id, version, upload.Size, upload.Hash, isLatest)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mod":    info,
+		"latest": isLatest,
+	})
+}
+
+// serveModFile 提供 mods/{id}/{version}/{filename} 下的模组文件
+func serveModFile(w http.ResponseWriter, r *http.Request, id, version, filename string) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filePath, err := safeJoin(modsDir(), filepath.Join(id, version, filename))
+	if err != nil || !isValidModID(id) || filename == "latest.json" || filename == ModInfoFile {
+		http.Error(w, "Invalid mod file", http.StatusBadRequest)
+		return
+	}
+	file, err := os.Open(filePath)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	rule := contentTypeFor(filename)
+	w.Header().Set("Content-Type", rule.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=%s", rule.Disposition, filename))
+	w.Header().Set("ETag", downloadETag(info))
+
+	cw := &countingResponseWriter{ResponseWriter: w}
+	http.ServeContent(cw, r, filename, info.ModTime(), file)
+
+	// 与下载文件相同，各次传输（含断点续传）合计送达整个文件时计一次，同一客户端每天只计一次
+	if r.Method == http.MethodHead || isAdminDownload(r) {
+		return
+	}
+	key := downloadCountKey(r, "mods/"+id+"/"+version+"/"+filename)
+	if !deliveryCompletesFile(key, downloadETag(info), r.Header.Get("Range"), cw.status, info.Size(), cw.bytes) || !claimDownloadKey(key) {
+		return
+	}
+	attribution := DownloadAttribution{ModID: id}
+	statsStore.RecordDownload(filename, attribution)
+	observeDownload(filename, attribution)
+	addActivity("download", fmt.Sprintf("Downloaded mod %s %s: %s", id, version, filename))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeModFixture 在临时下载目录中写入模组文件，path 相对于 mods/
+func writeModFixture(t *testing.T, path, content string) {
+	t.Helper()
+	full := filepath.Join(modsDir(), path)
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getMod 请求 /mods/ 下的路径，返回状态码和响应体
+func getMod(t *testing.T, path string) (int, []byte) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	modHandler(w, httptest.NewRequest(http.MethodGet, "/mods/"+path, nil))
+	return w.Code, w.Body.Bytes()
+}
+
+func TestModVersionHistory(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// 手动放置的旧格式模组只有 latest.json，且没有 id 字段
+	writeModFixture(t, "legacy/latest.json", `{"version":"0.5.0","downloadUrl":"x"}`)
+	// 通过 /api/mods 发布的模组每个版本一个目录，latest.json 是最新版本的副本
+	writeModFixture(t, "pinned/1.0.0/info.json", `{"id":"pinned","version":"1.0.0","downloadUrl":"a"}`)
+	writeModFixture(t, "pinned/1.1.0/info.json", `{"id":"pinned","version":"1.1.0","downloadUrl":"b"}`)
+	writeModFixture(t, "pinned/latest.json", `{"id":"pinned","version":"1.1.0","downloadUrl":"b"}`)
+
+	tests := []struct {
+		path        string
+		want        int
+		wantVersion string
+	}{
+		{"legacy/latest.json", http.StatusOK, "0.5.0"},
+		{"legacy/0.5.0", http.StatusOK, "0.5.0"},
+		{"legacy/0.4.0", http.StatusNotFound, ""},
+		{"pinned/latest.json", http.StatusOK, "1.1.0"},
+		{"pinned/1.0.0", http.StatusOK, "1.0.0"},
+		{"pinned/2.0.0", http.StatusNotFound, ""},
+		{"pinned/not-a-version", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		code, body := getMod(t, tt.path)
+		if code != tt.want {
+			t.Errorf("GET /mods/%s = %d, want %d: %s", tt.path, code, tt.want, body)
+			continue
+		}
+		if tt.wantVersion == "" {
+			continue
+		}
+		var info ModInfo
+		if err := json.Unmarshal(body, &info); err != nil || info.Version != tt.wantVersion {
+			t.Errorf("GET /mods/%s = %s (%v), want version %s", tt.path, body, err, tt.wantVersion)
+		}
+	}
+
+	for id, want := range map[string][]string{
+		"legacy": {"0.5.0"},
+		"pinned": {"1.0.0", "1.1.0"},
+	} {
+		code, body := getMod(t, id+"/versions")
+		if code != http.StatusOK {
+			t.Fatalf("GET /mods/%s/versions = %d: %s", id, code, body)
+		}
+		var list ModVersionList
+		if err := json.Unmarshal(body, &list); err != nil {
+			t.Fatal(err)
+		}
+		var versions []string
+		for _, info := range list.Versions {
+			versions = append(versions, info.Version)
+			if info.ID != id {
+				t.Errorf("%s version %s has id %q", id, info.Version, info.ID)
+			}
+		}
+		if list.ID != id || list.LatestVersion != want[len(want)-1] || !slices.Equal(versions, want) {
+			t.Errorf("GET /mods/%s/versions = %+v, want versions %v", id, list, want)
+		}
+	}
+}
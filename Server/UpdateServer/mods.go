@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultModSuggestionLimit 未知模组 404 响应中默认返回的候选数量
+const DefaultModSuggestionLimit = 5
+
+// ModNotFoundResponse 未知模组的 404 响应
+type ModNotFoundResponse struct {
+	Error       string   `json:"error"`
+	ModID       string   `json:"modId"`
+	Suggestions []string `json:"suggestions"`
+}
+
+var (
+	modIDCache        []string
+	modIDCacheModTime time.Time
+	modIDCacheMu      sync.Mutex
+)
+
+// availableModIDs 返回 downloads/mods 下已发布的模组ID，目录未变化时使用缓存
+func availableModIDs() []string {
+	modsDir := filepath.Join(DownloadsDir, "mods")
+	info, err := os.Stat(modsDir)
+	if err != nil {
+		return nil
+	}
+
+	modIDCacheMu.Lock()
+	defer modIDCacheMu.Unlock()
+	if modIDCache != nil && modIDCacheModTime.Equal(info.ModTime()) {
+		return modIDCache
+	}
+
+	entries, err := os.ReadDir(modsDir)
+	if err != nil {
+		return nil
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(modsDir, entry.Name(), "latest.json")); err == nil {
+			ids = append(ids, entry.Name())
+		}
+	}
+
+	modIDCache = ids
+	modIDCacheModTime = info.ModTime()
+	return ids
+}
+
+// modForFile 返回最新版本下载地址指向该文件的模组ID，没有时返回空字符串
+func modForFile(filename string) string {
+	for _, id := range availableModIDs() {
+		data, err := os.ReadFile(filepath.Join(DownloadsDir, "mods", id, "latest.json"))
+		if err != nil {
+			continue
+		}
+		var info struct {
+			DownloadUrl string `json:"downloadUrl"`
+		}
+		if json.Unmarshal(data, &info) == nil && downloadFilename(info.DownloadUrl) == filename {
+			return id
+		}
+	}
+	return ""
+}
+
+// suggestModIDs 按编辑距离返回与 modID 相近的模组ID，包含关系视为最接近
+func suggestModIDs(modID string, ids []string, limit int) []string {
+	type candidate struct {
+		id       string
+		distance int
+	}
+
+	query := strings.ToLower(modID)
+	threshold := max(2, len(query)/3)
+	var candidates []candidate
+	for _, id := range ids {
+		lower := strings.ToLower(id)
+		distance := editDistance(query, lower)
+		if query != "" && (strings.Contains(lower, query) || strings.Contains(query, lower)) {
+			distance = min(distance, 1)
+		}
+		if distance <= threshold {
+			candidates = append(candidates, candidate{id, distance})
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].id < candidates[j].id
+	})
+
+	suggestions := make([]string, 0, min(len(candidates), limit))
+	for _, c := range candidates[:min(len(candidates), limit)] {
+		suggestions = append(suggestions, c.id)
+	}
+	return suggestions
+}
+
+// editDistance 计算两个字符串的 Levenshtein 距离
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
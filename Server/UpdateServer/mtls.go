@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// TLSConfig HTTPS 与双向TLS配置
+type TLSConfig struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+	// ClientCAFile 客户端证书CA（PEM），配置后需认证的 /api/* 接口只接受该CA签发的客户端证书
+	ClientCAFile string `json:"clientCaFile"`
+	// RedirectAddr 额外监听的 HTTP 地址（如 ":80"），请求跳转到 HTTPS，未配置时不监听
+	RedirectAddr string `json:"redirectAddr"`
+	// ACMEWebroot 跳转监听器从 {acmeWebroot}/.well-known/acme-challenge/ 提供 ACME 验证文件（certbot --webroot -w）
+	ACMEWebroot string `json:"acmeWebroot"`
+}
+
+// tlsEnabled 是否以 HTTPS 提供服务
+func tlsEnabled() bool {
+	return config.TLS.CertFile != "" && config.TLS.KeyFile != ""
+}
+
+// mtlsEnabled 是否要求管理API提供客户端证书
+func mtlsEnabled() bool {
+	return tlsEnabled() && config.TLS.ClientCAFile != ""
+}
+
+// serverTLSConfig 构建服务端TLS配置；配置客户端CA时校验客户端证书链和有效期
+// 使用 VerifyClientCertIfGiven，公开端点和管理面板不受影响，管理API在 basicAuth 中强制要求证书
+func serverTLSConfig() (*tls.Config, error) {
+	if config.TLS.ClientCAFile == "" {
+		return &tls.Config{MinVersion: tls.VersionTLS12}, nil
+	}
+	if !tlsEnabled() {
+		return nil, errors.New("tls.clientCaFile requires tls.certFile and tls.keyFile")
+	}
+
+	data, err := os.ReadFile(config.TLS.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", config.TLS.ClientCAFile)
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		ClientCAs:  pool,
+	}, nil
+}
+
+// clientCertIdentity 返回已通过校验的客户端证书身份（CN，缺省时为完整主题）
+func clientCertIdentity(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	// 握手时已校验，长连接上证书可能在连接期间过期，这里再检查一次
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return "", false
+	}
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName, true
+	}
+	return cert.Subject.String(), true
+}
+
+// mtlsAuth 启用双向TLS时校验管理API的客户端证书，并记录证书身份执行的修改操作
+func mtlsAuth(w http.ResponseWriter, r *http.Request, handler http.HandlerFunc) {
+	identity, ok := clientCertIdentity(r)
+	if !ok {
+		writeJSONError(w, http.StatusForbidden, "Valid client certificate required")
+		log.Printf("Rejected %s %s without valid client certificate rid=%s", r.Method, r.URL.Path, requestID(r))
+		return
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		addActivity("mtls", fmt.Sprintf("%s %s by %s", r.Method, r.URL.Path, identity))
+	}
+	log.Printf("Client certificate %q: %s %s rid=%s", identity, r.Method, r.URL.Path, requestID(r))
+	// 受信任CA签发的证书视为管理员
+	handler(w, withAdminUser(r, AdminUser{Username: identity, Role: RoleAdmin}))
+}
+
+// listenAndServe 按配置以 HTTP 或 HTTPS 启动服务器，收到退出信号后优雅关闭
+func listenAndServe(addr string, handler http.Handler) error {
+	if !tlsEnabled() {
+		if config.TLS.ClientCAFile != "" {
+			return errors.New("tls.clientCaFile requires tls.certFile and tls.keyFile")
+		}
+		server := &http.Server{Addr: addr, Handler: handler}
+		return serveUntilSignal(server, server.ListenAndServe)
+	}
+
+	tlsConfig, err := serverTLSConfig()
+	if err != nil {
+		return err
+	}
+	certs, err := newCertReloader(config.TLS.CertFile, config.TLS.KeyFile)
+	if err != nil {
+		return err
+	}
+	tlsConfig.GetCertificate = certs.GetCertificate
+
+	if config.TLS.RedirectAddr != "" {
+		go serveHTTPSRedirect(config.TLS.RedirectAddr)
+	}
+	server := &http.Server{Addr: addr, Handler: handler, TLSConfig: tlsConfig}
+	return serveUntilSignal(server, func() error { return server.ListenAndServeTLS("", "") })
+}
+
+// isAPIPath 是否为 /api/ 下的路径
+func isAPIPath(path string) bool {
+	return strings.HasPrefix(path, "/api/")
+}
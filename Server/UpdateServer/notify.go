@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultManifestWebhookTimeout 未配置超时时的通知超时
+	DefaultManifestWebhookTimeout = 2 * time.Second
+	// MaxManifestWebhookTimeout 通知超时上限。通知失败要回滚清单，回滚必须与写入在同一把 manifestMu 内完成，
+	// 发送通知时其他清单写入都在等待，所以超时只能很短，配置更长的超时按上限处理
+	MaxManifestWebhookTimeout = 3 * time.Second
+)
+
+// ManifestWebhookConfig 清单发布通知配置，URL 为空时不通知
+type ManifestWebhookConfig struct {
+	// URL 每次清单提交后 POST 通知的地址，返回非2xx视为失败
+	URL string `json:"url"`
+	// Timeout 单次通知超时，默认2秒，最长3秒
+	Timeout Duration `json:"timeout"`
+}
+
+// ManifestNotification 清单提交通知的请求体
+type ManifestNotification struct {
+	Channel       string    `json:"channel"`
+	LatestVersion string    `json:"latestVersion"`
+	ContentHash   string    `json:"contentHash"`
+	LastUpdated   time.Time `json:"lastUpdated"`
+}
+
+// notifyManifestCommitted 向配置的地址发送清单提交通知，未配置时直接返回
+func notifyManifestCommitted(channel string, manifest *UpdateManifest) error {
+	url := config.ManifestWebhook.URL
+	if url == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(ManifestNotification{
+		Channel:       channel,
+		LatestVersion: manifest.LatestVersion,
+		ContentHash:   manifest.ContentHash,
+		LastUpdated:   manifest.LastUpdated,
+	})
+	if err != nil {
+		return err
+	}
+
+	timeout := config.ManifestWebhook.Timeout.Duration
+	if timeout <= 0 {
+		timeout = DefaultManifestWebhookTimeout
+	}
+	timeout = min(timeout, MaxManifestWebhookTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
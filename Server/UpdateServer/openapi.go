@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// openAPISpec 管理API的OpenAPI描述（持续补充中）
+var openAPISpec = map[string]interface{}{
+	"openapi": "3.0.3",
+	"info": map[string]interface{}{
+		"title":   "LizardClient Update Server API",
+		"version": "2.0.0",
+	},
+	"paths": map[string]interface{}{
+		"/api/files": map[string]interface{}{
+			"get": map[string]interface{}{
+				"summary":    "文件列表",
+				"parameters": listParameters(),
+				"responses": map[string]interface{}{
+					"200": listResponse("#/components/schemas/FileInfo"),
+				},
+			},
+		},
+	},
+	"components": map[string]interface{}{
+		"securitySchemes": map[string]interface{}{
+			"basicAuth": map[string]interface{}{"type": "http", "scheme": "basic"},
+		},
+		"schemas": map[string]interface{}{
+			"ListEnvelope": map[string]interface{}{
+				"type":        "object",
+				"description": "列表接口统一响应信封；?format=array 返回裸数组",
+				"required":    []string{"items", "total", "limit", "offset", "nextOffset"},
+				"properties": map[string]interface{}{
+					"items":      map[string]interface{}{"type": "array", "items": map[string]interface{}{}},
+					"total":      map[string]interface{}{"type": "integer"},
+					"limit":      map[string]interface{}{"type": "integer"},
+					"offset":     map[string]interface{}{"type": "integer"},
+					"nextOffset": map[string]interface{}{"type": "integer", "nullable": true},
+				},
+			},
+			"FileInfo": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":     map[string]interface{}{"type": "string"},
+					"size":     map[string]interface{}{"type": "integer"},
+					"hash":     map[string]interface{}{"type": "string"},
+					"modified": map[string]interface{}{"type": "string", "format": "date-time"},
+				},
+			},
+		},
+	},
+	"security": []interface{}{map[string]interface{}{"basicAuth": []string{}}},
+}
+
+// listParameters 列表接口的通用分页参数
+func listParameters() []interface{} {
+	return []interface{}{
+		map[string]interface{}{"name": "limit", "in": "query", "schema": map[string]interface{}{"type": "integer", "default": DefaultPageLimit, "maximum": MaxPageLimit}},
+		map[string]interface{}{"name": "offset", "in": "query", "schema": map[string]interface{}{"type": "integer", "default": 0}},
+		map[string]interface{}{"name": "format", "in": "query", "schema": map[string]interface{}{"type": "string", "enum": []string{"array"}}},
+	}
+}
+
+// listResponse 生成使用分页信封的响应描述
+func listResponse(itemRef string) map[string]interface{} {
+	return map[string]interface{}{
+		"description": "分页列表",
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": map[string]interface{}{
+					"allOf": []interface{}{
+						map[string]interface{}{"$ref": "#/components/schemas/ListEnvelope"},
+						map[string]interface{}{
+							"properties": map[string]interface{}{
+								"items": map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": itemRef}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// openAPIHandler 返回OpenAPI描述
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(openAPISpec)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// DefaultPageLimit 未指定 limit 时的每页条数
+	DefaultPageLimit = 100
+	// MaxPageLimit 单页最大条数
+	MaxPageLimit = 1000
+)
+
+// ListEnvelope 列表接口统一响应结构
+type ListEnvelope[T any] struct {
+	Items  []T `json:"items"`
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+	// NextOffset 下一页的偏移量，没有更多数据时为 null
+	NextOffset *int `json:"nextOffset"`
+}
+
+// parsePage 解析 limit/offset 查询参数
+func parsePage(r *http.Request) (limit, offset int, ok bool) {
+	limit, offset = DefaultPageLimit, 0
+
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		limit = min(n, MaxPageLimit)
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
+}
+
+// paginate 截取一页数据并生成响应信封
+func paginate[T any](items []T, limit, offset int) ListEnvelope[T] {
+	total := len(items)
+	start := min(offset, total)
+	end := min(start+limit, total)
+
+	page := ListEnvelope[T]{
+		Items:  items[start:end],
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if end < total {
+		next := end
+		page.NextOffset = &next
+	}
+	return page
+}
+
+// writeList 输出分页列表；旧客户端可通过 ?format=array 或配置 legacyListFormat 获取裸数组
+func writeList[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	writeListPage(w, r, items, nil)
+}
+
+// writeListPage 与 writeList 相同，输出前对实际返回的条目调用 fill，用于只为当前页补充代价较高的字段
+// 过滤后的总数写入 X-Total-Count 响应头
+func writeListPage[T any](w http.ResponseWriter, r *http.Request, items []T, fill func(page []T)) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(items)))
+
+	if config.LegacyListFormat || r.URL.Query().Get("format") == "array" {
+		if items == nil {
+			items = []T{}
+		}
+		if fill != nil {
+			fill(items)
+		}
+		json.NewEncoder(w).Encode(items)
+		return
+	}
+
+	limit, offset, ok := parsePage(r)
+	if !ok {
+		http.Error(w, "Invalid limit or offset", http.StatusBadRequest)
+		return
+	}
+	page := paginate(items, limit, offset)
+	if fill != nil {
+		fill(page.Items)
+	}
+	json.NewEncoder(w).Encode(page)
+}
@@ -47,7 +47,7 @@ function initializeUpload() {
     });
 }
 
-async function uploadFile(file) {
+async function uploadFile(file, overwrite = false) {
     const progressDiv = document.getElementById('uploadProgress');
     const resultDiv = document.getElementById('uploadResult');
     const progressFill = document.getElementById('progressFill');
@@ -61,6 +61,9 @@ async function uploadFile(file) {
 
     const formData = new FormData();
     formData.append('file', file);
+    if (overwrite) {
+        formData.append('overwrite', 'true');
+    }
 
     try {
         const xhr = new XMLHttpRequest();
@@ -81,6 +84,12 @@ async function uploadFile(file) {
                 showUploadResult(response);
                 loadStatistics();
                 loadFiles();
+            } else if (xhr.status === 409 && !overwrite) {
+                progressDiv.style.display = 'none';
+                if (confirm(`文件 ${file.name} 已存在，是否覆盖？`)) {
+                    uploadFile(file, true);
+                }
+                return;
             } else {
                 showError('上传失败: ' + xhr.statusText);
             }
@@ -92,7 +101,7 @@ async function uploadFile(file) {
             progressDiv.style.display = 'none';
         });
 
-        xhr.open('POST', '/api/upload');
+        xhr.open('POST', '/admin/api/upload');
         xhr.send(formData);
 
     } catch (error) {
@@ -119,7 +128,7 @@ function showUploadResult(data) {
 
 async function loadStatistics() {
     try {
-        const response = await fetch('/api/statistics');
+        const response = await fetch('/admin/api/statistics');
         const stats = await response.json();
 
         document.getElementById('totalDownloads').textContent = stats.totalDownloads || 0;
@@ -180,7 +189,7 @@ async function saveManifest() {
     try {
         const manifest = JSON.parse(editorContent);
 
-        const response = await fetch(`/api/manifests/${channel}`, {
+        const response = await fetch(`/admin/api/manifests/${channel}`, {
             method: 'PUT',
             headers: {
                 'Content-Type': 'application/json'
@@ -202,20 +211,43 @@ async function saveManifest() {
 
 // ============ 文件管理 ============
 
-async function loadFiles() {
+// 文件列表分页加载，哈希在复制时按需获取
+const FILE_PAGE_SIZE = 50;
+let nextFileOffset = 0;
+let fileSearchTimer = null;
+
+function searchFiles() {
+    clearTimeout(fileSearchTimer);
+    fileSearchTimer = setTimeout(() => loadFiles(), 300);
+}
+
+async function loadFiles(append = false) {
     const listDiv = document.getElementById('fileList');
-    listDiv.innerHTML = '<p class="loading">加载中...</p>';
+    const moreButton = document.getElementById('loadMoreFiles');
+    if (!append) {
+        nextFileOffset = 0;
+        listDiv.innerHTML = '<p class="loading">加载中...</p>';
+    }
 
     try {
-        const response = await fetch('/api/files');
-        const files = await response.json();
+        const params = new URLSearchParams({
+            limit: FILE_PAGE_SIZE,
+            offset: nextFileOffset,
+            q: document.getElementById('fileSearch').value.trim()
+        });
+        const response = await fetch(`/admin/api/files?${params}`);
+        const page = await response.json();
+        const files = page.items || [];
 
-        if (files.length === 0) {
+        nextFileOffset = page.nextOffset ?? 0;
+        moreButton.hidden = page.nextOffset == null;
+
+        if (!append && files.length === 0) {
             listDiv.innerHTML = '<p class="loading">暂无文件</p>';
             return;
         }
 
-        listDiv.innerHTML = files.map(file => `
+        const html = files.map(file => `
             <div class="file-item">
                 <div class="file-info">
                     <div class="file-name">📄 ${file.name}</div>
@@ -223,14 +255,19 @@ async function loadFiles() {
                         大小: ${formatBytes(file.size)} | 
                         修改: ${new Date(file.modified).toLocaleString('zh-CN')}
                     </div>
-                    ${file.hash ? `<div class="file-hash">SHA256: ${file.hash}</div>` : ''}
                 </div>
                 <div class="file-actions">
-                    <button class="btn btn-secondary" onclick="copyHash('${file.hash}')">复制哈希</button>
+                    <button class="btn btn-secondary" onclick="copyFileHash('${file.name}')">复制哈希</button>
+                    <button class="btn btn-secondary" onclick="linkFile('${file.name}')">加入清单</button>
                     <button class="btn btn-danger" onclick="deleteFile('${file.name}')">删除</button>
                 </div>
             </div>
         `).join('');
+        if (append) {
+            listDiv.insertAdjacentHTML('beforeend', html);
+        } else {
+            listDiv.innerHTML = html;
+        }
     } catch (error) {
         console.error('Failed to load files:', error);
         listDiv.innerHTML = '<p class="loading">加载失败</p>';
@@ -238,12 +275,27 @@ async function loadFiles() {
 }
 
 async function deleteFile(filename) {
-    if (!confirm(`确定要删除文件 "${filename}" 吗？`)) {
-        return;
-    }
-
     try {
-        const response = await fetch(`/api/files/${filename}`, {
+        // 先试运行，查看文件被哪些清单引用
+        const preview = await fetch(`/admin/api/files/${encodeURIComponent(filename)}?dryRun=true`, {
+            method: 'DELETE'
+        });
+        const impact = preview.ok ? await preview.json() : { references: [] };
+
+        let message = `确定要删除文件 "${filename}" 吗？`;
+        if (impact.references.length > 0) {
+            const refs = impact.references.map(ref => `${ref.channel}/${ref.version}${ref.isLatest ? ' (当前版本)' : ''}`);
+            message = `文件 "${filename}" 被以下清单版本引用:\n${refs.join('\n')}\n`;
+            if (impact.breaksLatest) {
+                message += '\n⚠️ 删除后当前最新版本将无法下载！\n';
+            }
+            message += '\n仍要删除吗？';
+        }
+        if (!confirm(message)) {
+            return;
+        }
+
+        const response = await fetch(`/admin/api/files/${encodeURIComponent(filename)}?force=true`, {
             method: 'DELETE'
         });
 
@@ -259,6 +311,49 @@ async function deleteFile(filename) {
     }
 }
 
+async function linkFile(filename) {
+    const channel = document.getElementById('channelSelect').value;
+    const version = prompt(`将 "${filename}" 加入 ${channel} 频道，版本号:`, '');
+    if (!version) {
+        return;
+    }
+
+    try {
+        const response = await fetch(`/admin/api/files/${encodeURIComponent(filename)}/link`, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ channel: channel, version: version })
+        });
+
+        if (response.ok) {
+            showSuccess(`已加入 ${channel}/${version}`);
+            loadManifest();
+        } else {
+            showError('加入清单失败: ' + await response.text());
+        }
+    } catch (error) {
+        showError('加入清单失败: ' + error.message);
+    }
+}
+
+async function copyFileHash(filename) {
+    try {
+        const response = await fetch('/admin/api/hash', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ filename })
+        });
+        if (!response.ok) {
+            showError('获取哈希失败');
+            return;
+        }
+        const data = await response.json();
+        copyHash(data.hash);
+    } catch (error) {
+        showError('获取哈希失败: ' + error.message);
+    }
+}
+
 function copyHash(hash) {
     navigator.clipboard.writeText(hash).then(() => {
         showSuccess('哈希值已复制到剪贴板');
@@ -267,6 +362,45 @@ function copyHash(hash) {
     });
 }
 
+// ============ 通行密钥 ============
+
+async function registerPasskey() {
+    const name = prompt('通行密钥名称', '');
+    if (name === null) {
+        return;
+    }
+
+    try {
+        const begin = await fetch('/admin/webauthn/register/begin', { method: 'POST' });
+        if (!begin.ok) {
+            throw new Error(await begin.text());
+        }
+        const options = (await begin.json()).publicKey;
+        options.challenge = fromBase64Url(options.challenge);
+        options.user.id = fromBase64Url(options.user.id);
+        options.excludeCredentials = options.excludeCredentials.map(c => ({ ...c, id: fromBase64Url(c.id) }));
+
+        const credential = await navigator.credentials.create({ publicKey: options });
+        const finish = await fetch('/admin/webauthn/register/finish', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({
+                name: name,
+                response: {
+                    clientDataJSON: toBase64Url(credential.response.clientDataJSON),
+                    attestationObject: toBase64Url(credential.response.attestationObject)
+                }
+            })
+        });
+        if (!finish.ok) {
+            throw new Error(await finish.text());
+        }
+        showSuccess('通行密钥已注册');
+    } catch (error) {
+        showError('注册失败: ' + error.message);
+    }
+}
+
 // ============ 工具函数 ============
 
 function formatBytes(bytes) {
@@ -277,6 +411,16 @@ function formatBytes(bytes) {
     return (bytes / Math.pow(k, i)).toFixed(2) + ' ' + sizes[i];
 }
 
+function toBase64Url(buffer) {
+    return btoa(String.fromCharCode(...new Uint8Array(buffer)))
+        .replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+function fromBase64Url(value) {
+    const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+    return Uint8Array.from(atob(base64), c => c.charCodeAt(0));
+}
+
 function showSuccess(message) {
     showMessage(message, 'success');
 }
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// embeddedPanel 内置的管理面板，PanelDir 中缺少的文件从这里提供
+//
+//go:embed panel
+var embeddedPanel embed.FS
+
+// servePanelFile 提供面板文件，PanelDir 中的自定义文件优先于内置文件
+func servePanelFile(w http.ResponseWriter, r *http.Request, name string) {
+	name = path.Clean("/" + name)[1:]
+	if name == "" {
+		name = "index.html"
+	}
+
+	diskPath := filepath.Join(PanelDir, filepath.FromSlash(name))
+	if info, err := os.Stat(diskPath); err == nil && !info.IsDir() {
+		http.ServeFile(w, r, diskPath)
+		return
+	}
+
+	panelFS, err := fs.Sub(embeddedPanel, "panel")
+	if err != nil || !fs.ValidPath(name) {
+		http.NotFound(w, r)
+		return
+	}
+	if info, err := fs.Stat(panelFS, name); err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeFileFS(w, r, panelFS, name)
+}
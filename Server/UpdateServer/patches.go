@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PatchesDir 差分补丁存储目录，位于下载目录下，由 applyPathOverrides 设置
+var PatchesDir = filepath.Join(DownloadsDir, "patches")
+
+// DefaultPatchMaxFileSize 生成补丁时新旧文件的默认大小上限，生成过程约需 10 倍于旧文件的内存
+const DefaultPatchMaxFileSize = 256 << 20
+
+// PatchFile 已生成的差分补丁
+type PatchFile struct {
+	From string `json:"from"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Hash string `json:"hash"`
+}
+
+// patchFilename 返回从 fromVersion 升级到 filename 的补丁文件名
+func patchFilename(filename, fromVersion string) string {
+	return fmt.Sprintf("%s.from-%s.patch", filename, fromVersion)
+}
+
+// generatePatch 生成并校验从 oldPath 到 newPath 的补丁，补丁不小于新文件时放弃
+func generatePatch(oldPath, newPath, patchPath string) (int64, string, error) {
+	for _, path := range []string{oldPath, newPath} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0, "", err
+		}
+		if config.PatchMaxFileSize > 0 && info.Size() > config.PatchMaxFileSize {
+			return 0, "", fmt.Errorf("%s exceeds patchMaxFileSize", filepath.Base(path))
+		}
+	}
+
+	old, err := os.ReadFile(oldPath)
+	if err != nil {
+		return 0, "", err
+	}
+	new, err := os.ReadFile(newPath)
+	if err != nil {
+		return 0, "", err
+	}
+
+	patch, err := bsdiff(old, new)
+	if err != nil {
+		return 0, "", err
+	}
+	if len(patch) >= len(new) {
+		return 0, "", errors.New("patch is not smaller than the full file")
+	}
+
+	// 写入前回放补丁，确保客户端应用后得到完全相同的文件
+	rebuilt, err := bspatch(old, patch)
+	if err != nil {
+		return 0, "", fmt.Errorf("verify patch: %w", err)
+	}
+	if !bytes.Equal(rebuilt, new) {
+		return 0, "", errors.New("verify patch: output mismatch")
+	}
+
+	if err := os.MkdirAll(PatchesDir, 0755); err != nil {
+		return 0, "", err
+	}
+	if err := atomicWriteFile(patchPath, patch, 0644); err != nil {
+		return 0, "", err
+	}
+	sum := sha256.Sum256(patch)
+	return int64(len(patch)), hex.EncodeToString(sum[:]), nil
+}
+
+// startPatchGeneration 在后台生成从频道中 fromVersion 的主文件到 filename 的补丁
+// fromVersion 为空时使用频道当前发布的版本；生成完成后自动写入引用该文件的清单条目
+func startPatchGeneration(channel, fromVersion, filename string) (string, error) {
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		return "", err
+	}
+
+	from := findUpdate(manifest, fromVersion)
+	if fromVersion == "" {
+		from = currentRelease(manifest)
+	}
+	if from == nil {
+		return "", fmt.Errorf("version %q not found in %s", fromVersion, channel)
+	}
+	files := releaseFiles(*from)
+	if len(files) == 0 {
+		return "", fmt.Errorf("version %s has no download file", from.Version)
+	}
+	if files[0] == filename {
+		return "", fmt.Errorf("version %s already uses %s", from.Version, filename)
+	}
+
+	version := from.Version
+	oldPath := filepath.Join(DownloadsDir, files[0])
+	go func() {
+		start := time.Now()
+		patchPath := filepath.Join(PatchesDir, patchFilename(filename, version))
+		size, _, err := generatePatch(oldPath, filepath.Join(DownloadsDir, filename), patchPath)
+		if err != nil {
+			log.Printf("Patch generation skipped for %s from %s: %v", filename, version, err)
+			return
+		}
+		log.Printf("Patch generated: %s (%d bytes, %s)", filepath.Base(patchPath), size, time.Since(start).Round(time.Millisecond))
+		addActivity("patch", fmt.Sprintf("Generated patch for %s from %s (%d bytes)", filename, version, size))
+		attachPatches(filename)
+	}()
+	return version, nil
+}
+
+// availablePatch 返回版本主文件可用的补丁，优先选择最接近的旧版本；旧版本须仍在清单中且未撤回
+func availablePatch(manifest *UpdateManifest, update UpdateInfo) (PatchFile, bool) {
+	files := releaseFiles(update)
+	if len(files) == 0 {
+		return PatchFile{}, false
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(PatchesDir, patchFilename(files[0], "*")))
+	var best PatchFile
+	for _, path := range matches {
+		name := filepath.Base(path)
+		from := strings.TrimSuffix(strings.TrimPrefix(name, files[0]+".from-"), ".patch")
+		candidate := findUpdate(manifest, from)
+		if candidate == nil || candidate.Yanked || compareVersions(from, update.Version) >= 0 {
+			continue
+		}
+		if best.From != "" && compareVersions(from, best.From) <= 0 {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		hash, err := cachedFileHash(path)
+		if err != nil {
+			continue
+		}
+		best = PatchFile{From: from, Name: name, Size: info.Size(), Hash: hash}
+	}
+	return best, best.From != ""
+}
+
+// applyPatchInfo 将补丁信息写入清单条目
+func applyPatchInfo(update *UpdateInfo, patch PatchFile, baseURL, channel string) {
+	update.PatchFrom = patch.From
+	update.PatchUrl = fmt.Sprintf("%s/patches/%s/%s?from=%s", baseURL, channel, url.PathEscape(update.Version), url.QueryEscape(patch.From))
+	update.PatchSize = patch.Size
+	update.PatchHash = patch.Hash
+}
+
+// attachPatches 为主文件为 filename 且尚无补丁的清单条目补充补丁信息
+func attachPatches(filename string) {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+
+		changed := false
+		for i := range manifest.Updates {
+			update := &manifest.Updates[i]
+			files := releaseFiles(*update)
+			if update.PatchFrom != "" || len(files) == 0 || files[0] != filename {
+				continue
+			}
+			if patch, ok := availablePatch(manifest, *update); ok {
+				applyPatchInfo(update, patch, manifestBaseURL(manifest), channel)
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		manifest.LastUpdated = time.Now()
+		if err := saveManifest(channel, manifest); err != nil {
+			log.Printf("Error attaching patch to %s manifest: %v", channel, err)
+		}
+	}
+}
+
+// patchHandler 下载差分补丁，没有从 from 版本出发的补丁时重定向到完整文件
+// GET /patches/{channel}/{version}?from=1.2.0&platform=windows
+func patchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts, ok := splitSubpath(r.URL.Path, "/patches/")
+	if !ok || len(parts) != 2 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	channel, version := parts[0], parts[1]
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		http.Error(w, "Missing from version", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		return
+	}
+	update := findUpdate(manifest, version)
+	if update == nil || update.Yanked {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	// 补丁只针对主文件，平台选中其他文件时同样回退到完整下载
+	asset, ok := assetForPlatform(*update, r.URL.Query().Get("platform"))
+	if !ok {
+		http.Error(w, "Version has no download file", http.StatusNotFound)
+		return
+	}
+	if update.PatchFrom == from && asset.Url == update.DownloadUrl {
+		patchPath := filepath.Join(PatchesDir, patchFilename(downloadFilename(update.DownloadUrl), from))
+		if file, err := os.Open(patchPath); err == nil {
+			defer file.Close()
+			info, err := file.Stat()
+			if err == nil {
+				w.Header().Set("Content-Type", "application/x-bsdiff")
+				w.Header().Set("Content-Disposition", "attachment; filename="+filepath.Base(patchPath))
+				w.Header().Set("X-Patch-From", from)
+				w.Header().Set("X-Patch-Hash", update.PatchHash)
+				log.Printf("Patch download: %s %s -> %s rid=%s", channel, from, version, requestID(r))
+				http.ServeContent(w, r, "", info.ModTime(), file)
+				return
+			}
+		}
+	}
+
+	w.Header().Set("X-Patch-Fallback", "full")
+	http.Redirect(w, r, asset.Url, http.StatusFound)
+}
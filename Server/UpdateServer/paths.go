@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// 监听端口与数据目录的环境变量，启动参数优先
+const (
+	PortEnv          = "LIZARD_PORT"
+	ManifestsDirEnv  = "LIZARD_MANIFESTS_DIR"
+	DownloadsDirEnv  = "LIZARD_DOWNLOADS_DIR"
+	ChangelogsDirEnv = "LIZARD_CHANGELOGS_DIR"
+	PanelDirEnv      = "LIZARD_PANEL_DIR"
+	UploadsDirEnv    = "LIZARD_UPLOADS_DIR"
+)
+
+var (
+	portFlag          = flag.String("port", "", "listen port, overrides "+PortEnv+" (default 51000)")
+	manifestsDirFlag  = flag.String("manifests-dir", "", "manifest directory, overrides "+ManifestsDirEnv+" (default ./manifests)")
+	downloadsDirFlag  = flag.String("downloads-dir", "", "download directory (also holds patches and mods), overrides "+DownloadsDirEnv+" (default ./downloads)")
+	changelogsDirFlag = flag.String("changelogs-dir", "", "changelog directory, overrides "+ChangelogsDirEnv+" (default ./changelogs)")
+	panelDirFlag      = flag.String("panel-dir", "", "directory with admin panel overrides, overrides "+PanelDirEnv+" (default ./panel)")
+	uploadsDirFlag    = flag.String("uploads-dir", "", "temporary upload directory, overrides "+UploadsDirEnv+" (default ./uploads)")
+)
+
+// applyPathOverrides 以启动参数和环境变量覆盖默认端口与数据目录，需在访问任何目录之前调用
+func applyPathOverrides() {
+	override := func(target *string, flagValue, env string) {
+		if flagValue != "" {
+			*target = flagValue
+		} else if value := os.Getenv(env); value != "" {
+			*target = value
+		}
+	}
+	override(&Port, *portFlag, PortEnv)
+	override(&ManifestsDir, *manifestsDirFlag, ManifestsDirEnv)
+	override(&DownloadsDir, *downloadsDirFlag, DownloadsDirEnv)
+	override(&ChangelogsDir, *changelogsDirFlag, ChangelogsDirEnv)
+	override(&PanelDir, *panelDirFlag, PanelDirEnv)
+	override(&UploadSessionsDir, *uploadsDirFlag, UploadsDirEnv)
+	PatchesDir = filepath.Join(DownloadsDir, "patches")
+
+	if port, err := strconv.Atoi(Port); err != nil || port < 1 || port > 65535 {
+		log.Fatalf("Invalid port %q", Port)
+	}
+}
+
+// checkWritableDir 在目录中创建并删除临时文件，确认进程可以写入
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	tmp, err := os.CreateTemp(dir, ".write-check.tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+// validateDirectories 启动时确认数据目录可写、面板目录可读，挂载的卷权限不对时直接退出
+func validateDirectories() {
+	for _, dir := range []string{ManifestsDir, DownloadsDir, ChangelogsDir, filepath.Join(DownloadsDir, "mods")} {
+		if err := checkWritableDir(dir); err != nil {
+			log.Fatalf("Directory %s is not writable: %v", dir, err)
+		}
+	}
+	if _, err := os.ReadDir(PanelDir); err != nil {
+		log.Fatalf("Panel directory %s is not readable: %v", PanelDir, err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+)
+
+// MaxQueryVersions 单次部分清单查询最多请求的版本数
+const MaxQueryVersions = 100
+
+// ManifestQueryResult 部分清单查询中单个版本的结果
+type ManifestQueryResult struct {
+	Version string      `json:"version"`
+	Status  int         `json:"status"`
+	Update  *UpdateInfo `json:"update,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// ManifestQueryResponse 部分清单查询结果，包含频道元数据
+type ManifestQueryResponse struct {
+	Channel        string                `json:"channel"`
+	LatestVersion  string                `json:"latestVersion"`
+	MinimumVersion string                `json:"minimumVersion,omitempty"`
+	LastUpdated    time.Time             `json:"lastUpdated"`
+	Results        []ManifestQueryResult `json:"results"`
+}
+
+// queryManifestHandler 只返回指定版本的更新条目，不存在的版本在结果中标记 404
+// POST /api/manifests/{channel}/query {"versions": ["1.0.0", "1.1.0"]}
+func queryManifestHandler(w http.ResponseWriter, r *http.Request, channel string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Versions []string `json:"versions"`
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(req.Versions) == 0 {
+		http.Error(w, "Missing versions", http.StatusBadRequest)
+		return
+	}
+	if len(req.Versions) > MaxQueryVersions {
+		http.Error(w, "Too many versions", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		log.Printf("Error reading manifest for query %s: %v", channel, err)
+		return
+	}
+
+	response := ManifestQueryResponse{
+		Channel:        channel,
+		LatestVersion:  manifest.LatestVersion,
+		MinimumVersion: manifest.MinimumVersion,
+		LastUpdated:    manifest.LastUpdated,
+		Results:        make([]ManifestQueryResult, 0, len(req.Versions)),
+	}
+	for _, version := range req.Versions {
+		result := ManifestQueryResult{Version: version}
+		switch update := findUpdate(manifest, version); {
+		case !isValidVersion(version):
+			result.Status = http.StatusBadRequest
+			result.Error = "Invalid version"
+		case update == nil:
+			result.Status = http.StatusNotFound
+			result.Error = "Version not found"
+		default:
+			result.Status = http.StatusOK
+			result.Update = update
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rateLimitBucketIdle 令牌桶空闲多久后回收（此时桶早已补满，回收不影响限流结果）
+const rateLimitBucketIdle = 10 * time.Minute
+
+// RateLimitConfig 按客户端IP的令牌桶限流配置
+type RateLimitConfig struct {
+	// Enabled 是否启用限流
+	Enabled bool `json:"enabled"`
+	// TrustForwardedFor 以 X-Forwarded-For 中最后一个地址（反向代理看到的来源）作为客户端IP，仅在反向代理后启用
+	TrustForwardedFor bool `json:"trustForwardedFor"`
+	// Public 公开端点（清单、下载、健康检查等）的默认限额
+	Public RateLimitRule `json:"public"`
+	// Admin 管理API与管理面板的限额，通常高于公开端点
+	Admin RateLimitRule `json:"admin"`
+	// Endpoints 路由前缀（以 "/" 开头）到限额的映射，按最长前缀匹配，优先于 Public/Admin
+	Endpoints map[string]RateLimitRule `json:"endpoints"`
+}
+
+// RateLimitRule 令牌桶限额
+type RateLimitRule struct {
+	// Rate 每秒补充的请求数，0 表示不限流
+	Rate float64 `json:"rate"`
+	// Burst 桶容量，即允许的突发请求数
+	Burst int `json:"burst"`
+}
+
+// publicAPIPaths 无需认证的 /api/ 路由，使用公开端点限额；新增公开API时需同步
+var publicAPIPaths = map[string]bool{
+	"/api/heartbeat":      true,
+	"/api/client-config":  true,
+	"/api/check":          true,
+	"/api/limits":         true,
+	"/api/changelog/diff": true,
+	"/api/latest-multi":   true,
+	"/api/resolve":        true,
+}
+
+// tokenBucket 单个客户端在某一限额下的令牌桶
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+var (
+	rateLimitBuckets   = make(map[string]*tokenBucket)
+	rateLimitBucketsMu sync.Mutex
+)
+
+// isAdminRoute 是否为管理面板或需要认证的管理API
+func isAdminRoute(path string) bool {
+	if isPanelPath(path) {
+		return true
+	}
+	return isAPIPath(path) && !publicAPIPaths[path]
+}
+
+// rateLimitRuleFor 返回路由适用的限额及其名称（用作令牌桶的键），名称相同的路由共享令牌桶
+func rateLimitRuleFor(path string) (string, RateLimitRule) {
+	matched := ""
+	for prefix := range config.RateLimit.Endpoints {
+		if strings.HasPrefix(prefix, "/") && strings.HasPrefix(path, prefix) && len(prefix) > len(matched) {
+			matched = prefix
+		}
+	}
+	if matched != "" {
+		return matched, config.RateLimit.Endpoints[matched]
+	}
+	if isAdminRoute(path) {
+		return "admin", config.RateLimit.Admin
+	}
+	return "public", config.RateLimit.Public
+}
+
+// clientIP 返回请求的客户端IP，限流、并发下载限制与访问日志共用；只在开启 trustForwardedFor 时采用 X-Forwarded-For
+func clientIP(r *http.Request) string {
+	if config.RateLimit.TrustForwardedFor {
+		// 最后一个地址由最近的反向代理追加，客户端无法伪造
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			parts := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// takeRateLimitToken 从令牌桶中取出一个令牌，桶已空时返回需要等待的时长
+func takeRateLimitToken(key string, rule RateLimitRule, now time.Time) (bool, time.Duration) {
+	burst := float64(max(rule.Burst, 1))
+
+	rateLimitBucketsMu.Lock()
+	defer rateLimitBucketsMu.Unlock()
+
+	bucket, ok := rateLimitBuckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		rateLimitBuckets[key] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rule.Rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - bucket.tokens) / rule.Rate * float64(time.Second))
+}
+
+// pruneRateLimitBuckets 回收长时间空闲的令牌桶，避免大量来源IP占用内存
+func pruneRateLimitBuckets(now time.Time) {
+	rateLimitBucketsMu.Lock()
+	defer rateLimitBucketsMu.Unlock()
+
+	for key, bucket := range rateLimitBuckets {
+		if now.Sub(bucket.last) > rateLimitBucketIdle {
+			delete(rateLimitBuckets, key)
+		}
+	}
+}
+
+// rateLimitMiddleware 按客户端IP限制请求速率，超出时返回 429 和 Retry-After
+func rateLimitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !config.RateLimit.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+		name, rule := rateLimitRuleFor(r.URL.Path)
+		if rule.Rate <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ok, wait := takeRateLimitToken(name+"|"+clientIP(r), rule, time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"net/http"
+	"os"
+	"time"
+)
+
+// ComponentStatus 就绪检查中单个组件的状态
+type ComponentStatus struct {
+	// Status 为 "ok" 或 "error"
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ReadinessResponse 就绪检查响应
+type ReadinessResponse struct {
+	// Status 全部组件正常时为 "ready"，否则为 "degraded"
+	Status     string                     `json:"status"`
+	Timestamp  time.Time                  `json:"timestamp"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// componentStatus 将检查结果转换为组件状态
+func componentStatus(err error) ComponentStatus {
+	if err != nil {
+		return ComponentStatus{Status: "error", Error: err.Error()}
+	}
+	return ComponentStatus{Status: "ok"}
+}
+
+// checkStatsStore 实际写入一次统计存储，并报告之前持续写入失败的错误
+func checkStatsStore() error {
+	if err := statsStore.Probe(); err != nil {
+		return err
+	}
+	return statsStore.Health()
+}
+
+// checkDownloadsDir 下载目录存在且为目录
+func checkDownloadsDir() error {
+	info, err := os.Stat(DownloadsDir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("not a directory")
+	}
+	return nil
+}
+
+// readyHandler 就绪检查：实际读取清单目录、写入统计存储、检查下载目录，任一失败返回 503；
+// /health 只用于存活检查，不访问磁盘
+// GET /ready
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	_, manifestsErr := os.ReadDir(ManifestsDir)
+	response := ReadinessResponse{
+		Status:    "ready",
+		Timestamp: time.Now(),
+		Components: map[string]ComponentStatus{
+			"manifests": componentStatus(manifestsErr),
+			"stats":     componentStatus(checkStatsStore()),
+			"downloads": componentStatus(checkDownloadsDir()),
+		},
+	}
+
+	status := http.StatusOK
+	for _, component := range response.Components {
+		if component.Status != "ok" {
+			response.Status = "degraded"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// forceRedownloadHandler 设置或清除强制重新下载标记
+// POST/DELETE /api/manifests/{channel}/force-redownload                    频道级开关，作用于当前版本
+// POST/DELETE /api/manifests/{channel}/updates/{version}/force-redownload  指定版本
+// 文件损坏并原地替换后使用，已是该版本的客户端也会重新下载
+func forceRedownloadHandler(w http.ResponseWriter, r *http.Request, channel, version string) {
+	var enable bool
+	switch r.Method {
+	case http.MethodPost:
+		enable = true
+	case http.MethodDelete:
+		enable = false
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		log.Printf("Error reading manifest: %v", err)
+		return
+	}
+
+	target := channel
+	if version == "" {
+		manifest.ForceRedownload = enable
+	} else {
+		update := findUpdate(manifest, version)
+		if update == nil {
+			http.Error(w, "Version not found", http.StatusNotFound)
+			return
+		}
+		update.ForceRedownload = enable
+		target = channel + "/" + version
+	}
+	manifest.LastUpdated = time.Now()
+
+	// 标记计入清单内容哈希，保存后 ETag 随之变化，客户端缓存失效
+	err = saveManifest(channel, manifest)
+	if errors.Is(err, errInvalidManifest) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save manifest", http.StatusInternalServerError)
+		log.Printf("Error saving manifest: %v", err)
+		return
+	}
+
+	if enable {
+		addActivity("force-redownload", fmt.Sprintf("Forced re-download of %s", target))
+		log.Printf("Force re-download enabled: %s", target)
+	} else {
+		addActivity("force-redownload", fmt.Sprintf("Cleared forced re-download of %s", target))
+		log.Printf("Force re-download cleared: %s", target)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "success",
+		"forceRedownload": enable,
+		"contentHash":     manifest.ContentHash,
+	})
+}
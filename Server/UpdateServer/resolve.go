@@ -0,0 +1,283 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// modDependencyPrefix 依赖项中模组的前缀，如 "mod:minimap" 或 "mod:minimap@2.1.0"
+const modDependencyPrefix = "mod:"
+
+// 依赖图无法满足的原因
+const (
+	DependencyMissing = "missing"
+	DependencyYanked  = "yanked"
+	DependencyCycle   = "cycle"
+)
+
+// Artifact 客户端需要下载的一个文件，依赖排在依赖它的文件之前
+type Artifact struct {
+	// Kind 为 "release"（同频道的版本）或 "mod"（模组的最新版本）
+	Kind        string `json:"kind"`
+	ID          string `json:"id"`
+	Version     string `json:"version"`
+	DownloadUrl string `json:"downloadUrl"`
+	FileHash    string `json:"fileHash,omitempty"`
+	FileSize    int64  `json:"fileSize,omitempty"`
+}
+
+// ResolveResponse 依赖解析结果
+type ResolveResponse struct {
+	Channel   string     `json:"channel"`
+	Version   string     `json:"version"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// DependencyError 依赖图无法满足，Path 为从目标版本到出问题的依赖的路径
+type DependencyError struct {
+	Reason string   `json:"reason"`
+	Path   []string `json:"path"`
+}
+
+func (e *DependencyError) Error() string {
+	return fmt.Sprintf("unsatisfiable dependencies (%s): %s", e.Reason, strings.Join(e.Path, " → "))
+}
+
+// dependency 解析后的依赖项
+type dependency struct {
+	mod     bool
+	id      string // 模组ID，版本依赖时为空
+	version string // 版本依赖的版本；模组依赖要求的版本，可为空
+}
+
+// parseDependency 解析依赖项：同频道的版本号（如 "1.2.0"），或 "mod:<模组ID>[@<版本>]"
+func parseDependency(dep string) (dependency, error) {
+	if rest, ok := strings.CutPrefix(dep, modDependencyPrefix); ok {
+		id, version, hasVersion := strings.Cut(rest, "@")
+		if !isValidModID(id) {
+			return dependency{}, fmt.Errorf("invalid mod id %q", id)
+		}
+		if hasVersion {
+			if _, err := parseSemver(version); err != nil {
+				return dependency{}, fmt.Errorf("invalid mod version %q: %v", version, err)
+			}
+		}
+		return dependency{mod: true, id: id, version: version}, nil
+	}
+	if _, err := parseSemver(dep); err != nil {
+		return dependency{}, fmt.Errorf("invalid version: %v", err)
+	}
+	return dependency{version: dep}, nil
+}
+
+// isValidModID 模组ID只含字母、数字、连字符、下划线和点，且不是 "." 或 ".."
+func isValidModID(id string) bool {
+	if id == "" || id == "." || id == ".." || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// dependencyResolver 在一个频道内按深度优先遍历依赖图，后序输出即为下载顺序
+type dependencyResolver struct {
+	manifest  *UpdateManifest
+	platform  string
+	artifacts []Artifact
+	done      map[string]bool
+	// visiting 当前递归路径上的节点，再次遇到即为循环依赖
+	visiting map[string]bool
+	path     []string
+}
+
+// resolveDependencies 返回安装 target 所需的全部文件，依赖在前、target 本身在最后
+func resolveDependencies(manifest *UpdateManifest, target *UpdateInfo, platform string) ([]Artifact, error) {
+	resolver := &dependencyResolver{
+		manifest: manifest,
+		platform: platform,
+		done:     make(map[string]bool),
+		visiting: make(map[string]bool),
+	}
+	if err := resolver.visitRelease(target); err != nil {
+		return nil, err
+	}
+	return resolver.artifacts, nil
+}
+
+// enter 将节点加入当前路径，已处理过的节点返回 false
+func (d *dependencyResolver) enter(key string) (bool, error) {
+	if d.visiting[key] {
+		return false, &DependencyError{Reason: DependencyCycle, Path: append(append([]string{}, d.path...), key)}
+	}
+	if d.done[key] {
+		return false, nil
+	}
+	d.visiting[key] = true
+	d.path = append(d.path, key)
+	return true, nil
+}
+
+// leave 节点的依赖处理完毕，输出该节点
+func (d *dependencyResolver) leave(key string, artifact Artifact) {
+	delete(d.visiting, key)
+	d.path = d.path[:len(d.path)-1]
+	d.done[key] = true
+	d.artifacts = append(d.artifacts, artifact)
+}
+
+// fail 返回指向 key 的依赖错误
+func (d *dependencyResolver) fail(reason, key string) error {
+	return &DependencyError{Reason: reason, Path: append(append([]string{}, d.path...), key)}
+}
+
+func (d *dependencyResolver) visitRelease(release *UpdateInfo) error {
+	key := release.Version
+	if ok, err := d.enter(key); !ok {
+		return err
+	}
+	if err := d.visitDependencies(release.Dependencies); err != nil {
+		return err
+	}
+
+	artifact := Artifact{Kind: "release", ID: release.Version, Version: release.Version}
+	if asset, ok := assetForPlatform(*release, d.platform); ok {
+		artifact.DownloadUrl = asset.Url
+		artifact.FileHash = asset.Hash
+		artifact.FileSize = asset.Size
+	}
+	d.leave(key, artifact)
+	return nil
+}
+
+func (d *dependencyResolver) visitMod(dep dependency) error {
+	key := modDependencyPrefix + dep.id
+	// 未指定版本时使用 latest.json，指定版本时使用该版本的 info.json
+	release, err := loadModInfo(dep.id, dep.version)
+	if errors.Is(err, os.ErrNotExist) && dep.version != "" {
+		return d.fail(DependencyMissing, key+"@"+dep.version)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return d.fail(DependencyMissing, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	if ok, err := d.enter(key); !ok {
+		return err
+	}
+	if err := d.visitDependencies(release.Dependencies); err != nil {
+		return err
+	}
+	d.leave(key, Artifact{
+		Kind:        "mod",
+		ID:          dep.id,
+		Version:     release.Version,
+		DownloadUrl: release.DownloadUrl,
+		FileHash:    release.FileHash,
+		FileSize:    release.FileSize,
+	})
+	return nil
+}
+
+func (d *dependencyResolver) visitDependencies(deps []string) error {
+	for _, raw := range deps {
+		dep, err := parseDependency(raw)
+		if err != nil {
+			return d.fail(DependencyMissing, raw)
+		}
+		if dep.mod {
+			if err := d.visitMod(dep); err != nil {
+				return err
+			}
+			continue
+		}
+
+		release := findUpdate(d.manifest, dep.version)
+		if release == nil {
+			return d.fail(DependencyMissing, dep.version)
+		}
+		if release.Yanked {
+			return d.fail(DependencyYanked, dep.version)
+		}
+		if err := d.visitRelease(release); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDependencyError 依赖图无法满足时返回 422，其他错误返回 500
+func writeDependencyError(w http.ResponseWriter, err error) {
+	var depErr *DependencyError
+	if !errors.As(err, &depErr) {
+		http.Error(w, "Failed to resolve dependencies", http.StatusInternalServerError)
+		log.Printf("Error resolving dependencies: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  depErr.Error(),
+		"reason": depErr.Reason,
+		"path":   depErr.Path,
+	})
+}
+
+// resolveHandler 返回安装指定版本需要下载的全部文件（按依赖顺序）
+// GET /api/resolve?channel=stable&version=1.2.0&platform=windows，version 缺省为频道当前版本
+func resolveHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	channel := query.Get("channel")
+	if channel == "" {
+		channel = "stable"
+	}
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := loadPublicManifest(channel)
+	if err != nil {
+		http.Error(w, "Manifest not found", http.StatusNotFound)
+		return
+	}
+
+	var target *UpdateInfo
+	if version := query.Get("version"); version != "" {
+		target = findUpdate(manifest, version)
+	} else {
+		target = currentRelease(manifest)
+	}
+	if target == nil {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	artifacts, err := resolveDependencies(manifest, target, query.Get("platform"))
+	if err != nil {
+		writeDependencyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ResolveResponse{
+		Channel:   channel,
+		Version:   target.Version,
+		Artifacts: artifacts,
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// applyRetention 按频道保留策略清理最旧的非强制版本
+// LatestVersion、MinimumVersion 和强制更新版本永远不会被清理，返回被移除的条目
+// 被移除的条目仍保留在清单历史归档中
+func applyRetention(channel string, manifest *UpdateManifest) []UpdateInfo {
+	policy, ok := config.Retention[channel]
+	if !ok || policy.MaxRetainedVersions <= 0 || len(manifest.Updates) <= policy.MaxRetainedVersions {
+		return nil
+	}
+
+	// 按版本从旧到新排列候选条目
+	candidates := make([]int, 0, len(manifest.Updates))
+	for i, update := range manifest.Updates {
+		if update.Version == manifest.LatestVersion || update.Version == manifest.MinimumVersion || update.IsMandatory {
+			continue
+		}
+		candidates = append(candidates, i)
+	}
+	sort.Slice(candidates, func(a, b int) bool {
+		return compareVersions(manifest.Updates[candidates[a]].Version, manifest.Updates[candidates[b]].Version) < 0
+	})
+
+	excess := len(manifest.Updates) - policy.MaxRetainedVersions
+	if excess > len(candidates) {
+		excess = len(candidates)
+	}
+
+	remove := make(map[int]bool, excess)
+	for _, idx := range candidates[:excess] {
+		remove[idx] = true
+	}
+
+	var pruned []UpdateInfo
+	kept := make([]UpdateInfo, 0, len(manifest.Updates)-excess)
+	for i, update := range manifest.Updates {
+		if remove[i] {
+			pruned = append(pruned, update)
+			continue
+		}
+		kept = append(kept, update)
+	}
+	manifest.Updates = kept
+	return pruned
+}
+
+// finishPrune 清单保存成功后记录被清理的版本，并按策略删除不再被任何清单引用的文件
+func finishPrune(channel string, pruned []UpdateInfo) {
+	if len(pruned) == 0 {
+		return
+	}
+
+	policy := config.Retention[channel]
+	for _, update := range pruned {
+		addActivity("prune", fmt.Sprintf("Pruned %s/%s (retention %d)", channel, update.Version, policy.MaxRetainedVersions))
+		log.Printf("Version pruned by retention policy: %s/%s", channel, update.Version)
+	}
+
+	if !policy.DeleteFiles {
+		return
+	}
+
+	referenced := referencedFiles()
+	var names []string
+	for _, update := range pruned {
+		names = append(names, releaseFiles(update)...)
+	}
+	for _, name := range names {
+		if referenced[name] != nil {
+			continue
+		}
+
+		filePath := filepath.Join(DownloadsDir, name)
+		info, err := os.Stat(filePath)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if err := os.Remove(filePath); err != nil {
+			log.Printf("Error deleting pruned file %s: %v", name, err)
+			continue
+		}
+
+		invalidateFileHash(filePath)
+		invalidateChecksums()
+		adjustStorageStats(-info.Size(), -1)
+		unstageFile(name)
+		addActivity("delete", fmt.Sprintf("Deleted pruned file: %s", name))
+	}
+}
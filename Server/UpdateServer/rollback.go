@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// rollbackHandler 将频道的最新版本指回清单中已有的旧版本
+// 回滚只修改 latestVersion，出问题的版本条目保留在清单中，便于追溯和之后重新发布
+// POST /api/rollback {"channel": "stable", "version": "1.2.0"}
+func rollbackHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Channel string `json:"channel"`
+		Version string `json:"version"`
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !isValidChannel(req.Channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+	if req.Version == "" {
+		http.Error(w, "Missing version", http.StatusBadRequest)
+		return
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest, err := loadManifest(req.Channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		log.Printf("Error reading manifest: %v", err)
+		return
+	}
+
+	target := findUpdate(manifest, req.Version)
+	if target == nil {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	// 撤回的版本不会被客户端选中，回滚到它没有意义
+	if target.Yanked {
+		http.Error(w, "Version is yanked", http.StatusConflict)
+		return
+	}
+
+	previous := manifest.LatestVersion
+	if previous == req.Version {
+		http.Error(w, "Version is already latest", http.StatusConflict)
+		return
+	}
+
+	manifest.LatestVersion = req.Version
+	manifest.LastUpdated = time.Now()
+
+	err = saveManifest(req.Channel, manifest)
+	if errors.Is(err, errInvalidManifest) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save manifest", http.StatusInternalServerError)
+		log.Printf("Error saving manifest: %v", err)
+		return
+	}
+
+	rolledBackBy := "unknown"
+	if user, ok := currentUser(r); ok {
+		rolledBackBy = user.Username
+	}
+	addActivity("rollback", fmt.Sprintf("Rolled back %s from %s to %s (by %s)", req.Channel, previous, req.Version, rolledBackBy))
+	log.Printf("Channel rolled back: %s %s -> %s by %s", req.Channel, previous, req.Version, rolledBackBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":          "success",
+		"channel":         req.Channel,
+		"previousVersion": previous,
+		"latestVersion":   manifest.LatestVersion,
+	})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// QuarantineDir 扫描未通过的上传文件隔离目录
+const QuarantineDir = "./quarantine"
+
+// UploadScanConfig 上传文件扫描配置，Command 与 ClamdAddress 都为空时不扫描
+type UploadScanConfig struct {
+	// Command 扫描命令，参数中的 {file} 替换为文件路径；退出码0表示通过
+	Command []string `json:"command"`
+	// ClamdAddress ClamAV守护进程地址，如 "unix:/run/clamav/clamd.ctl" 或 "tcp:127.0.0.1:3310"
+	ClamdAddress string `json:"clamdAddress"`
+	// Timeout 单次扫描超时
+	Timeout Duration `json:"timeout"`
+}
+
+// errScanFailed 扫描发现问题
+var errScanFailed = errors.New("scan failed")
+
+// scanEnabled 是否配置了上传扫描
+func scanEnabled() bool {
+	return len(config.UploadScan.Command) > 0 || config.UploadScan.ClamdAddress != ""
+}
+
+// scanUploadedFile 扫描上传的文件，返回扫描结果描述
+// 扫描器发现问题、出错或超时都视为未通过
+func scanUploadedFile(filePath string) (string, error) {
+	timeout := config.UploadScan.Timeout.Duration
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if config.UploadScan.ClamdAddress != "" {
+		return scanWithClamd(ctx, config.UploadScan.ClamdAddress, filePath)
+	}
+	return scanWithCommand(ctx, config.UploadScan.Command, filePath)
+}
+
+// scanWithCommand 调用外部扫描命令
+func scanWithCommand(ctx context.Context, command []string, filePath string) (string, error) {
+	args := make([]string, len(command))
+	replaced := false
+	for i, arg := range command {
+		if strings.Contains(arg, "{file}") {
+			replaced = true
+		}
+		args[i] = strings.ReplaceAll(arg, "{file}", filePath)
+	}
+	if !replaced {
+		args = append(args, filePath)
+	}
+
+	output, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+	result := strings.TrimSpace(string(output))
+	if ctx.Err() != nil {
+		return result, fmt.Errorf("%w: timeout", errScanFailed)
+	}
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", errScanFailed, err)
+	}
+	return result, nil
+}
+
+// scanWithClamd 通过 clamd INSTREAM 协议扫描文件内容
+func scanWithClamd(ctx context.Context, address, filePath string) (string, error) {
+	network, addr, ok := strings.Cut(address, ":")
+	if !ok || (network != "unix" && network != "tcp") {
+		return "", fmt.Errorf("%w: invalid clamd address %q", errScanFailed, address)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errScanFailed, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return "", fmt.Errorf("%w: %v", errScanFailed, err)
+	}
+
+	buf := make([]byte, 64<<10)
+	var size [4]byte
+	for {
+		n, readErr := file.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := conn.Write(size[:]); err != nil {
+				return "", fmt.Errorf("%w: %v", errScanFailed, err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return "", fmt.Errorf("%w: %v", errScanFailed, err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return "", readErr
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := conn.Write(size[:]); err != nil {
+		return "", fmt.Errorf("%w: %v", errScanFailed, err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return "", fmt.Errorf("%w: %v", errScanFailed, err)
+	}
+	result := strings.TrimRight(reply, "\x00\n")
+	if !strings.HasSuffix(result, " OK") {
+		return result, fmt.Errorf("%w: %s", errScanFailed, result)
+	}
+	return result, nil
+}
+
+// quarantineFile 将文件以 name 为名移入隔离目录，返回隔离后的路径
+func quarantineFile(filePath, name string) (string, error) {
+	if err := os.MkdirAll(QuarantineDir, 0755); err != nil {
+		return "", err
+	}
+
+	target := filepath.Join(QuarantineDir, fmt.Sprintf("%s.%d", filepath.Base(name), time.Now().Unix()))
+	if err := moveFile(filePath, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// scanBeforePublish 在文件移入对外目录前扫描上传暂存目录中的临时文件，未配置扫描时直接通过；
+// 未通过时将临时文件以 name 移入隔离目录（移动失败则删除）并写出 422 响应，已发布的同名文件不受影响
+func scanBeforePublish(w http.ResponseWriter, tempPath, name string) (string, bool) {
+	if !scanEnabled() {
+		return "", true
+	}
+
+	result, err := scanUploadedFile(tempPath)
+	if err == nil {
+		log.Printf("Upload scan passed: %s (%s)", name, result)
+		return result, true
+	}
+
+	quarantined, qErr := quarantineFile(tempPath, name)
+	if qErr != nil {
+		os.Remove(tempPath)
+		log.Printf("Error quarantining %s: %v", name, qErr)
+	}
+	log.Printf("Upload scan failed: %s -> %s: %v (%s)", name, quarantined, err, result)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":  "File rejected by scanner",
+		"result": result,
+	})
+	return result, false
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ScheduledPublishesFile 待发布清单存储文件
+const ScheduledPublishesFile = "./scheduled.json"
+
+// schedulerInterval 检查到期发布的间隔
+const schedulerInterval = 10 * time.Second
+
+// 定时发布状态
+const (
+	SchedulePending = "pending"
+	ScheduleFailed  = "failed"
+)
+
+// ScheduledPublish 定时发布的清单
+type ScheduledPublish struct {
+	ID        string    `json:"id"`
+	Channel   string    `json:"channel"`
+	PublishAt time.Time `json:"publishAt"`
+	CreatedAt time.Time `json:"createdAt"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	// OverrideCadence 创建时指定了 ?override=true，到期发布时不检查最小发布间隔
+	OverrideCadence bool            `json:"overrideCadence,omitempty"`
+	Manifest        *UpdateManifest `json:"manifest"`
+}
+
+var (
+	scheduledPublishes   []ScheduledPublish
+	scheduledPublishesMu sync.Mutex
+	scheduledLoadOnce    sync.Once
+)
+
+// loadScheduledPublishesLocked 首次访问时加载定时发布，调用方需持有锁
+func loadScheduledPublishesLocked() {
+	scheduledLoadOnce.Do(func() {
+		data, err := os.ReadFile(ScheduledPublishesFile)
+		if err != nil {
+			return
+		}
+		if err := json.Unmarshal(data, &scheduledPublishes); err != nil {
+			log.Printf("Error loading scheduled publishes: %v", err)
+		}
+	})
+}
+
+// saveScheduledPublishesLocked 保存定时发布，调用方需持有锁
+func saveScheduledPublishesLocked() error {
+	data, err := json.MarshalIndent(scheduledPublishes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(ScheduledPublishesFile, data, 0644)
+}
+
+// scheduleHandler 定时发布频道清单；与立即发布相同地校验版本号、清单和发布节奏，到期发布时再校验一次
+// POST /api/manifests/{channel}/schedule[?override=true] {"publishAt": "2025-12-01T10:00:00+08:00", "manifest": {...}}
+func scheduleHandler(w http.ResponseWriter, r *http.Request, channel string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		PublishAt time.Time       `json:"publishAt"`
+		Manifest  *UpdateManifest `json:"manifest"`
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Manifest == nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !req.PublishAt.After(time.Now()) {
+		http.Error(w, "publishAt must be in the future", http.StatusBadRequest)
+		return
+	}
+
+	// 提前校验，避免到发布时才发现清单无效
+	if err := validateManifestVersions(req.Manifest); err != nil {
+		writeVersionFieldError(w, err)
+		return
+	}
+	override := r.URL.Query().Get("override") == "true"
+	if !override {
+		if err := checkCadenceAt(channel, req.PublishAt); err != nil {
+			writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+	}
+	preview := *req.Manifest
+	preview.Updates = append([]UpdateInfo(nil), req.Manifest.Updates...)
+	normalizeManifest(&preview)
+	if err := validateManifest(channel, &preview); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, "Failed to create schedule", http.StatusInternalServerError)
+		log.Printf("Error generating schedule ID: %v", err)
+		return
+	}
+	entry := ScheduledPublish{
+		ID:              hex.EncodeToString(id),
+		Channel:         channel,
+		PublishAt:       req.PublishAt,
+		CreatedAt:       time.Now(),
+		Status:          SchedulePending,
+		OverrideCadence: override,
+		Manifest:        req.Manifest,
+	}
+	if override {
+		addActivity("cadence-override", fmt.Sprintf("Scheduled %s publish %s overrides release cadence", channel, entry.ID))
+	}
+
+	scheduledPublishesMu.Lock()
+	loadScheduledPublishesLocked()
+	scheduledPublishes = append(scheduledPublishes, entry)
+	err := saveScheduledPublishesLocked()
+	scheduledPublishesMu.Unlock()
+	if err != nil {
+		http.Error(w, "Failed to save schedule", http.StatusInternalServerError)
+		log.Printf("Error saving scheduled publishes: %v", err)
+		return
+	}
+
+	addActivity("schedule", fmt.Sprintf("Scheduled %s publish at %s", channel, entry.PublishAt.Format(time.RFC3339)))
+	log.Printf("Manifest publish scheduled: %s at %s (%s)", channel, entry.PublishAt.Format(time.RFC3339), entry.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// scheduledHandler 查看或取消定时发布
+// GET    /api/manifests/scheduled
+// DELETE /api/manifests/scheduled/{id}
+func scheduledHandler(w http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 0 && r.Method == http.MethodGet:
+		scheduledPublishesMu.Lock()
+		loadScheduledPublishesLocked()
+		entries := append([]ScheduledPublish{}, scheduledPublishes...)
+		scheduledPublishesMu.Unlock()
+
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].PublishAt.Before(entries[j].PublishAt)
+		})
+		writeList(w, r, entries)
+
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		scheduledPublishesMu.Lock()
+		loadScheduledPublishesLocked()
+		var cancelled *ScheduledPublish
+		for i := range scheduledPublishes {
+			if scheduledPublishes[i].ID == parts[0] {
+				entry := scheduledPublishes[i]
+				cancelled = &entry
+				scheduledPublishes = append(scheduledPublishes[:i], scheduledPublishes[i+1:]...)
+				break
+			}
+		}
+		var err error
+		if cancelled != nil {
+			err = saveScheduledPublishesLocked()
+		}
+		scheduledPublishesMu.Unlock()
+
+		if cancelled == nil {
+			http.Error(w, "Schedule not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to save schedule", http.StatusInternalServerError)
+			log.Printf("Error saving scheduled publishes: %v", err)
+			return
+		}
+
+		addActivity("schedule", fmt.Sprintf("Cancelled scheduled %s publish %s", cancelled.Channel, cancelled.ID))
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+
+	case len(parts) <= 1:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+	default:
+		apiNotFoundHandler(w, r)
+	}
+}
+
+// schedulerLoop 定期发布到期的清单
+func schedulerLoop() {
+	ticker := time.NewTicker(schedulerInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		runDuePublishes(time.Now())
+		publishDueStagedFiles(time.Now())
+		pruneRateLimitBuckets(time.Now())
+		pruneDownloadKeys(time.Now())
+	}
+}
+
+// publishScheduled 发布到期的定时清单：创建后发布节奏和清单内容可能已变化，发布前重新校验版本号与最小发布间隔
+func publishScheduled(entry ScheduledPublish, now time.Time) error {
+	if err := validateManifestVersions(entry.Manifest); err != nil {
+		return err
+	}
+	if !entry.OverrideCadence {
+		if err := checkCadenceAt(entry.Channel, now); err != nil {
+			return err
+		}
+	}
+	return publishManifest(entry.Channel, entry.Manifest)
+}
+
+// runDuePublishes 发布所有到期的清单，失败的条目保留并标记错误
+func runDuePublishes(now time.Time) {
+	scheduledPublishesMu.Lock()
+	defer scheduledPublishesMu.Unlock()
+	loadScheduledPublishesLocked()
+
+	remaining := scheduledPublishes[:0]
+	changed := false
+	for _, entry := range scheduledPublishes {
+		if entry.Status != SchedulePending || entry.PublishAt.After(now) {
+			remaining = append(remaining, entry)
+			continue
+		}
+		changed = true
+
+		if err := publishScheduled(entry, now); err != nil {
+			entry.Status = ScheduleFailed
+			entry.Error = err.Error()
+			remaining = append(remaining, entry)
+			addActivity("publish", fmt.Sprintf("Scheduled %s publish %s failed: %v", entry.Channel, entry.ID, err))
+			log.Printf("Scheduled publish failed: %s (%s): %v", entry.Channel, entry.ID, err)
+			continue
+		}
+
+		addActivity("publish", fmt.Sprintf("Published scheduled manifest: %s (%s)", entry.Channel, entry.ID))
+		log.Printf("Scheduled manifest published: %s (%s)", entry.Channel, entry.ID)
+	}
+	scheduledPublishes = remaining
+
+	if changed {
+		if err := saveScheduledPublishesLocked(); err != nil {
+			log.Printf("Error saving scheduled publishes: %v", err)
+		}
+	}
+}
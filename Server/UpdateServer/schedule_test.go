@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupScheduleTest 在已提交 stable 清单的临时目录中准备空的定时发布列表，stable 频道最小发布间隔为1小时且刚刚发布过
+func setupScheduleTest(t *testing.T) {
+	t.Helper()
+	setupManifestTest(t)
+	config.ReleaseCadence = map[string]Duration{"stable": {time.Hour}}
+
+	scheduledPublishesMu.Lock()
+	loadScheduledPublishesLocked()
+	old := scheduledPublishes
+	scheduledPublishes = nil
+	scheduledPublishesMu.Unlock()
+	t.Cleanup(func() {
+		scheduledPublishesMu.Lock()
+		scheduledPublishes = old
+		scheduledPublishesMu.Unlock()
+		lastPublishesMu.Lock()
+		delete(lastPublishes, "stable")
+		lastPublishesMu.Unlock()
+	})
+	recordPublish("stable", time.Now())
+}
+
+// schedule 提交定时发布，返回状态码和响应体
+func schedule(t *testing.T, query string, publishAt time.Time, manifest *UpdateManifest) (int, string) {
+	t.Helper()
+	body, err := json.Marshal(map[string]any{"publishAt": publishAt, "manifest": manifest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/api/manifests/stable/schedule"+query, bytes.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	scheduleHandler(w, r, "stable")
+	return w.Code, w.Body.String()
+}
+
+func TestScheduleValidatesVersionsAndCadence(t *testing.T) {
+	setupScheduleTest(t)
+
+	badVersion := testManifest("2.0")
+	tests := []struct {
+		name      string
+		query     string
+		publishAt time.Duration
+		manifest  *UpdateManifest
+		want      int
+	}{
+		{"invalid version", "", 2 * time.Hour, badVersion, http.StatusBadRequest},
+		{"before cadence allows", "", 10 * time.Minute, testManifest("2.0.0"), http.StatusUnprocessableEntity},
+		{"cadence overridden", "?override=true", 10 * time.Minute, testManifest("2.0.0"), http.StatusCreated},
+		{"after cadence allows", "", 2 * time.Hour, testManifest("2.0.0"), http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, body := schedule(t, tt.query, time.Now().Add(tt.publishAt), tt.manifest)
+			if code != tt.want {
+				t.Errorf("schedule = %d, want %d: %s", code, tt.want, body)
+			}
+		})
+	}
+}
+
+func TestRunDuePublishesRevalidates(t *testing.T) {
+	setupScheduleTest(t)
+	now := time.Now()
+
+	// 直接写入的条目模拟创建后才失效的定时发布（手动编辑 scheduled.json、发布节奏在此期间被其他发布占用）
+	scheduledPublishesMu.Lock()
+	scheduledPublishes = []ScheduledPublish{
+		{ID: "bad-version", Channel: "stable", PublishAt: now, Status: SchedulePending, Manifest: testManifest("2.0"), OverrideCadence: true},
+		{ID: "too-early", Channel: "stable", PublishAt: now, Status: SchedulePending, Manifest: testManifest("2.0.0")},
+	}
+	scheduledPublishesMu.Unlock()
+
+	runDuePublishes(now)
+
+	scheduledPublishesMu.Lock()
+	entries := append([]ScheduledPublish{}, scheduledPublishes...)
+	scheduledPublishesMu.Unlock()
+	if len(entries) != 2 {
+		t.Fatalf("%d entries remain, want both failed entries kept", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.Status != ScheduleFailed {
+			t.Errorf("%s: status %q, want failed", entry.ID, entry.Status)
+		}
+	}
+	if !strings.Contains(entries[1].Error, errCadenceInEffect.Error()) {
+		t.Errorf("too-early error = %q, want cadence error", entries[1].Error)
+	}
+	if stored, err := loadManifest("stable"); err != nil || stored.LatestVersion != "1.0.0" {
+		t.Errorf("stable manifest changed to %+v (%v)", stored, err)
+	}
+
+	// 覆盖发布节奏的有效条目按时发布
+	scheduledPublishesMu.Lock()
+	scheduledPublishes = []ScheduledPublish{
+		{ID: "override", Channel: "stable", PublishAt: now, Status: SchedulePending, Manifest: testManifest("2.0.0"), OverrideCadence: true},
+	}
+	scheduledPublishesMu.Unlock()
+	runDuePublishes(now)
+	if stored, err := loadManifest("stable"); err != nil || stored.LatestVersion != "2.0.0" {
+		t.Errorf("stable manifest = %+v (%v), want 2.0.0 published", stored, err)
+	}
+}
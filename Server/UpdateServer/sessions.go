@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SessionCookieName 管理面板会话Cookie名
+	SessionCookieName = "lizard_session"
+	// DefaultSessionTTL 未配置 sessionTtl 时的会话有效期
+	DefaultSessionTTL = 12 * time.Hour
+)
+
+// sessionClaims 会话Cookie中签名的内容
+type sessionClaims struct {
+	User      string `json:"u"`
+	ID        string `json:"id"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var (
+	// ephemeralSessionSecret 未配置 sessionSecrets 时使用的临时密钥，重启后会话失效
+	ephemeralSessionSecret     []byte
+	ephemeralSessionSecretOnce sync.Once
+
+	// revokedSessions 已退出登录但尚未过期的会话
+	revokedSessions   = make(map[string]time.Time)
+	revokedSessionsMu sync.Mutex
+)
+
+// sessionSecrets 返回会话签名密钥，第一个用于签发，全部用于校验（便于轮换）
+func sessionSecrets() [][]byte {
+	if len(config.SessionSecrets) > 0 {
+		secrets := make([][]byte, 0, len(config.SessionSecrets))
+		for _, secret := range config.SessionSecrets {
+			secrets = append(secrets, []byte(secret))
+		}
+		return secrets
+	}
+
+	ephemeralSessionSecretOnce.Do(func() {
+		ephemeralSessionSecret = make([]byte, 32)
+		if _, err := rand.Read(ephemeralSessionSecret); err != nil {
+			log.Fatalf("Failed to generate session secret: %v", err)
+		}
+		log.Printf("No sessionSecrets configured, sessions will not survive a restart")
+	})
+	return [][]byte{ephemeralSessionSecret}
+}
+
+// sessionTTL 返回会话有效期
+func sessionTTL() time.Duration {
+	if config.SessionTTL.Duration > 0 {
+		return config.SessionTTL.Duration
+	}
+	return DefaultSessionTTL
+}
+
+// signSession 计算会话内容的HMAC
+func signSession(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issueSession 为账号签发会话并写入 HttpOnly Cookie
+func issueSession(w http.ResponseWriter, r *http.Request, username string) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	ttl := sessionTTL()
+	data, err := json.Marshal(sessionClaims{
+		User:      username,
+		ID:        hex.EncodeToString(id),
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    payload + "." + signSession(sessionSecrets()[0], payload),
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+// parseSession 校验会话Cookie的签名与有效期
+func parseSession(r *http.Request) (*sessionClaims, bool) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return nil, false
+	}
+
+	payload, signature, found := strings.Cut(cookie.Value, ".")
+	if !found {
+		return nil, false
+	}
+
+	valid := false
+	for _, secret := range sessionSecrets() {
+		if hmac.Equal([]byte(signature), []byte(signSession(secret, payload))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return nil, false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, false
+	}
+	var claims sessionClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, false
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, false
+	}
+	// 账号已从 users.json 移除的会话随之失效
+	if _, ok := lookupUser(claims.User); !ok {
+		return nil, false
+	}
+
+	revokedSessionsMu.Lock()
+	_, revoked := revokedSessions[claims.ID]
+	revokedSessionsMu.Unlock()
+	return &claims, !revoked
+}
+
+// sessionUser 返回请求中有效会话对应的账号
+func sessionUser(r *http.Request) (AdminUser, bool) {
+	claims, ok := parseSession(r)
+	if !ok {
+		return AdminUser{}, false
+	}
+	return lookupUser(claims.User)
+}
+
+// isPanelPath 路径是否属于管理面板（/admin 及其子路径），会话Cookie只在这些路径下作为认证凭据
+func isPanelPath(path string) bool {
+	return path == "/admin" || strings.HasPrefix(path, "/admin/")
+}
+
+// panelAPI 管理面板经 /admin/api/ 调用管理API：校验会话后去掉 /admin 前缀交给 mux 中对应的 /api/ 路由。
+// /api/ 路由本身不接受会话Cookie，跨站页面无法借用管理员的浏览器会话
+func panelAPI(mux http.Handler) http.HandlerFunc {
+	api := http.StripPrefix("/admin", mux)
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := sessionUser(r)
+		if !ok {
+			writeJSONError(w, http.StatusUnauthorized, "Session required")
+			return
+		}
+		api.ServeHTTP(w, withAdminUser(r, user))
+	}
+}
+
+// validSession 检查请求是否携带有效会话
+func validSession(r *http.Request) bool {
+	_, ok := sessionUser(r)
+	return ok
+}
+
+// revokeSession 使会话在过期前失效
+func revokeSession(claims *sessionClaims) {
+	now := time.Now()
+
+	revokedSessionsMu.Lock()
+	defer revokedSessionsMu.Unlock()
+
+	for id, expiresAt := range revokedSessions {
+		if now.After(expiresAt) {
+			delete(revokedSessions, id)
+		}
+	}
+	revokedSessions[claims.ID] = time.Unix(claims.ExpiresAt, 0)
+}
+
+// loginHandler 校验管理员密码并签发会话
+// GET  /admin/login  登录页
+// POST /admin/login  表单或JSON {"username", "password"}
+func loginHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		loginPageHandler(w, r)
+		return
+	case http.MethodPost:
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	// 登录页提交表单，其余请求与管理API一样要求 JSON
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	isJSON := mediaType != "application/x-www-form-urlencoded"
+	if isJSON {
+		if !requireJSON(w, r) {
+			return
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	} else {
+		req.Username = r.PostFormValue("username")
+		req.Password = r.PostFormValue("password")
+	}
+
+	user, ok := checkAdminCredentials(req.Username, req.Password)
+	if !ok {
+		log.Printf("Failed panel login for %q from %s", req.Username, r.RemoteAddr)
+		if isJSON {
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		} else {
+			http.Redirect(w, r, "/admin/login?error=1", http.StatusSeeOther)
+		}
+		return
+	}
+
+	if err := issueSession(w, r, user.Username); err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Panel login: %s (%s)", user.Username, user.Role)
+
+	if isJSON {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+		return
+	}
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
+
+// logoutHandler 清除会话Cookie
+// POST /admin/logout
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if claims, ok := parseSession(r); ok {
+		revokeSession(claims)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isSecureRequest(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.Redirect(w, r, "/admin/login", http.StatusSeeOther)
+}
+
+// isSecureRequest 判断请求是否经由HTTPS；X-Forwarded-Proto 可由客户端伪造，只在开启 trustForwardedHeaders 时采用
+func isSecureRequest(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return config.TrustForwardedHeaders && r.Header.Get("X-Forwarded-Proto") == "https"
+}
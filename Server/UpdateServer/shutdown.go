@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// DefaultShutdownTimeout 收到退出信号后等待进行中请求的默认时长，低于 Kubernetes 默认的 30 秒终止宽限期
+const DefaultShutdownTimeout = 25 * time.Second
+
+// serveUntilSignal 运行服务器直到收到 SIGINT/SIGTERM；退出时停止接受新连接，
+// 在 shutdownTimeout 内等待进行中的请求（包括下载）完成，最后写入统计数据与哈希缓存
+func serveUntilSignal(server *http.Server, serve func() error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- serve() }()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	// 再次收到信号时按默认行为立即退出
+	stop()
+
+	timeout := config.ShutdownTimeout.Duration
+	log.Printf("Shutting down, waiting up to %s for in-flight requests", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown grace period expired, closing remaining connections: %v", err)
+		server.Close()
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Server error during shutdown: %v", err)
+	}
+
+	statsStore.Flush()
+	saveHashCache()
+	log.Printf("Server stopped")
+	return nil
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSignedURLTTL /api/sign 未指定有效期时签发链接的有效期
+	DefaultSignedURLTTL = time.Hour
+	// MaxSignedURLTTL 签发链接的最长有效期
+	MaxSignedURLTTL = 30 * 24 * time.Hour
+)
+
+// SignedURLCheck 签名下载链接的校验结果，不包含签名密钥
+type SignedURLCheck struct {
+	Valid     bool       `json:"valid"`
+	Expired   bool       `json:"expired"`
+	Filename  string     `json:"filename,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// downloadURLSecrets 返回下载链接签名密钥，第一个用于签发，全部用于校验（便于轮换）
+func downloadURLSecrets() [][]byte {
+	secrets := make([][]byte, 0, len(config.DownloadURLSecrets))
+	for _, secret := range config.DownloadURLSecrets {
+		secrets = append(secrets, []byte(secret))
+	}
+	return secrets
+}
+
+// signDownloadURL 计算下载链接的签名，签名内容为文件名与过期时间（Unix秒）
+func signDownloadURL(secret []byte, filename string, expires int64) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(filename + "\n" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// checkSignedDownloadURL 解析 /downloads/<filename>?expires=&signature= 并校验签名与有效期
+func checkSignedDownloadURL(u *url.URL, now time.Time) SignedURLCheck {
+	var check SignedURLCheck
+
+	filename, ok := strings.CutPrefix(u.Path, "/downloads/")
+	if !ok || !filepath.IsLocal(filename) {
+		check.Reason = "not a download URL"
+		return check
+	}
+	check.Filename = filename
+
+	query := u.Query()
+	expiresParam, signature := query.Get("expires"), query.Get("signature")
+	if expiresParam == "" || signature == "" {
+		check.Reason = "missing expires or signature parameter"
+		return check
+	}
+
+	expires, err := strconv.ParseInt(expiresParam, 10, 64)
+	if err != nil {
+		check.Reason = "malformed expires parameter"
+		return check
+	}
+	expiresAt := time.Unix(expires, 0).UTC()
+	check.ExpiresAt = &expiresAt
+	check.Expired = !now.Before(expiresAt)
+
+	for _, secret := range downloadURLSecrets() {
+		if hmac.Equal([]byte(signature), []byte(signDownloadURL(secret, filename, expires))) {
+			check.Valid = true
+			break
+		}
+	}
+
+	switch {
+	case !check.Valid:
+		check.Reason = "signature mismatch"
+	case check.Expired:
+		check.Reason = "expired"
+	}
+	return check
+}
+
+// hasDownloadSignature 下载请求是否带有签名参数，两者都未提供时视为未签名的请求
+func hasDownloadSignature(r *http.Request) bool {
+	query := r.URL.Query()
+	return query.Has("expires") || query.Has("signature")
+}
+
+// authorizeDownload 校验下载请求的签名链接；未签名的请求在 requireSignedDownloads 开启时被拒绝。
+// 返回 false 时已写出 403 响应
+func authorizeDownload(w http.ResponseWriter, r *http.Request) bool {
+	if !hasDownloadSignature(r) {
+		if config.RequireSignedDownloads {
+			http.Error(w, "Signed URL required", http.StatusForbidden)
+			return false
+		}
+		return true
+	}
+
+	check := checkSignedDownloadURL(r.URL, time.Now())
+	switch {
+	case !check.Valid:
+		http.Error(w, "Invalid download signature", http.StatusForbidden)
+		log.Printf("Rejected signed download %s: %s rid=%s", r.URL.Path, check.Reason, requestID(r))
+		return false
+	case check.Expired:
+		http.Error(w, "Download link expired", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// signURLHandler 签发限时下载链接
+// POST /api/sign {"filename": "LizardClient-1.2.0.zip", "ttl": "1h"}
+func signURLHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Filename string   `json:"filename"`
+		TTL      Duration `json:"ttl"`
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if len(config.DownloadURLSecrets) == 0 {
+		writeJSONError(w, http.StatusServiceUnavailable, "Signed download URLs are not configured")
+		return
+	}
+
+	ttl := req.TTL.Duration
+	if ttl == 0 {
+		ttl = DefaultSignedURLTTL
+	}
+	if ttl < 0 || ttl > MaxSignedURLTTL {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("ttl must be between 0 and %s", MaxSignedURLTTL))
+		return
+	}
+
+	filePath, err := safeJoin(DownloadsDir, req.Filename)
+	if err != nil || req.Filename == "" || filepath.Dir(filePath) != filepath.Clean(DownloadsDir) {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second).UTC()
+	signature := signDownloadURL(downloadURLSecrets()[0], req.Filename, expiresAt.Unix())
+	link := fmt.Sprintf("%s/downloads/%s?expires=%d&signature=%s",
+		serverBaseURL(r), url.PathEscape(req.Filename), expiresAt.Unix(), signature)
+
+	signedBy := "unknown"
+	if user, ok := currentUser(r); ok {
+		signedBy = user.Username
+	}
+	log.Printf("Signed download URL for %s until %s by %s", req.Filename, expiresAt.Format(time.RFC3339), signedBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	// 链接中的 & 不转义为 \u0026，便于直接复制
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(map[string]interface{}{
+		"url":       link,
+		"filename":  req.Filename,
+		"expiresAt": expiresAt,
+	})
+}
+
+// verifySignedURLHandler 离线校验签名下载链接，用于排查客户端链接被拒绝的原因
+// POST /api/verify-signed-url {"url": "https://.../downloads/x.zip?expires=...&signature=..."}
+func verifySignedURLHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		URL string `json:"url"`
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || req.URL == "" {
+		http.Error(w, "Invalid URL", http.StatusBadRequest)
+		return
+	}
+	if len(config.DownloadURLSecrets) == 0 {
+		writeJSONError(w, http.StatusServiceUnavailable, "Signed download URLs are not configured")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checkSignedDownloadURL(u, time.Now()))
+}
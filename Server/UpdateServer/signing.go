@@ -0,0 +1,348 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SigningKeysFile 签名密钥存储文件（包含私钥，权限0600）
+const SigningKeysFile = "./keys/signing.json"
+
+// SigningKey 发布签名密钥
+type SigningKey struct {
+	ID         string    `json:"id"`
+	PublicKey  string    `json:"publicKey"`
+	PrivateKey string    `json:"privateKey,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	// ExpiresAt 轮换后的旧公钥在宽限期结束后不再公布
+	ExpiresAt time.Time `json:"expiresAt,omitempty"`
+}
+
+// signingKeyStore 当前密钥与宽限期内的旧密钥
+type signingKeyStore struct {
+	Current  *SigningKey  `json:"current"`
+	Previous []SigningKey `json:"previous"`
+}
+
+var (
+	signingKeys   signingKeyStore
+	signingKeysMu sync.RWMutex
+)
+
+// loadSigningKeys 加载签名密钥，不存在时生成新密钥；
+// 密钥文件存在但无法读取或解析时终止启动，不会用新密钥覆盖已发布版本所用的私钥
+func loadSigningKeys() {
+	signingKeysMu.Lock()
+	defer signingKeysMu.Unlock()
+
+	store, err := readSigningKeys(SigningKeysFile)
+	if err == nil {
+		signingKeys = store
+		log.Printf("Signing key loaded: %s", signingKeys.Current.ID)
+		return
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		log.Fatalf("Failed to load signing keys from %s: %v (repair or move the file aside to generate a new key)", SigningKeysFile, err)
+	}
+
+	key, err := generateSigningKey()
+	if err != nil {
+		log.Fatalf("Failed to generate signing key: %v", err)
+	}
+	signingKeys = signingKeyStore{Current: key}
+	if err := saveSigningKeysLocked(); err != nil {
+		log.Fatalf("Failed to save signing key: %v", err)
+	}
+	log.Printf("Signing key generated: %s", key.ID)
+}
+
+// readSigningKeys 读取并解析签名密钥文件，文件不存在时返回 os.ErrNotExist
+func readSigningKeys(path string) (signingKeyStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return signingKeyStore{}, err
+	}
+	var store signingKeyStore
+	if err := json.Unmarshal(data, &store); err != nil {
+		return signingKeyStore{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if store.Current == nil {
+		return signingKeyStore{}, fmt.Errorf("%s has no current key", path)
+	}
+	return store, nil
+}
+
+// saveSigningKeysLocked 保存签名密钥，调用方需持有写锁
+func saveSigningKeysLocked() error {
+	if err := os.MkdirAll(filepath.Dir(SigningKeysFile), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(signingKeys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(SigningKeysFile, data, 0600)
+}
+
+// generateSigningKey 生成新的 Ed25519 密钥对，ID 为公钥SHA256的前16位
+func generateSigningKey() (*SigningKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(pub)
+	return &SigningKey{
+		ID:         hex.EncodeToString(sum[:])[:16],
+		PublicKey:  base64.StdEncoding.EncodeToString(pub),
+		PrivateKey: base64.StdEncoding.EncodeToString(priv),
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// currentPublicKey 返回当前签名公钥（Base64）
+func currentPublicKey() string {
+	signingKeysMu.RLock()
+	defer signingKeysMu.RUnlock()
+
+	if signingKeys.Current == nil {
+		return ""
+	}
+	return signingKeys.Current.PublicKey
+}
+
+// currentKeyID 返回当前签名密钥ID
+func currentKeyID() string {
+	signingKeysMu.RLock()
+	defer signingKeysMu.RUnlock()
+
+	if signingKeys.Current == nil {
+		return ""
+	}
+	return signingKeys.Current.ID
+}
+
+// signData 使用当前密钥签名，返回Base64签名和密钥ID
+func signData(data []byte) (signature, keyID string, err error) {
+	signingKeysMu.RLock()
+	defer signingKeysMu.RUnlock()
+
+	if signingKeys.Current == nil {
+		return "", "", fmt.Errorf("no signing key")
+	}
+
+	priv, err := base64.StdEncoding.DecodeString(signingKeys.Current.PrivateKey)
+	if err != nil || len(priv) != ed25519.PrivateKeySize {
+		return "", "", fmt.Errorf("invalid signing key")
+	}
+
+	sig := ed25519.Sign(ed25519.PrivateKey(priv), data)
+	return base64.StdEncoding.EncodeToString(sig), signingKeys.Current.ID, nil
+}
+
+// verifySignature 校验签名是否由当前密钥或宽限期内仍在公布的旧密钥对 data 签发
+func verifySignature(data []byte, signature, keyID string) bool {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+
+	now := time.Now()
+	signingKeysMu.RLock()
+	defer signingKeysMu.RUnlock()
+
+	keys := signingKeys.Previous
+	if signingKeys.Current != nil {
+		keys = append([]SigningKey{*signingKeys.Current}, keys...)
+	}
+	for _, key := range keys {
+		if key.ID != keyID || (!key.ExpiresAt.IsZero() && now.After(key.ExpiresAt)) {
+			continue
+		}
+		pub, err := base64.StdEncoding.DecodeString(key.PublicKey)
+		return err == nil && len(pub) == ed25519.PublicKeySize && ed25519.Verify(ed25519.PublicKey(pub), data, sig)
+	}
+	return false
+}
+
+// signHash 为文件哈希签名：签名仍有效时保持不变，否则使用当前密钥重新签名；没有哈希时清除签名
+// 返回签名是否有变化
+func signHash(hash string, signature, keyID *string, force bool) (bool, error) {
+	if hash == "" {
+		changed := *signature != "" || *keyID != ""
+		*signature, *keyID = "", ""
+		return changed, nil
+	}
+	if !force && *signature != "" && verifySignature([]byte(hash), *signature, *keyID) {
+		return false, nil
+	}
+
+	sig, id, err := signData([]byte(hash))
+	if err != nil {
+		return false, err
+	}
+	*signature, *keyID = sig, id
+	return true, nil
+}
+
+// signRelease 使用当前密钥对更新条目及其资源重新签名，签名内容为文件SHA256哈希的十六进制字符串
+func signRelease(update *UpdateInfo) error {
+	return signReleaseFiles(update, true)
+}
+
+// signReleaseFiles 为更新条目的主文件和各资源签名，force 为 false 时只补签缺失或已失效的签名
+func signReleaseFiles(update *UpdateInfo, force bool) error {
+	if _, err := signHash(update.FileHash, &update.Signature, &update.SigningKeyId, force); err != nil {
+		return err
+	}
+	for i := range update.Assets {
+		asset := &update.Assets[i]
+		if _, err := signHash(asset.Hash, &asset.Signature, &asset.SigningKeyId, force); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signReleases 为清单中的条目及资源补签：未签名、哈希修改后签名不再匹配、或签名密钥已不再公布的重新签名，
+// 哈希被清空的条目同时清除签名
+func signReleases(manifest *UpdateManifest) {
+	for i := range manifest.Updates {
+		update := &manifest.Updates[i]
+		if err := signReleaseFiles(update, false); err != nil {
+			log.Printf("Error signing %s: %v", update.Version, err)
+		}
+	}
+}
+
+// pubkeyHandler 公布当前公钥及宽限期内的旧公钥（公开端点）
+func pubkeyHandler(w http.ResponseWriter, r *http.Request) {
+	type publicKey struct {
+		ID        string     `json:"id"`
+		PublicKey string     `json:"publicKey"`
+		CreatedAt time.Time  `json:"createdAt"`
+		ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	}
+
+	now := time.Now()
+	response := struct {
+		Algorithm string      `json:"algorithm"`
+		Current   *publicKey  `json:"current"`
+		Previous  []publicKey `json:"previous"`
+	}{
+		Algorithm: "ed25519",
+		Previous:  []publicKey{},
+	}
+
+	signingKeysMu.RLock()
+	if key := signingKeys.Current; key != nil {
+		response.Current = &publicKey{ID: key.ID, PublicKey: key.PublicKey, CreatedAt: key.CreatedAt}
+	}
+	for _, key := range signingKeys.Previous {
+		if now.After(key.ExpiresAt) {
+			continue
+		}
+		expiresAt := key.ExpiresAt
+		response.Previous = append(response.Previous, publicKey{
+			ID:        key.ID,
+			PublicKey: key.PublicKey,
+			CreatedAt: key.CreatedAt,
+			ExpiresAt: &expiresAt,
+		})
+	}
+	signingKeysMu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// rotateSigningKeyHandler 轮换签名密钥并用新密钥重新签名各频道当前版本
+func rotateSigningKeyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	key, err := generateSigningKey()
+	if err != nil {
+		http.Error(w, "Failed to generate key", http.StatusInternalServerError)
+		log.Printf("Error generating signing key: %v", err)
+		return
+	}
+
+	now := time.Now()
+	grace := config.SigningKeyGracePeriod.Duration
+
+	signingKeysMu.Lock()
+	previous := make([]SigningKey, 0, len(signingKeys.Previous)+1)
+	for _, old := range signingKeys.Previous {
+		if now.Before(old.ExpiresAt) {
+			previous = append(previous, old)
+		}
+	}
+	oldID := ""
+	if old := signingKeys.Current; old != nil {
+		oldID = old.ID
+		retired := *old
+		retired.PrivateKey = ""
+		retired.ExpiresAt = now.Add(grace)
+		previous = append(previous, retired)
+	}
+	oldStore := signingKeys
+	signingKeys = signingKeyStore{Current: key, Previous: previous}
+	if err := saveSigningKeysLocked(); err != nil {
+		signingKeys = oldStore
+		signingKeysMu.Unlock()
+		http.Error(w, "Failed to save key", http.StatusInternalServerError)
+		log.Printf("Error saving signing key: %v", err)
+		return
+	}
+	signingKeysMu.Unlock()
+
+	// 用新密钥重新签名各频道当前发布的版本
+	resigned := make(map[string]string)
+	manifestMu.Lock()
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+		release := currentRelease(manifest)
+		if release == nil {
+			continue
+		}
+		if err := signRelease(release); err != nil {
+			log.Printf("Error re-signing %s/%s: %v", channel, release.Version, err)
+			continue
+		}
+		if err := saveManifest(channel, manifest); err != nil {
+			log.Printf("Error saving manifest %s: %v", channel, err)
+			continue
+		}
+		resigned[channel] = release.Version
+	}
+	manifestMu.Unlock()
+
+	addActivity("signing", fmt.Sprintf("Rotated signing key %s -> %s", oldID, key.ID))
+	log.Printf("Signing key rotated: %s -> %s", oldID, key.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":        key.ID,
+		"publicKey": key.PublicKey,
+		"resigned":  resigned,
+	})
+}
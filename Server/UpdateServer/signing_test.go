@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useTestSigningKey 以内存中新生成的密钥替换签名密钥
+func useTestSigningKey(t *testing.T) *SigningKey {
+	t.Helper()
+	key, err := generateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := signingKeys
+	t.Cleanup(func() { signingKeys = old })
+	signingKeys = signingKeyStore{Current: key}
+	return key
+}
+
+func TestSignReleasesResignsChangedHash(t *testing.T) {
+	useTestSigningKey(t)
+
+	oldHash := strings.Repeat("a", 64)
+	newHash := strings.Repeat("b", 64)
+	update := UpdateInfo{Version: "1.0.0", FileHash: oldHash}
+	if err := signRelease(&update); err != nil {
+		t.Fatal(err)
+	}
+	oldSignature := update.Signature
+
+	update.FileHash = newHash
+	manifest := &UpdateManifest{Updates: []UpdateInfo{update}}
+	signReleases(manifest)
+
+	signed := manifest.Updates[0]
+	if signed.Signature == oldSignature {
+		t.Fatal("signature was not replaced after the file hash changed")
+	}
+	if !verifySignature([]byte(newHash), signed.Signature, signed.SigningKeyId) {
+		t.Error("new signature does not verify against the new hash")
+	}
+
+	// 签名仍有效时保持不变
+	before := signed.Signature
+	signReleases(manifest)
+	if manifest.Updates[0].Signature != before {
+		t.Error("valid signature was replaced")
+	}
+}
+
+func TestSignReleasesSignsAssets(t *testing.T) {
+	useTestSigningKey(t)
+
+	manifest := &UpdateManifest{Updates: []UpdateInfo{{
+		Version: "2.0.0",
+		Assets: []Asset{
+			{Url: "http://localhost/downloads/a.zip", Hash: strings.Repeat("1", 64), Platform: "windows"},
+			{Url: "http://localhost/downloads/b.zip", Hash: strings.Repeat("2", 64), Platform: "linux", Signature: "c3RhbGU=", SigningKeyId: "stale"},
+			{Url: "http://localhost/downloads/c.pdb", Kind: AssetKindSymbols},
+		},
+	}}}
+	signReleases(manifest)
+
+	for _, asset := range manifest.Updates[0].Assets {
+		if asset.Hash == "" {
+			if asset.Signature != "" {
+				t.Errorf("%s: unexpected signature without hash", asset.Url)
+			}
+			continue
+		}
+		if !verifySignature([]byte(asset.Hash), asset.Signature, asset.SigningKeyId) {
+			t.Errorf("%s: signature does not verify", asset.Url)
+		}
+	}
+}
+
+func TestSignReleasesResignsRetiredKey(t *testing.T) {
+	retired := useTestSigningKey(t)
+	hash := strings.Repeat("c", 64)
+	update := UpdateInfo{Version: "1.0.0", FileHash: hash}
+	if err := signRelease(&update); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := generateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := *retired
+	expired.PrivateKey = ""
+	expired.ExpiresAt = time.Now().Add(-time.Hour)
+	signingKeys = signingKeyStore{Current: current, Previous: []SigningKey{expired}}
+
+	manifest := &UpdateManifest{Updates: []UpdateInfo{update}}
+	signReleases(manifest)
+	if got := manifest.Updates[0].SigningKeyId; got != current.ID {
+		t.Errorf("signingKeyId = %q, want current key %q", got, current.ID)
+	}
+}
+
+func TestSignReleasesClearsSignatureWithoutHash(t *testing.T) {
+	useTestSigningKey(t)
+	update := UpdateInfo{Version: "1.0.0", FileHash: strings.Repeat("d", 64)}
+	if err := signRelease(&update); err != nil {
+		t.Fatal(err)
+	}
+	update.FileHash = ""
+
+	manifest := &UpdateManifest{Updates: []UpdateInfo{update}}
+	signReleases(manifest)
+	if manifest.Updates[0].Signature != "" || manifest.Updates[0].SigningKeyId != "" {
+		t.Error("signature kept after the file hash was cleared")
+	}
+}
+
+func TestReadSigningKeys(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if _, err := readSigningKeys("signing.json"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file: err = %v, want os.ErrNotExist", err)
+	}
+
+	key, err := generateSigningKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid, err := json.Marshal(signingKeyStore{Current: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 损坏或缺少当前密钥的文件不能被当作不存在，否则启动时会生成新密钥覆盖它
+	for name, content := range map[string]string{
+		"truncated":  string(valid[:len(valid)/2]),
+		"no current": `{"previous": []}`,
+	} {
+		if err := os.WriteFile("signing.json", []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readSigningKeys("signing.json"); err == nil || errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: err = %v, want a parse error", name, err)
+		}
+	}
+
+	if err := os.WriteFile("signing.json", valid, 0600); err != nil {
+		t.Fatal(err)
+	}
+	store, err := readSigningKeys("signing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Current == nil || store.Current.ID != key.ID {
+		t.Errorf("loaded key = %+v, want %s", store.Current, key.ID)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"math/bits"
+)
+
+// clientSketchPrecision 客户端草图精度：2^10 个寄存器（1 KiB），基数估计的标准误差约 3.3%
+const clientSketchPrecision = 10
+
+// clientSketch 按 HyperLogLog 估计不重复客户端数量的草图，大小固定，不随客户端数量增长；
+// JSON 中保存为 base64，读取时兼容旧版按客户端标识保存的集合
+type clientSketch []byte
+
+// newClientSketch 创建空草图
+func newClientSketch() clientSketch {
+	return make(clientSketch, 1<<clientSketchPrecision)
+}
+
+// add 加入一个客户端标识
+func (s clientSketch) add(key string) {
+	sum := sha256.Sum256([]byte(key))
+	x := binary.BigEndian.Uint64(sum[:8])
+	index := x >> (64 - clientSketchPrecision)
+	// 末尾补一位，保证前导零个数不超过剩余位数
+	rank := uint8(bits.LeadingZeros64(x<<clientSketchPrecision|1<<(clientSketchPrecision-1))) + 1
+	if rank > s[index] {
+		s[index] = rank
+	}
+}
+
+// merge 合并另一个草图，结果估计两者并集的大小
+func (s clientSketch) merge(other clientSketch) {
+	if len(other) != len(s) {
+		return
+	}
+	for i, rank := range other {
+		if rank > s[i] {
+			s[i] = rank
+		}
+	}
+}
+
+// estimate 估计加入过的不重复客户端数量，基数较小时使用线性计数
+func (s clientSketch) estimate() int {
+	m := float64(len(s))
+	if m == 0 {
+		return 0
+	}
+
+	var sum float64
+	zeros := 0
+	for _, rank := range s {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(math.Round(estimate))
+}
+
+// UnmarshalJSON 读取 base64 草图；旧版统计文件中的客户端集合转换为草图
+func (s *clientSketch) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var legacy map[string]bool
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return err
+		}
+		sketch := newClientSketch()
+		for key := range legacy {
+			sketch.add(key)
+		}
+		*s = sketch
+		return nil
+	}
+
+	var raw []byte
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 1<<clientSketchPrecision {
+		raw = newClientSketch()
+	}
+	*s = raw
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestClientSketchEstimate(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 100, 1000, 10000, 100000} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			sketch := newClientSketch()
+			for i := 0; i < n; i++ {
+				// 重复加入不影响估计
+				sketch.add(clientKey(fmt.Sprintf("client-%d", i)))
+				sketch.add(clientKey(fmt.Sprintf("client-%d", i)))
+			}
+
+			got := sketch.estimate()
+			if tolerance := math.Max(1, 0.1*float64(n)); math.Abs(float64(got-n)) > tolerance {
+				t.Errorf("estimate = %d, want %d ± %.0f", got, n, tolerance)
+			}
+		})
+	}
+}
+
+func TestClientSketchMerge(t *testing.T) {
+	a, b := newClientSketch(), newClientSketch()
+	for i := 0; i < 600; i++ {
+		a.add(clientKey(fmt.Sprintf("client-%d", i)))
+	}
+	for i := 400; i < 1000; i++ {
+		b.add(clientKey(fmt.Sprintf("client-%d", i)))
+	}
+
+	a.merge(b)
+	if got := a.estimate(); got < 900 || got > 1100 {
+		t.Errorf("union estimate = %d, want about 1000", got)
+	}
+}
+
+func TestStatisticsFileClientsJSON(t *testing.T) {
+	// 旧版统计文件按客户端标识保存集合
+	legacy := []byte(`{"fileClients": {"a.zip": {"0123456789abcdef": true, "fedcba9876543210": true}}}`)
+	loaded := newStatistics()
+	if err := json.Unmarshal(legacy, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.FileClients["a.zip"].estimate(); got != 2 {
+		t.Errorf("legacy clients estimate = %d, want 2", got)
+	}
+
+	data, err := json.Marshal(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded := newStatistics()
+	if err := json.Unmarshal(data, reloaded); err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.FileClients["a.zip"].estimate(); got != 2 {
+		t.Errorf("round-tripped estimate = %d, want 2", got)
+	}
+	if size := len(reloaded.FileClients["a.zip"]); size != 1<<clientSketchPrecision {
+		t.Errorf("sketch size = %d, want %d", size, 1<<clientSketchPrecision)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"maps"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SniffLength http.DetectContentType 使用的前导字节数
+const SniffLength = 512
+
+// UploadSniffConfig 上传文件类型嗅探配置
+type UploadSniffConfig struct {
+	// Enabled 是否校验上传文件的实际类型
+	Enabled bool `json:"enabled"`
+	// AllowedTypes 按扩展名列出允许的嗅探类型（http.DetectContentType 的结果，不含参数），
+	// "*" 用于未列出的扩展名；扩展名未列出且没有 "*" 时拒绝上传
+	AllowedTypes map[string][]string `json:"allowedTypes"`
+}
+
+// defaultSniffAllowedTypes 默认的类型白名单
+func defaultSniffAllowedTypes() map[string][]string {
+	return map[string][]string{
+		".zip": {"application/zip"},
+		".jar": {"application/zip"},
+		".exe": {"application/octet-stream"},
+		".dmg": {"application/octet-stream", "application/x-gzip"},
+		".md":  {"text/plain"},
+	}
+}
+
+// activeContentTypes 浏览器会渲染或执行的类型，未开启嗅探时也拒绝声明或检测为这些类型的上传，
+// 避免通过下载目录投放页面或脚本
+var activeContentTypes = []string{
+	"text/html", "application/xhtml+xml", "image/svg+xml", "text/xml", "application/xml",
+	"text/javascript", "application/javascript",
+}
+
+// sniffUploadType 嗅探前导字节的类型并与扩展名（支持多段扩展名）的白名单比对，返回检测到的类型和是否允许
+func sniffUploadType(filename string, head []byte) (string, bool) {
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+
+	allowed, ok := config.UploadSniff.AllowedTypes["*"]
+	if ext := matchExtension(filename, slices.Collect(maps.Keys(config.UploadSniff.AllowedTypes))); ext != "" {
+		allowed, ok = config.UploadSniff.AllowedTypes[ext], true
+	}
+	return detected, ok && slices.Contains(allowed, detected)
+}
+
+// checkUploadType 检查上传文件的类型：开启嗅探时检测类型须在扩展名的白名单中，
+// 未开启时仍拒绝声明类型或检测类型属于 activeContentTypes 的文件；返回检测到的类型和是否允许
+func checkUploadType(filename, claimed string, head []byte) (string, bool) {
+	if config.UploadSniff.Enabled {
+		return sniffUploadType(filename, head)
+	}
+	detected, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	claimed, _, _ = mime.ParseMediaType(claimed)
+	return detected, !slices.Contains(activeContentTypes, detected) && !slices.Contains(activeContentTypes, claimed)
+}
+
+// claimedUploadType 返回文件声明的类型：优先按扩展名推断，未知扩展名时使用表单中的 Content-Type
+func claimedUploadType(filename, header string) string {
+	if byExt := mime.TypeByExtension(strings.ToLower(filepath.Ext(filename))); byExt != "" {
+		return byExt
+	}
+	if header != "" {
+		return header
+	}
+	return "unknown"
+}
+
+// rejectUploadType 记录并返回类型不匹配的 422 响应
+func rejectUploadType(w http.ResponseWriter, filename, claimed, detected string) {
+	log.Printf("Upload rejected by type check: %s (claimed %s, detected %s)", filename, claimed, detected)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":    "File content does not match an allowed type",
+		"claimed":  claimed,
+		"detected": detected,
+	})
+}
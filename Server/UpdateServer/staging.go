@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StagedFilesFile 暂存文件存储文件
+const StagedFilesFile = "./staged.json"
+
+// UploadStagingConfig 上传暂存配置
+type UploadStagingConfig struct {
+	// Enabled 新上传的文件先进入暂存状态，发布前不对外提供下载
+	Enabled bool `json:"enabled"`
+	// PublishDelay 暂存文件自动发布的延迟，0 表示只能通过 /api/files/{name}/publish 手动发布
+	PublishDelay Duration `json:"publishDelay"`
+}
+
+// StagedFile 暂存中的文件
+type StagedFile struct {
+	StagedAt time.Time `json:"stagedAt"`
+	// PublishAt 自动发布时间，未配置延迟时为空
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+}
+
+var (
+	stagedFiles    map[string]StagedFile
+	stagedFilesMu  sync.Mutex
+	stagedLoadOnce sync.Once
+)
+
+// loadStagedFilesLocked 首次访问时加载暂存文件，调用方需持有锁
+func loadStagedFilesLocked() {
+	stagedLoadOnce.Do(func() {
+		stagedFiles = make(map[string]StagedFile)
+		data, err := os.ReadFile(StagedFilesFile)
+		if err != nil {
+			return
+		}
+		if err := json.Unmarshal(data, &stagedFiles); err != nil {
+			log.Printf("Error loading staged files: %v", err)
+		}
+	})
+}
+
+// saveStagedFilesLocked 保存暂存文件，调用方需持有锁
+func saveStagedFilesLocked() error {
+	data, err := json.MarshalIndent(stagedFiles, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(StagedFilesFile, data, 0644)
+}
+
+// stagedFile 返回文件的暂存状态，已到自动发布时间的文件视为已发布
+func stagedFile(filename string) (StagedFile, bool) {
+	stagedFilesMu.Lock()
+	defer stagedFilesMu.Unlock()
+	loadStagedFilesLocked()
+
+	staged, ok := stagedFiles[filename]
+	if !ok || (staged.PublishAt != nil && !staged.PublishAt.After(time.Now())) {
+		return StagedFile{}, false
+	}
+	return staged, true
+}
+
+// stageFile 将刚上传的文件置为暂存状态；覆盖已发布的文件时新内容同样需要重新发布
+func stageFile(filename string) (StagedFile, error) {
+	now := time.Now()
+	staged := StagedFile{StagedAt: now}
+	if delay := config.UploadStaging.PublishDelay.Duration; delay > 0 {
+		publishAt := now.Add(delay)
+		staged.PublishAt = &publishAt
+	}
+
+	stagedFilesMu.Lock()
+	defer stagedFilesMu.Unlock()
+	loadStagedFilesLocked()
+
+	stagedFiles[filename] = staged
+	if err := saveStagedFilesLocked(); err != nil {
+		delete(stagedFiles, filename)
+		return StagedFile{}, err
+	}
+
+	if staged.PublishAt != nil {
+		addActivity("stage", fmt.Sprintf("Staged: %s (publishes at %s)", filename, staged.PublishAt.Format(time.RFC3339)))
+	} else {
+		addActivity("stage", fmt.Sprintf("Staged: %s (awaiting publish)", filename))
+	}
+	return staged, nil
+}
+
+// unstageFile 删除文件时移除其暂存记录
+func unstageFile(filename string) {
+	stagedFilesMu.Lock()
+	defer stagedFilesMu.Unlock()
+	loadStagedFilesLocked()
+
+	if _, ok := stagedFiles[filename]; !ok {
+		return
+	}
+	delete(stagedFiles, filename)
+	if err := saveStagedFilesLocked(); err != nil {
+		log.Printf("Error saving staged files: %v", err)
+	}
+}
+
+// restoreStagedFile 上传未能移入下载目录时撤销 stageFile：恢复此前的暂存记录，原来没有暂存时删除记录
+func restoreStagedFile(filename string, previous StagedFile, wasStaged bool) {
+	if !wasStaged {
+		unstageFile(filename)
+		return
+	}
+
+	stagedFilesMu.Lock()
+	defer stagedFilesMu.Unlock()
+	loadStagedFilesLocked()
+
+	stagedFiles[filename] = previous
+	if err := saveStagedFilesLocked(); err != nil {
+		log.Printf("Error saving staged files: %v", err)
+	}
+}
+
+// publishDueStagedFiles 发布所有到达自动发布时间的暂存文件
+func publishDueStagedFiles(now time.Time) {
+	stagedFilesMu.Lock()
+	defer stagedFilesMu.Unlock()
+	loadStagedFilesLocked()
+
+	var due []string
+	for filename, staged := range stagedFiles {
+		if staged.PublishAt != nil && !staged.PublishAt.After(now) {
+			due = append(due, filename)
+		}
+	}
+	if len(due) == 0 {
+		return
+	}
+
+	for _, filename := range due {
+		delete(stagedFiles, filename)
+	}
+	if err := saveStagedFilesLocked(); err != nil {
+		log.Printf("Error saving staged files: %v", err)
+	}
+	for _, filename := range due {
+		addActivity("publish", fmt.Sprintf("Published: %s (after staging delay)", filename))
+		log.Printf("Staged file published after delay: %s", filename)
+	}
+}
+
+// publishFileHandler 发布暂存中的文件，之后即可公开下载
+// POST /api/files/{filename}/publish
+func publishFileHandler(w http.ResponseWriter, r *http.Request, filename string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, err := os.Stat(filepath.Join(DownloadsDir, filename)); err != nil {
+		writeJSONError(w, http.StatusNotFound, "File not found")
+		return
+	}
+
+	stagedFilesMu.Lock()
+	loadStagedFilesLocked()
+	staged, ok := stagedFiles[filename]
+	if !ok {
+		stagedFilesMu.Unlock()
+		writeJSONError(w, http.StatusConflict, "File is not staged")
+		return
+	}
+	delete(stagedFiles, filename)
+	err := saveStagedFilesLocked()
+	if err != nil {
+		stagedFiles[filename] = staged
+	}
+	stagedFilesMu.Unlock()
+	if err != nil {
+		http.Error(w, "Failed to save staged files", http.StatusInternalServerError)
+		log.Printf("Error saving staged files: %v", err)
+		return
+	}
+
+	publishedBy := "unknown"
+	if user, ok := currentUser(r); ok {
+		publishedBy = user.Username
+	}
+	addActivity("publish", fmt.Sprintf("Published: %s (by %s, staged %s)", filename, publishedBy, time.Since(staged.StagedAt).Round(time.Second)))
+	log.Printf("Staged file published: %s by %s", filename, publishedBy)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":        filename,
+		"stagedAt":    staged.StagedAt,
+		"publishedAt": time.Now(),
+	})
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// StatsFile JSON 统计数据文件
+	StatsFile = "./stats.json"
+	// StatsDatabase SQLite 统计数据库
+	StatsDatabase = "./stats.db"
+	// RecentActivityLimit 统计数据中保留的最近活动条数，完整记录见活动归档
+	RecentActivityLimit = 50
+)
+
+// StatsStore 统计数据存储，实现需保证并发调用安全
+type StatsStore interface {
+	// RecordDownload 记录一次完整下载，并计入文件所属的频道、版本和模组
+	RecordDownload(filename string, attribution DownloadAttribution)
+	// RecordTransfer 记录文件送出的字节数和下载客户端（哈希后的标识）
+	RecordTransfer(filename, clientKey string, bytes int64)
+	// AddBytesServed 累加所有响应送出的字节数
+	AddBytesServed(bytes int64)
+	// AddActivity 将活动加入最近活动窗口，只保留最近 RecentActivityLimit 条；完整记录由活动归档负责
+	AddActivity(activity ActivityLog)
+	// SetStorage 全量校准存储统计
+	SetStorage(usage int64, files int, at time.Time)
+	// AdjustStorage 按增量更新存储统计
+	AdjustStorage(sizeDelta int64, fileDelta int)
+	// StorageUsage 返回下载目录当前占用的字节数
+	StorageUsage() int64
+	// Snapshot 返回统计数据的副本
+	Snapshot() Statistics
+	// Flush 补写尚未持久化的数据
+	Flush()
+	// Health 持续写入失败时返回最近一次错误
+	Health() error
+	// Probe 实际尝试一次写入，用于就绪检查
+	Probe() error
+}
+
+var statsStore StatsStore
+
+// DownloadAttribution 下载文件在记录时所属的发布版本与模组
+type DownloadAttribution struct {
+	Releases []ReleaseRef
+	// ModID 文件为模组最新版本的下载文件时的模组ID
+	ModID string
+}
+
+// ReleaseRef 引用某个文件的频道版本
+type ReleaseRef struct {
+	Channel string
+	Version string
+}
+
+// channels 返回引用文件的频道（去重）
+func (a DownloadAttribution) channels() []string {
+	var channels []string
+	for _, release := range a.Releases {
+		if !slices.Contains(channels, release.Channel) {
+			channels = append(channels, release.Channel)
+		}
+	}
+	return channels
+}
+
+// errSQLiteUnavailable 当前构建不包含 SQLite 驱动
+var errSQLiteUnavailable = errors.New("SQLite statistics require a cgo build")
+
+// openStatsStore 按配置打开统计存储：未配置时优先使用 SQLite（需 cgo 构建），否则使用 JSON 文件
+func openStatsStore() {
+	switch config.StatsBackend {
+	case "json":
+		statsStore = newJSONStatsStore(StatsFile)
+	case "sqlite":
+		store, err := newSQLiteStatsStore(StatsDatabase, StatsFile)
+		if err != nil {
+			log.Fatalf("Failed to open statistics database: %v", err)
+		}
+		statsStore = store
+	case "":
+		store, err := newSQLiteStatsStore(StatsDatabase, StatsFile)
+		if errors.Is(err, errSQLiteUnavailable) {
+			statsStore = newJSONStatsStore(StatsFile)
+			return
+		}
+		if err != nil {
+			log.Fatalf("Failed to open statistics database: %v", err)
+		}
+		statsStore = store
+	default:
+		log.Fatalf("Unknown statsBackend %q (expected \"json\" or \"sqlite\")", config.StatsBackend)
+	}
+}
+
+// flushStatisticsLoop 定期补写未成功保存的统计数据，并保存有变化的文件哈希缓存
+func flushStatisticsLoop() {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		statsStore.Flush()
+		saveHashCache()
+	}
+}
+
+// cloneStatistics 深拷贝统计数据
+func cloneStatistics(s *Statistics) Statistics {
+	clone := *s
+	clone.FileDownloads = maps.Clone(s.FileDownloads)
+	clone.ChannelDownloads = maps.Clone(s.ChannelDownloads)
+	clone.VersionDownloads = make(map[string]map[string]int64, len(s.VersionDownloads))
+	for channel, versions := range s.VersionDownloads {
+		clone.VersionDownloads[channel] = maps.Clone(versions)
+	}
+	clone.ModDownloads = maps.Clone(s.ModDownloads)
+	clone.FileBytes = maps.Clone(s.FileBytes)
+	clone.FileClients = make(map[string]clientSketch, len(s.FileClients))
+	for name, sketch := range s.FileClients {
+		clone.FileClients[name] = slices.Clone(sketch)
+	}
+	clone.RecentActivities = append([]ActivityLog(nil), s.RecentActivities...)
+	return clone
+}
+
+// readStatisticsFile 读取 JSON 统计文件，文件损坏时备份后返回错误
+func readStatisticsFile(path string) (*Statistics, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 先解析到临时对象，避免损坏文件留下半填充的状态
+	loaded := newStatistics()
+	if err := json.Unmarshal(data, loaded); err != nil {
+		backupPath := fmt.Sprintf("%s.corrupt.%s", path, time.Now().Format("20060102T150405"))
+		if renameErr := os.Rename(path, backupPath); renameErr != nil {
+			log.Printf("Error backing up corrupt statistics: %v", renameErr)
+		}
+		return nil, fmt.Errorf("statistics file is corrupt (%v), backed up to %s", err, backupPath)
+	}
+
+	if loaded.FileDownloads == nil {
+		loaded.FileDownloads = make(map[string]int64)
+	}
+	if loaded.ChannelDownloads == nil {
+		loaded.ChannelDownloads = make(map[string]int64)
+	}
+	if loaded.VersionDownloads == nil {
+		loaded.VersionDownloads = make(map[string]map[string]int64)
+	}
+	if loaded.ModDownloads == nil {
+		loaded.ModDownloads = make(map[string]int64)
+	}
+	if loaded.FileBytes == nil {
+		loaded.FileBytes = make(map[string]int64)
+	}
+	if loaded.FileClients == nil {
+		loaded.FileClients = make(map[string]clientSketch)
+	}
+	if loaded.RecentActivities == nil {
+		loaded.RecentActivities = make([]ActivityLog, 0)
+	}
+	return loaded, nil
+}
+
+// jsonStatsStore 将统计数据整体保存在 JSON 文件中
+type jsonStatsStore struct {
+	path string
+
+	// mu 保护 stats、recent 与 dirty；下载、上传、删除和统计接口并发访问，只读操作使用读锁
+	mu    sync.RWMutex
+	stats *Statistics
+	// recent 最近活动，旧的在前，只在末尾追加；stats.RecentActivities 仅在复制和保存时按新的在前生成
+	recent []ActivityLog
+	// dirty 内存中的统计数据尚未成功写入磁盘
+	dirty bool
+
+	// saveMu 串行化文件写入，保证较新的快照不会被较旧的覆盖
+	saveMu sync.Mutex
+	// writeFailures 连续保存失败次数，成功后清零
+	writeFailures int
+	lastError     string
+}
+
+// newJSONStatsStore 加载 JSON 统计文件，文件不存在或损坏时从空统计开始
+func newJSONStatsStore(path string) *jsonStatsStore {
+	store := &jsonStatsStore{path: path, stats: newStatistics()}
+
+	loaded, err := readStatisticsFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		log.Printf("No existing statistics found, starting fresh")
+	case err != nil:
+		log.Printf("%v, reset to empty statistics", err)
+	default:
+		store.stats = loaded
+	}
+	store.recent = slices.Clone(store.stats.RecentActivities)
+	slices.Reverse(store.recent)
+	store.stats.RecentActivities = nil
+	return store
+}
+
+// recentActivitiesLocked 按新的在前返回最近活动的副本，调用方需持有 mu
+func (s *jsonStatsStore) recentActivitiesLocked() []ActivityLog {
+	activities := make([]ActivityLog, 0, min(len(s.recent), RecentActivityLimit))
+	for i := len(s.recent) - 1; i >= 0 && len(activities) < RecentActivityLimit; i-- {
+		activities = append(activities, s.recent[i])
+	}
+	return activities
+}
+
+func (s *jsonStatsStore) RecordDownload(filename string, attribution DownloadAttribution) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.FileDownloads[filename]++
+	s.stats.TotalDownloads++
+	for _, channel := range attribution.channels() {
+		s.stats.ChannelDownloads[channel]++
+	}
+	for _, release := range attribution.Releases {
+		if s.stats.VersionDownloads[release.Channel] == nil {
+			s.stats.VersionDownloads[release.Channel] = make(map[string]int64)
+		}
+		s.stats.VersionDownloads[release.Channel][release.Version]++
+	}
+	if attribution.ModID != "" {
+		s.stats.ModDownloads[attribution.ModID]++
+	}
+	s.dirty = true
+}
+
+func (s *jsonStatsStore) RecordTransfer(filename, clientKey string, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.FileBytes[filename] += bytes
+	if s.stats.FileClients[filename] == nil {
+		s.stats.FileClients[filename] = newClientSketch()
+	}
+	s.stats.FileClients[filename].add(clientKey)
+	s.dirty = true
+}
+
+func (s *jsonStatsStore) AddBytesServed(bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.BytesServed += bytes
+	s.dirty = true
+}
+
+// AddActivity 追加到最近活动窗口，超过两倍上限时才整体截断，均摊为常数时间；
+// 活动已同步写入归档，统计文件由后台刷新保存
+func (s *jsonStatsStore) AddActivity(activity ActivityLog) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recent = append(s.recent, activity)
+	if len(s.recent) > 2*RecentActivityLimit {
+		s.recent = slices.Clone(s.recent[len(s.recent)-RecentActivityLimit:])
+	}
+	s.dirty = true
+}
+
+func (s *jsonStatsStore) SetStorage(usage int64, files int, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.StorageUsage = usage
+	s.stats.TotalFiles = files
+	s.stats.LastUpdate = at
+	s.stats.StorageReconciledAt = at
+	s.dirty = true
+}
+
+func (s *jsonStatsStore) AdjustStorage(sizeDelta int64, fileDelta int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stats.StorageUsage += sizeDelta
+	s.stats.TotalFiles += fileDelta
+	s.stats.LastUpdate = time.Now()
+	s.dirty = true
+}
+
+func (s *jsonStatsStore) StorageUsage() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stats.StorageUsage
+}
+
+func (s *jsonStatsStore) Snapshot() Statistics {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snapshot := cloneStatistics(s.stats)
+	snapshot.RecentActivities = s.recentActivitiesLocked()
+	return snapshot
+}
+
+func (s *jsonStatsStore) Flush() {
+	s.mu.RLock()
+	dirty := s.dirty
+	s.mu.RUnlock()
+	if dirty {
+		s.save()
+	}
+}
+
+func (s *jsonStatsStore) Health() error {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	if s.writeFailures > 0 {
+		return errors.New(s.lastError)
+	}
+	return nil
+}
+
+// Probe 在统计文件所在目录创建并删除临时文件，确认仍可写入
+func (s *jsonStatsStore) Probe() error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+// save 保存统计数据
+// 写入失败时按指数退避重试，仍失败则保持脏标记，由下一次保存或后台刷新补写
+func (s *jsonStatsStore) save() {
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+
+	s.mu.Lock()
+	persisted := *s.stats
+	persisted.RecentActivities = s.recentActivitiesLocked()
+	data, err := json.MarshalIndent(&persisted, "", "  ")
+	s.dirty = false
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("Error encoding statistics: %v", err)
+		return
+	}
+
+	backoff := statsRetryBackoff
+	for attempt := 1; ; attempt++ {
+		err = atomicWriteFile(s.path, data, 0644)
+		if err == nil {
+			break
+		}
+		if attempt >= statsWriteAttempts {
+			s.writeFailures++
+			s.lastError = err.Error()
+			s.mu.Lock()
+			s.dirty = true
+			s.mu.Unlock()
+			log.Printf("Error saving statistics after %d attempts: %v", attempt, err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	s.writeFailures = 0
+	s.lastError = ""
+}
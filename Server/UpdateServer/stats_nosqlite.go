@@ -0,0 +1,11 @@
+//go:build !cgo
+
+package main
+
+// sqliteStatsStore 未启用 cgo 时不可用
+type sqliteStatsStore struct{ StatsStore }
+
+// newSQLiteStatsStore 未启用 cgo 时总是返回 errSQLiteUnavailable
+func newSQLiteStatsStore(path, legacyPath string) (*sqliteStatsStore, error) {
+	return nil, errSQLiteUnavailable
+}
@@ -0,0 +1,592 @@
+//go:build cgo
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// statsSchema 统计数据库结构；计数器均以 SQL 原子递增，并发下载不会互相覆盖
+const statsSchema = `
+CREATE TABLE IF NOT EXISTS counters (
+	name  TEXT PRIMARY KEY,
+	value INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS file_stats (
+	filename  TEXT PRIMARY KEY,
+	downloads INTEGER NOT NULL DEFAULT 0,
+	bytes     INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS file_client_sketches (
+	filename TEXT PRIMARY KEY,
+	sketch   BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS channel_downloads (
+	channel   TEXT PRIMARY KEY,
+	downloads INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS version_downloads (
+	channel   TEXT NOT NULL,
+	version   TEXT NOT NULL,
+	downloads INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (channel, version)
+);
+CREATE TABLE IF NOT EXISTS mod_downloads (
+	mod_id    TEXT PRIMARY KEY,
+	downloads INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS activities (
+	id        INTEGER PRIMARY KEY AUTOINCREMENT,
+	timestamp INTEGER NOT NULL,
+	action    TEXT NOT NULL,
+	details   TEXT NOT NULL,
+	target    TEXT NOT NULL DEFAULT '',
+	count     INTEGER NOT NULL DEFAULT 0,
+	until     INTEGER
+);
+`
+
+// 计数器名称，时间以 Unix 纳秒保存
+var statsCounters = []string{
+	"total_downloads", "bytes_served", "storage_usage", "total_files", "last_update", "storage_reconciled_at",
+}
+
+// sqliteStatsStore 使用 SQLite 保存统计数据
+type sqliteStatsStore struct {
+	db *sql.DB
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// newSQLiteStatsStore 打开统计数据库；数据库为新建且存在 JSON 统计文件时导入该文件，
+// 之后不再读取或更新 JSON 文件
+func newSQLiteStatsStore(path, legacyPath string) (*sqliteStatsStore, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate", path))
+	if err != nil {
+		return nil, err
+	}
+	// 单连接串行化写入，避免同一进程内的锁竞争
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(statsSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := migrateFileClients(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	var existing int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM counters`).Scan(&existing); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	store := &sqliteStatsStore{db: db}
+	if existing == 0 {
+		if err := store.initialize(legacyPath); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	log.Printf("Statistics stored in %s", path)
+	return store, nil
+}
+
+// migrateFileClients 将旧版逐客户端保存的 file_clients 表转换为固定大小的草图后删除该表
+func migrateFileClients(db *sql.DB) error {
+	var exists int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'file_clients'`).Scan(&exists); err != nil {
+		return err
+	}
+	if exists == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	sketches := make(map[string]clientSketch)
+	rows, err := tx.Query(`SELECT filename, client_key FROM file_clients`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var filename, key string
+		if err := rows.Scan(&filename, &key); err != nil {
+			rows.Close()
+			return err
+		}
+		if sketches[filename] == nil {
+			sketches[filename] = newClientSketch()
+		}
+		sketches[filename].add(key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for filename, sketch := range sketches {
+		if err := mergeClientSketch(tx, filename, sketch); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(`DROP TABLE file_clients`); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	log.Printf("Converted download clients of %d files to sketches", len(sketches))
+	return nil
+}
+
+// mergeClientSketch 将草图合并到文件已保存的草图中
+func mergeClientSketch(tx *sql.Tx, filename string, sketch clientSketch) error {
+	var stored []byte
+	err := tx.QueryRow(`SELECT sketch FROM file_client_sketches WHERE filename = ?`, filename).Scan(&stored)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	merged := newClientSketch()
+	merged.merge(stored)
+	merged.merge(sketch)
+	_, err = tx.Exec(`INSERT OR REPLACE INTO file_client_sketches (filename, sketch) VALUES (?, ?)`, filename, []byte(merged))
+	return err
+}
+
+// initialize 创建计数器，并在存在 JSON 统计文件时一次性导入
+func (s *sqliteStatsStore) initialize(legacyPath string) error {
+	legacy, err := readStatisticsFile(legacyPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Skipping statistics import: %v", err)
+	}
+
+	err = s.tx(func(tx *sql.Tx) error {
+		for _, name := range statsCounters {
+			if _, err := tx.Exec(`INSERT OR IGNORE INTO counters (name, value) VALUES (?, 0)`, name); err != nil {
+				return err
+			}
+		}
+		if legacy == nil {
+			return nil
+		}
+		return importStatistics(tx, legacy)
+	})
+	if err != nil || legacy == nil {
+		return err
+	}
+
+	log.Printf("Imported %s into statistics database (%d downloads, %d files); the JSON file is no longer updated",
+		legacyPath, legacy.TotalDownloads, len(legacy.FileDownloads))
+	return nil
+}
+
+// importStatistics 将 JSON 统计数据写入数据库
+func importStatistics(tx *sql.Tx, legacy *Statistics) error {
+	counters := map[string]int64{
+		"total_downloads":       legacy.TotalDownloads,
+		"bytes_served":          legacy.BytesServed,
+		"storage_usage":         legacy.StorageUsage,
+		"total_files":           int64(legacy.TotalFiles),
+		"last_update":           unixNano(legacy.LastUpdate),
+		"storage_reconciled_at": unixNano(legacy.StorageReconciledAt),
+	}
+	for name, value := range counters {
+		if _, err := tx.Exec(`UPDATE counters SET value = ? WHERE name = ?`, value, name); err != nil {
+			return err
+		}
+	}
+
+	for filename, downloads := range legacy.FileDownloads {
+		if _, err := tx.Exec(`INSERT INTO file_stats (filename, downloads) VALUES (?, ?)
+			ON CONFLICT (filename) DO UPDATE SET downloads = excluded.downloads`, filename, downloads); err != nil {
+			return err
+		}
+	}
+	for channel, downloads := range legacy.ChannelDownloads {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO channel_downloads (channel, downloads) VALUES (?, ?)`, channel, downloads); err != nil {
+			return err
+		}
+	}
+	for channel, versions := range legacy.VersionDownloads {
+		for version, downloads := range versions {
+			if _, err := tx.Exec(`INSERT OR REPLACE INTO version_downloads (channel, version, downloads) VALUES (?, ?, ?)`,
+				channel, version, downloads); err != nil {
+				return err
+			}
+		}
+	}
+	for modID, downloads := range legacy.ModDownloads {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO mod_downloads (mod_id, downloads) VALUES (?, ?)`, modID, downloads); err != nil {
+			return err
+		}
+	}
+	for filename, bytes := range legacy.FileBytes {
+		if _, err := tx.Exec(`INSERT INTO file_stats (filename, bytes) VALUES (?, ?)
+			ON CONFLICT (filename) DO UPDATE SET bytes = excluded.bytes`, filename, bytes); err != nil {
+			return err
+		}
+	}
+	for filename, sketch := range legacy.FileClients {
+		if err := mergeClientSketch(tx, filename, sketch); err != nil {
+			return err
+		}
+	}
+
+	// RecentActivities 为新的在前，按时间先后插入
+	for i := len(legacy.RecentActivities) - 1; i >= 0; i-- {
+		if err := insertActivity(tx, legacy.RecentActivities[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unixNano 返回时间的 Unix 纳秒，零值时间保存为 0
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano 将 unixNano 的结果还原为时间
+func fromUnixNano(n int64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, n)
+}
+
+// insertActivity 插入一条活动记录
+func insertActivity(tx *sql.Tx, activity ActivityLog) error {
+	var until sql.NullInt64
+	if activity.Until != nil {
+		until = sql.NullInt64{Int64: activity.Until.UnixNano(), Valid: true}
+	}
+	_, err := tx.Exec(`INSERT INTO activities (timestamp, action, details, target, count, until) VALUES (?, ?, ?, ?, ?, ?)`,
+		activity.Timestamp.UnixNano(), activity.Action, activity.Details, activity.Target, activity.Count, until)
+	return err
+}
+
+// tx 在事务中执行写入，并记录最近一次失败供健康检查使用
+func (s *sqliteStatsStore) tx(fn func(tx *sql.Tx) error) error {
+	err := func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}()
+
+	s.mu.Lock()
+	s.lastErr = err
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("Error writing statistics: %v", err)
+	}
+	return err
+}
+
+// addCounter 原子累加计数器
+func addCounter(tx *sql.Tx, name string, delta int64) error {
+	_, err := tx.Exec(`UPDATE counters SET value = value + ? WHERE name = ?`, delta, name)
+	return err
+}
+
+// setCounter 设置计数器
+func setCounter(tx *sql.Tx, name string, value int64) error {
+	_, err := tx.Exec(`UPDATE counters SET value = ? WHERE name = ?`, value, name)
+	return err
+}
+
+func (s *sqliteStatsStore) RecordDownload(filename string, attribution DownloadAttribution) {
+	s.tx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`INSERT INTO file_stats (filename, downloads) VALUES (?, 1)
+			ON CONFLICT (filename) DO UPDATE SET downloads = downloads + 1`, filename); err != nil {
+			return err
+		}
+		for _, channel := range attribution.channels() {
+			if _, err := tx.Exec(`INSERT INTO channel_downloads (channel, downloads) VALUES (?, 1)
+				ON CONFLICT (channel) DO UPDATE SET downloads = downloads + 1`, channel); err != nil {
+				return err
+			}
+		}
+		for _, release := range attribution.Releases {
+			if _, err := tx.Exec(`INSERT INTO version_downloads (channel, version, downloads) VALUES (?, ?, 1)
+				ON CONFLICT (channel, version) DO UPDATE SET downloads = downloads + 1`, release.Channel, release.Version); err != nil {
+				return err
+			}
+		}
+		if attribution.ModID != "" {
+			if _, err := tx.Exec(`INSERT INTO mod_downloads (mod_id, downloads) VALUES (?, 1)
+				ON CONFLICT (mod_id) DO UPDATE SET downloads = downloads + 1`, attribution.ModID); err != nil {
+				return err
+			}
+		}
+		return addCounter(tx, "total_downloads", 1)
+	})
+}
+
+func (s *sqliteStatsStore) RecordTransfer(filename, clientKey string, bytes int64) {
+	s.tx(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`INSERT INTO file_stats (filename, bytes) VALUES (?, ?)
+			ON CONFLICT (filename) DO UPDATE SET bytes = bytes + excluded.bytes`, filename, bytes); err != nil {
+			return err
+		}
+		sketch := newClientSketch()
+		sketch.add(clientKey)
+		return mergeClientSketch(tx, filename, sketch)
+	})
+}
+
+func (s *sqliteStatsStore) AddBytesServed(bytes int64) {
+	if bytes == 0 {
+		return
+	}
+	s.tx(func(tx *sql.Tx) error {
+		return addCounter(tx, "bytes_served", bytes)
+	})
+}
+
+func (s *sqliteStatsStore) AddActivity(activity ActivityLog) {
+	s.tx(func(tx *sql.Tx) error {
+		if err := insertActivity(tx, activity); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM activities WHERE id <= (SELECT MAX(id) FROM activities) - ?`, RecentActivityLimit)
+		return err
+	})
+}
+
+func (s *sqliteStatsStore) SetStorage(usage int64, files int, at time.Time) {
+	s.tx(func(tx *sql.Tx) error {
+		for name, value := range map[string]int64{
+			"storage_usage":         usage,
+			"total_files":           int64(files),
+			"last_update":           at.UnixNano(),
+			"storage_reconciled_at": at.UnixNano(),
+		} {
+			if err := setCounter(tx, name, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteStatsStore) AdjustStorage(sizeDelta int64, fileDelta int) {
+	s.tx(func(tx *sql.Tx) error {
+		if err := addCounter(tx, "storage_usage", sizeDelta); err != nil {
+			return err
+		}
+		if err := addCounter(tx, "total_files", int64(fileDelta)); err != nil {
+			return err
+		}
+		return setCounter(tx, "last_update", time.Now().UnixNano())
+	})
+}
+
+func (s *sqliteStatsStore) StorageUsage() int64 {
+	var usage int64
+	if err := s.db.QueryRow(`SELECT value FROM counters WHERE name = 'storage_usage'`).Scan(&usage); err != nil {
+		log.Printf("Error reading storage usage: %v", err)
+	}
+	return usage
+}
+
+func (s *sqliteStatsStore) Snapshot() Statistics {
+	snapshot := *newStatistics()
+	if err := s.readSnapshot(&snapshot); err != nil {
+		log.Printf("Error reading statistics: %v", err)
+	}
+	return snapshot
+}
+
+// readSnapshot 在同一事务中读取全部统计数据，避免总数与按文件统计之间混入并发写入
+func (s *sqliteStatsStore) readSnapshot(snapshot *Statistics) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT name, value FROM counters`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		var value int64
+		if err := rows.Scan(&name, &value); err != nil {
+			rows.Close()
+			return err
+		}
+		switch name {
+		case "total_downloads":
+			snapshot.TotalDownloads = value
+		case "bytes_served":
+			snapshot.BytesServed = value
+		case "storage_usage":
+			snapshot.StorageUsage = value
+		case "total_files":
+			snapshot.TotalFiles = int(value)
+		case "last_update":
+			snapshot.LastUpdate = fromUnixNano(value)
+		case "storage_reconciled_at":
+			snapshot.StorageReconciledAt = fromUnixNano(value)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(`SELECT filename, downloads, bytes FROM file_stats`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var filename string
+		var downloads, bytes int64
+		if err := rows.Scan(&filename, &downloads, &bytes); err != nil {
+			rows.Close()
+			return err
+		}
+		if downloads > 0 {
+			snapshot.FileDownloads[filename] = downloads
+		}
+		if bytes > 0 {
+			snapshot.FileBytes[filename] = bytes
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(`SELECT filename, sketch FROM file_client_sketches`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var filename string
+		var stored []byte
+		if err := rows.Scan(&filename, &stored); err != nil {
+			rows.Close()
+			return err
+		}
+		sketch := newClientSketch()
+		sketch.merge(stored)
+		snapshot.FileClients[filename] = sketch
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if err := readDownloadCounts(tx, `SELECT channel, downloads FROM channel_downloads`, snapshot.ChannelDownloads); err != nil {
+		return err
+	}
+	if err := readDownloadCounts(tx, `SELECT mod_id, downloads FROM mod_downloads`, snapshot.ModDownloads); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(`SELECT channel, version, downloads FROM version_downloads`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var channel, version string
+		var downloads int64
+		if err := rows.Scan(&channel, &version, &downloads); err != nil {
+			rows.Close()
+			return err
+		}
+		if snapshot.VersionDownloads[channel] == nil {
+			snapshot.VersionDownloads[channel] = make(map[string]int64)
+		}
+		snapshot.VersionDownloads[channel][version] = downloads
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(`SELECT timestamp, action, details, target, count, until FROM activities ORDER BY id DESC LIMIT ?`, RecentActivityLimit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var activity ActivityLog
+		var timestamp int64
+		var until sql.NullInt64
+		if err := rows.Scan(&timestamp, &activity.Action, &activity.Details, &activity.Target, &activity.Count, &until); err != nil {
+			return err
+		}
+		activity.Timestamp = time.Unix(0, timestamp)
+		if until.Valid {
+			t := time.Unix(0, until.Int64)
+			activity.Until = &t
+		}
+		snapshot.RecentActivities = append(snapshot.RecentActivities, activity)
+	}
+	return rows.Err()
+}
+
+// readDownloadCounts 读取 键-下载次数 两列的查询结果
+func readDownloadCounts(tx *sql.Tx, query string, counts map[string]int64) error {
+	rows, err := tx.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		var downloads int64
+		if err := rows.Scan(&key, &downloads); err != nil {
+			return err
+		}
+		counts[key] = downloads
+	}
+	return rows.Err()
+}
+
+// Flush 每次写入都已提交，无需补写
+func (s *sqliteStatsStore) Flush() {}
+
+func (s *sqliteStatsStore) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Probe 开启并回滚一个事务；连接使用 _txlock=immediate，开启时即获取写锁
+func (s *sqliteStatsStore) Probe() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	return tx.Rollback()
+}
@@ -0,0 +1,47 @@
+//go:build cgo
+
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStatsMigratesFileClients(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.db")
+
+	// 旧版数据库逐客户端保存 file_clients
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE file_clients (filename TEXT NOT NULL, client_key TEXT NOT NULL, PRIMARY KEY (filename, client_key));
+		INSERT INTO file_clients VALUES ('a.zip', 'k1'), ('a.zip', 'k2'), ('b.zip', 'k1');`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	store, err := newSQLiteStatsStore(path, filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.db.Close()
+
+	store.RecordTransfer("a.zip", "k3", 10)
+	store.RecordTransfer("a.zip", "k1", 10)
+
+	snapshot := store.Snapshot()
+	if got := snapshot.FileClients["a.zip"].estimate(); got != 3 {
+		t.Errorf("a.zip clients = %d, want 3", got)
+	}
+	if got := snapshot.FileClients["b.zip"].estimate(); got != 1 {
+		t.Errorf("b.zip clients = %d, want 1", got)
+	}
+
+	var tables int
+	store.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'file_clients'`).Scan(&tables)
+	if tables != 0 {
+		t.Error("file_clients table was not dropped")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJSONStatsStoreRetriesFailedSave(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// 统计文件的父目录位置被普通文件占用，写入必然失败
+	if err := os.WriteFile("data", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	store := newJSONStatsStore("data/stats.json")
+	store.RecordDownload("a.zip", DownloadAttribution{})
+
+	start := time.Now()
+	store.Flush()
+	if elapsed, min := time.Since(start), statsRetryBackoff*(1<<(statsWriteAttempts-1)-1); elapsed < min {
+		t.Errorf("failed save returned after %v, want retries with backoff of at least %v", elapsed, min)
+	}
+	if store.Health() == nil {
+		t.Error("Health() = nil after a failed save")
+	}
+	if store.Probe() == nil {
+		t.Error("Probe() = nil for an unwritable path")
+	}
+	if !store.dirty {
+		t.Error("dirty flag cleared although the save failed")
+	}
+
+	// 目录恢复可写后，后台刷新补写此前未保存的数据
+	if err := os.Remove("data"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir("data", 0755); err != nil {
+		t.Fatal(err)
+	}
+	store.RecordDownload("a.zip", DownloadAttribution{})
+	store.Flush()
+	if err := store.Health(); err != nil {
+		t.Errorf("Health() = %v after a successful save", err)
+	}
+	if store.dirty {
+		t.Error("dirty flag still set after a successful save")
+	}
+
+	loaded, err := readStatisticsFile("data/stats.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.FileDownloads["a.zip"] != 2 {
+		t.Errorf("saved downloads = %d, want 2", loaded.FileDownloads["a.zip"])
+	}
+
+	// 没有变化时不再写入
+	if err := os.Remove("data/stats.json"); err != nil {
+		t.Fatal(err)
+	}
+	store.Flush()
+	if _, err := os.Stat("data/stats.json"); !os.IsNotExist(err) {
+		t.Errorf("clean store was saved again: %v", err)
+	}
+}
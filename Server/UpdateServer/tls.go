@@ -0,0 +1,178 @@
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TLSCertEnv、TLSKeyEnv HTTPS 证书与私钥文件的环境变量，优先于配置文件
+	TLSCertEnv = "LIZARD_TLS_CERT"
+	TLSKeyEnv  = "LIZARD_TLS_KEY"
+	// HTTPRedirectEnv HTTP→HTTPS 跳转监听地址的环境变量，如 ":80"
+	HTTPRedirectEnv = "LIZARD_HTTP_REDIRECT"
+
+	// certCheckInterval 检查证书文件是否被续期替换的最短间隔
+	certCheckInterval = time.Minute
+	// acmeChallengePrefix ACME HTTP-01 验证文件的路径前缀
+	acmeChallengePrefix = "/.well-known/acme-challenge/"
+)
+
+// 启动参数优先于环境变量和配置文件
+var (
+	tlsCertFlag      = flag.String("tls-cert", "", "HTTPS certificate file (PEM), overrides "+TLSCertEnv+" and tls.certFile")
+	tlsKeyFlag       = flag.String("tls-key", "", "HTTPS private key file (PEM), overrides "+TLSKeyEnv+" and tls.keyFile")
+	httpRedirectFlag = flag.String("http-redirect", "", "address of a plain HTTP listener that redirects to HTTPS, e.g. :80")
+)
+
+// applyTLSOverrides 以启动参数和环境变量覆盖配置文件中的证书设置
+func applyTLSOverrides() {
+	override := func(target *string, flagValue, env string) {
+		if flagValue != "" {
+			*target = flagValue
+		} else if value := os.Getenv(env); value != "" {
+			*target = value
+		}
+	}
+	override(&config.TLS.CertFile, *tlsCertFlag, TLSCertEnv)
+	override(&config.TLS.KeyFile, *tlsKeyFlag, TLSKeyEnv)
+	override(&config.TLS.RedirectAddr, *httpRedirectFlag, HTTPRedirectEnv)
+
+	switch {
+	case tlsEnabled():
+	case config.TLS.CertFile != "" || config.TLS.KeyFile != "":
+		log.Printf("WARNING: TLS needs both a certificate and a key file; serving plain HTTP")
+	default:
+		log.Printf("WARNING: TLS is not configured; serving plain HTTP, admin credentials are sent unencrypted")
+	}
+	if config.TLS.RedirectAddr != "" && !tlsEnabled() {
+		log.Printf("WARNING: tls.redirectAddr is ignored without TLS")
+		config.TLS.RedirectAddr = ""
+	}
+}
+
+// serverScheme 服务器对外的协议
+func serverScheme() string {
+	if tlsEnabled() {
+		return "https"
+	}
+	return "http"
+}
+
+// certReloader 按需重新加载证书，续期工具替换证书文件后无需重启服务器
+type certReloader struct {
+	certFile, keyFile string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// newCertReloader 加载证书与私钥，启动时即校验文件是否有效
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// load 读取证书文件，调用方需持有锁或处于初始化阶段
+func (c *certReloader) load() error {
+	info, err := os.Stat(c.certFile)
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+	c.cert = &cert
+	c.modTime = info.ModTime()
+	c.checkedAt = time.Now()
+	return nil
+}
+
+// GetCertificate 用于 tls.Config；证书文件修改时间变化时重新加载，加载失败继续使用旧证书
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.checkedAt) < certCheckInterval {
+		return c.cert, nil
+	}
+	c.checkedAt = time.Now()
+	info, err := os.Stat(c.certFile)
+	if err != nil || info.ModTime().Equal(c.modTime) {
+		return c.cert, nil
+	}
+
+	previous := c.cert
+	if err := c.load(); err != nil {
+		// 证书与私钥可能尚未同时写完，下次检查时重试
+		c.cert = previous
+		log.Printf("Error reloading TLS certificate: %v", err)
+		return previous, nil
+	}
+	log.Printf("Reloaded TLS certificate from %s", c.certFile)
+	return c.cert, nil
+}
+
+// httpsRedirectHandler 将 HTTP 请求跳转到同一路径的 HTTPS 地址；
+// 配置 acmeWebroot 时从中提供 ACME HTTP-01 验证文件，便于使用 certbot --webroot 续期
+func httpsRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	if config.TLS.ACMEWebroot != "" && strings.HasPrefix(r.URL.Path, acmeChallengePrefix) {
+		token := strings.TrimPrefix(r.URL.Path, acmeChallengePrefix)
+		// 验证令牌为 base64url 字符串，不含目录
+		path, err := safeJoin(filepath.Join(config.TLS.ACMEWebroot, ".well-known", "acme-challenge"), token)
+		if err != nil || strings.Contains(token, "/") {
+			http.Error(w, "Invalid token", http.StatusBadRequest)
+			return
+		}
+		http.ServeFile(w, r, path)
+		return
+	}
+
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if host == "" {
+		http.Error(w, "Host required", http.StatusBadRequest)
+		return
+	}
+	if Port != "443" {
+		host = net.JoinHostPort(host, Port)
+	}
+
+	// 非 GET 请求使用 308，客户端重发时保留方法和请求体
+	status := http.StatusMovedPermanently
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		status = http.StatusPermanentRedirect
+	}
+	http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), status)
+}
+
+// serveHTTPSRedirect 在 tls.redirectAddr 上监听 HTTP 并跳转到 HTTPS
+func serveHTTPSRedirect(addr string) {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           http.HandlerFunc(httpsRedirectHandler),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("Redirecting http://%s to HTTPS", addr)
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("HTTP redirect listener failed: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TransferInfo 正在进行的下载传输
+type TransferInfo struct {
+	ID         uint64    `json:"id"`
+	Filename   string    `json:"filename"`
+	RemoteAddr string    `json:"remoteAddr"`
+	StartedAt  time.Time `json:"startedAt"`
+	BytesSent  int64     `json:"bytesSent"`
+	TotalBytes int64     `json:"totalBytes"`
+	Percent    float64   `json:"percent"`
+}
+
+// activeTransfer 单个下载的进度，bytesSent 由传输协程原子更新
+type activeTransfer struct {
+	id         uint64
+	filename   string
+	remoteAddr string
+	startedAt  time.Time
+	totalBytes int64
+	bytesSent  atomic.Int64
+}
+
+var (
+	activeTransfers   = make(map[uint64]*activeTransfer)
+	activeTransfersMu sync.Mutex
+	nextTransferID    atomic.Uint64
+)
+
+// startTransfer 登记一个下载传输，结束时必须调用 endTransfer
+func startTransfer(r *http.Request, filename string, size int64) *activeTransfer {
+	total := size
+	if start, end, ok := parseSingleRange(r.Header.Get("Range"), size); ok {
+		total = end - start + 1
+	}
+
+	t := &activeTransfer{
+		id:         nextTransferID.Add(1),
+		filename:   filename,
+		remoteAddr: r.RemoteAddr,
+		startedAt:  time.Now(),
+		totalBytes: total,
+	}
+
+	activeTransfersMu.Lock()
+	activeTransfers[t.id] = t
+	activeTransfersMu.Unlock()
+	return t
+}
+
+// endTransfer 移除已完成或中断的传输
+func endTransfer(t *activeTransfer) {
+	activeTransfersMu.Lock()
+	delete(activeTransfers, t.id)
+	activeTransfersMu.Unlock()
+}
+
+// progressWriter 在写出响应时更新传输进度
+type progressWriter struct {
+	http.ResponseWriter
+	transfer *activeTransfer
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.transfer.bytesSent.Add(int64(n))
+	return n, err
+}
+
+// Flush 透传流式刷新
+func (w *progressWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 供 http.ResponseController 访问底层ResponseWriter
+func (w *progressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// transfersHandler 列出正在进行的下载及进度
+// GET /api/transfers
+func transfersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	activeTransfersMu.Lock()
+	transfers := make([]TransferInfo, 0, len(activeTransfers))
+	for _, t := range activeTransfers {
+		info := TransferInfo{
+			ID:         t.id,
+			Filename:   t.filename,
+			RemoteAddr: t.remoteAddr,
+			StartedAt:  t.startedAt,
+			BytesSent:  t.bytesSent.Load(),
+			TotalBytes: t.totalBytes,
+		}
+		if info.TotalBytes > 0 {
+			info.Percent = float64(info.BytesSent) * 100 / float64(info.TotalBytes)
+		}
+		transfers = append(transfers, info)
+	}
+	activeTransfersMu.Unlock()
+
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].StartedAt.Before(transfers[j].StartedAt)
+	})
+
+	writeList(w, r, transfers)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// filesRouteHandler 分发 /api/files/ 下的请求
+func filesRouteHandler(w http.ResponseWriter, r *http.Request) {
+	parts, ok := splitSubpath(r.URL.Path, "/api/files/")
+	if !ok {
+		http.Error(w, "Invalid filename", http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] == "unlinked":
+		unlinkedFilesHandler(w, r)
+	case len(parts) == 2 && parts[1] == "link":
+		linkFileHandler(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "publish":
+		publishFileHandler(w, r, parts[0])
+	default:
+		deleteFileHandler(w, r)
+	}
+}
+
+// unlinkedFilesHandler 列出下载目录中未被任何清单引用的文件
+// GET /api/files/unlinked
+func unlinkedFilesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	files, err := os.ReadDir(DownloadsDir)
+	if err != nil {
+		http.Error(w, "Failed to read directory", http.StatusInternalServerError)
+		return
+	}
+
+	refs := referencedFiles()
+	unlinked := []FileInfo{}
+	for _, file := range files {
+		if file.IsDir() || refs[file.Name()] != nil {
+			continue
+		}
+
+		info, err := file.Info()
+		if err != nil {
+			continue
+		}
+
+		hash, _ := cachedFileHash(filepath.Join(DownloadsDir, file.Name()))
+		unlinked = append(unlinked, FileInfo{
+			Name:     file.Name(),
+			Size:     info.Size(),
+			Hash:     hash,
+			Modified: info.ModTime(),
+		})
+	}
+
+	writeList(w, r, unlinked)
+}
+
+// linkFileHandler 将已上传的文件作为新版本加入频道清单；
+// 指定 platform/kind 时可作为附加文件加入已有版本
+// POST /api/files/{filename}/link {"channel", "version", "changelog", "isMandatory", "setLatest", "platform", "kind"}
+func linkFileHandler(w http.ResponseWriter, r *http.Request, filename string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Channel     string `json:"channel"`
+		Version     string `json:"version"`
+		Changelog   string `json:"changelog"`
+		IsMandatory bool   `json:"isMandatory"`
+		SetLatest   *bool  `json:"setLatest"`
+		Platform    string `json:"platform"`
+		Kind        string `json:"kind"`
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !isValidChannel(req.Channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+	if req.Version == "" {
+		http.Error(w, "Version required", http.StatusBadRequest)
+		return
+	}
+
+	filePath := filepath.Join(DownloadsDir, filename)
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	hash, err := cachedFileHash(filePath)
+	if err != nil {
+		http.Error(w, "Failed to calculate hash", http.StatusInternalServerError)
+		return
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest, err := loadManifest(req.Channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		return
+	}
+
+	baseURL := manifestBaseURL(manifest)
+
+	asset := Asset{
+		Url:      fmt.Sprintf("%s/downloads/%s", baseURL, filename),
+		Size:     info.Size(),
+		Hash:     hash,
+		Platform: req.Platform,
+		Kind:     req.Kind,
+	}
+	multiAsset := req.Platform != "" || req.Kind != ""
+
+	var update UpdateInfo
+	created := false
+	if existing := findUpdate(manifest, req.Version); existing != nil {
+		if !multiAsset {
+			http.Error(w, "Version already exists", http.StatusConflict)
+			return
+		}
+		if slices.Contains(releaseFiles(*existing), filename) {
+			http.Error(w, "File already linked to this version", http.StatusConflict)
+			return
+		}
+		existing.Assets = append(releaseAssets(*existing), asset)
+		syncPrimaryAsset(existing)
+		update = *existing
+	} else {
+		if !enforceCadence(w, r, req.Channel) {
+			return
+		}
+		created = true
+		update = UpdateInfo{
+			Version:         req.Version,
+			ReleaseDate:     time.Now(),
+			DownloadUrl:     asset.Url,
+			FileSize:        asset.Size,
+			FileHash:        asset.Hash,
+			IsMandatory:     req.IsMandatory,
+			Changelog:       req.Changelog,
+			Dependencies:    []string{},
+			ReleaseNotesUrl: fmt.Sprintf("%s/changelog/%s.md", baseURL, req.Version),
+		}
+		if multiAsset {
+			update.Assets = []Asset{asset}
+		}
+		if patch, ok := availablePatch(manifest, update); ok {
+			applyPatchInfo(&update, patch, baseURL, req.Channel)
+		}
+		if err := signRelease(&update); err != nil {
+			log.Printf("Error signing release %s: %v", req.Version, err)
+		}
+
+		manifest.Updates = append(manifest.Updates, update)
+		if req.SetLatest == nil || *req.SetLatest {
+			manifest.LatestVersion = req.Version
+		}
+	}
+	manifest.LastUpdated = time.Now()
+
+	pruned := applyRetention(req.Channel, manifest)
+	err = saveManifest(req.Channel, manifest)
+	if errors.Is(err, errInvalidManifest) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save manifest", http.StatusInternalServerError)
+		log.Printf("Error saving manifest: %v", err)
+		return
+	}
+	finishPrune(req.Channel, pruned)
+	if created {
+		recordPublish(req.Channel, manifest.LastUpdated)
+	}
+
+	addActivity("manifest", fmt.Sprintf("Linked %s to %s/%s", filename, req.Channel, req.Version))
+	log.Printf("File linked: %s -> %s/%s", filename, req.Channel, req.Version)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(update)
+}
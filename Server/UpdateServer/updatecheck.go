@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+)
+
+// UpdateDecision 针对某个客户端的更新判定结果
+type UpdateDecision struct {
+	Channel        string `json:"channel"`
+	CurrentVersion string `json:"currentVersion"`
+	Platform       string `json:"platform,omitempty"`
+	ClientID       string `json:"clientId,omitempty"`
+	LatestVersion  string `json:"latestVersion"`
+	// TargetVersion 客户端应安装的版本；当前版本低于最新版本的 minimumCompatibleVersion 时为可直接升级的中间版本
+	TargetVersion     string `json:"targetVersion,omitempty"`
+	HasUpdate         bool   `json:"hasUpdate"`
+	Mandatory         bool   `json:"mandatory"`
+	ForceRedownload   bool   `json:"forceRedownload"`
+	Critical          bool   `json:"critical"`
+	RolloutPercentage int    `json:"rolloutPercentage"`
+	RolloutBucket     int    `json:"rolloutBucket"`
+	InRollout         bool   `json:"inRollout"`
+	DownloadUrl       string `json:"downloadUrl,omitempty"`
+	FileHash          string `json:"fileHash,omitempty"`
+	FileSize          int64  `json:"fileSize,omitempty"`
+	Reason            string `json:"reason"`
+}
+
+// evaluateUpdate 计算客户端应收到的更新
+// 低于 MinimumVersion 或目标版本标记 IsMandatory 时为强制更新，强制更新不受灰度比例限制
+func evaluateUpdate(manifest *UpdateManifest, current, platform, clientID string) UpdateDecision {
+	decision := UpdateDecision{
+		Channel:        manifest.Channel,
+		CurrentVersion: current,
+		Platform:       platform,
+		ClientID:       clientID,
+	}
+
+	release := currentRelease(manifest)
+	if release == nil {
+		decision.Reason = "no releases available"
+		return decision
+	}
+
+	decision.LatestVersion = release.Version
+	decision.RolloutPercentage = rolloutPercentage(release)
+	decision.RolloutBucket = rolloutBucket(clientID, release.Version)
+	decision.InRollout = decision.RolloutBucket < decision.RolloutPercentage
+
+	if compareVersions(current, release.Version) == 0 && (release.ForceRedownload || manifest.ForceRedownload) {
+		decision.HasUpdate = true
+		decision.ForceRedownload = true
+		decision.Reason = "forced re-download"
+		applyReleaseAsset(&decision, release, platform)
+		return decision
+	}
+	if compareVersions(current, release.Version) >= 0 {
+		decision.Reason = "up to date"
+		return decision
+	}
+
+	belowMinimum := manifest.MinimumVersion != "" && compareVersions(current, manifest.MinimumVersion) < 0
+	decision.Mandatory = release.IsMandatory || belowMinimum
+	decision.Critical = release.IsCritical
+
+	if !decision.InRollout && !decision.Mandatory {
+		decision.Reason = "not in rollout"
+		return decision
+	}
+
+	decision.HasUpdate = true
+
+	// 当前版本过旧、无法直接升级到最新版本时，先升级到可直接升级的最高中间版本
+	if step := steppingStoneRelease(manifest, current, release); step != nil {
+		decision.Critical = decision.Critical || step.IsCritical
+		applyReleaseAsset(&decision, step, platform)
+		decision.Reason = "update via " + step.Version
+		return decision
+	}
+	applyReleaseAsset(&decision, release, platform)
+
+	switch {
+	case belowMinimum:
+		decision.Reason = "below minimum version"
+	case release.IsMandatory:
+		decision.Reason = "mandatory update"
+	default:
+		decision.Reason = "update available"
+	}
+	return decision
+}
+
+// steppingStoneRelease 当前版本低于目标版本的 minimumCompatibleVersion 时，返回介于两者之间、
+// 可从当前版本直接升级的最高未撤回版本；无需中间版本或找不到时返回 nil（此时直接提供目标版本的完整安装包）
+func steppingStoneRelease(manifest *UpdateManifest, current string, target *UpdateInfo) *UpdateInfo {
+	if target.MinimumCompatibleVersion == "" || compareVersions(current, target.MinimumCompatibleVersion) >= 0 {
+		return nil
+	}
+
+	var step *UpdateInfo
+	for i := range manifest.Updates {
+		update := &manifest.Updates[i]
+		if update.Yanked || compareVersions(update.Version, current) <= 0 || compareVersions(update.Version, target.Version) >= 0 {
+			continue
+		}
+		if update.MinimumCompatibleVersion != "" && compareVersions(current, update.MinimumCompatibleVersion) < 0 {
+			continue
+		}
+		if step == nil || compareVersions(update.Version, step.Version) > 0 {
+			step = update
+		}
+	}
+	return step
+}
+
+// applyReleaseAsset 填充目标版本与下载信息，多文件发布按平台选择下载文件
+func applyReleaseAsset(decision *UpdateDecision, release *UpdateInfo, platform string) {
+	decision.TargetVersion = release.Version
+	if asset, ok := assetForPlatform(*release, platform); ok {
+		decision.DownloadUrl = asset.Url
+		decision.FileHash = asset.Hash
+		decision.FileSize = asset.Size
+	}
+}
+
+// rolloutPercentage 返回版本的灰度比例，未设置时视为全量发布
+func rolloutPercentage(update *UpdateInfo) int {
+	if update.RolloutPercentage <= 0 || update.RolloutPercentage > 100 {
+		return 100
+	}
+	return update.RolloutPercentage
+}
+
+// rolloutBucket 将客户端稳定地映射到 0-99 的灰度分桶，同一版本内结果固定
+func rolloutBucket(clientID, version string) int {
+	h := fnv.New32a()
+	h.Write([]byte(clientID + ":" + version))
+	return int(h.Sum32() % 100)
+}
+
+// simulateClientHandler 模拟指定客户端的更新检查结果（只读）
+// GET /api/simulate-client?channel=stable&version=1.1.0&platform=windows&clientId=abc
+func simulateClientHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	channel := query.Get("channel")
+	version := query.Get("version")
+
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+	if version == "" {
+		http.Error(w, "Version required", http.StatusBadRequest)
+		return
+	}
+
+	manifest, err := loadPublicManifest(channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		return
+	}
+
+	decision := evaluateUpdate(manifest, version, query.Get("platform"), query.Get("clientId"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(decision)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+)
+
+// receivedUpload 已流式写入临时文件的上传
+type receivedUpload struct {
+	// Filename 规范化后的文件名，OriginalName 为表单中的原始文件名
+	Filename     string
+	OriginalName string
+	// TempPath 临时文件路径，处理完成后重命名到下载目录，出错时由调用方删除
+	TempPath string
+	Size     int64
+	Hash     string
+	// Fields 表单中的普通字段，同名字段只保留第一个
+	Fields map[string]string
+}
+
+// receiveUpload 逐个读取 multipart 表单部分：file 字段直接经哈希写入临时文件，内存占用与文件大小无关；
+// 失败时已写入响应并删除临时文件
+func receiveUpload(w http.ResponseWriter, r *http.Request) (*receivedUpload, bool) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return nil, false
+	}
+
+	upload := &receivedUpload{Fields: make(map[string]string)}
+	var fieldBytes int64
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			upload.discard()
+			writeUploadReadError(w, err, "Failed to parse form", http.StatusBadRequest)
+			return nil, false
+		}
+
+		switch {
+		case part.FormName() == "file" && part.FileName() != "" && upload.TempPath == "":
+			ok := upload.receiveFile(w, part)
+			part.Close()
+			if !ok {
+				upload.discard()
+				return nil, false
+			}
+		case part.FileName() != "":
+			// 多余的文件字段不保存
+			io.Copy(io.Discard, part)
+			part.Close()
+		default:
+			value, err := io.ReadAll(io.LimitReader(part, MultipartFormMemory-fieldBytes+1))
+			part.Close()
+			if err != nil {
+				upload.discard()
+				writeUploadReadError(w, err, "Failed to parse form", http.StatusBadRequest)
+				return nil, false
+			}
+			fieldBytes += int64(len(value))
+			if fieldBytes > MultipartFormMemory {
+				upload.discard()
+				http.Error(w, "Form fields too large", http.StatusRequestEntityTooLarge)
+				return nil, false
+			}
+			if _, exists := upload.Fields[part.FormName()]; !exists {
+				upload.Fields[part.FormName()] = string(value)
+			}
+		}
+	}
+
+	if upload.TempPath == "" {
+		http.Error(w, "Failed to get file", http.StatusBadRequest)
+		return nil, false
+	}
+	return upload, true
+}
+
+// receiveFile 校验文件名与类型后将文件部分写入临时文件，超出 maxUploadSize 时返回 413
+func (u *receivedUpload) receiveFile(w http.ResponseWriter, part *multipart.Part) bool {
+	// 规范化文件名，避免不同平台上无法创建或无法下载的文件名
+	u.OriginalName = part.FileName()
+	filename, err := uploadFilename(u.OriginalName)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid filename %q: %v", u.OriginalName, err))
+		return false
+	}
+	u.Filename = filename
+
+	// 只预读前导字节嗅探类型，其余数据仍以流的方式写入
+	reader := bufio.NewReaderSize(part, SniffLength)
+	head, _ := reader.Peek(SniffLength)
+	if detected, ok := checkUploadType(filename, part.Header.Get("Content-Type"), head); !ok {
+		rejectUploadType(w, filename, claimedUploadType(filename, part.Header.Get("Content-Type")), detected)
+		return false
+	}
+
+	// 临时文件放在上传目录，写入过程中不会出现在文件列表或被下载
+	if err := os.MkdirAll(UploadSessionsDir, 0755); err != nil {
+		http.Error(w, "Failed to create file", http.StatusInternalServerError)
+		return false
+	}
+	tmp, err := os.CreateTemp(UploadSessionsDir, "upload-*.tmp")
+	if err != nil {
+		http.Error(w, "Failed to create file", http.StatusInternalServerError)
+		log.Printf("Error creating upload temp file: %v", err)
+		return false
+	}
+	u.TempPath = tmp.Name()
+
+	var src io.Reader = reader
+	if config.MaxUploadSize > 0 {
+		src = io.LimitReader(reader, config.MaxUploadSize+1)
+	}
+	hash := sha256.New()
+	size, copyErr := io.Copy(io.MultiWriter(tmp, hash), src)
+	closeErr := tmp.Close()
+	if copyErr != nil {
+		writeUploadReadError(w, copyErr, "Failed to save file", http.StatusInternalServerError)
+		log.Printf("Upload interrupted: %s after %d bytes: %v", filename, size, copyErr)
+		return false
+	}
+	if config.MaxUploadSize > 0 && size > config.MaxUploadSize {
+		http.Error(w, "Upload exceeds maximum size", http.StatusRequestEntityTooLarge)
+		return false
+	}
+	if closeErr != nil {
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return false
+	}
+	// CreateTemp 创建的文件仅所有者可读，移入下载目录前恢复为普通文件权限
+	if err := os.Chmod(u.TempPath, 0644); err != nil {
+		http.Error(w, "Failed to save file", http.StatusInternalServerError)
+		return false
+	}
+
+	u.Size = size
+	u.Hash = hex.EncodeToString(hash.Sum(nil))
+	return true
+}
+
+// discard 删除尚未移入下载目录的临时文件
+func (u *receivedUpload) discard() {
+	if u.TempPath == "" {
+		return
+	}
+	if err := os.Remove(u.TempPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Error removing upload temp file %s: %v", u.TempPath, err)
+	}
+}
+
+// writeUploadReadError 请求体超出 MaxBytesReader 上限时返回 413，否则返回给定的错误
+func writeUploadReadError(w http.ResponseWriter, err error, message string, status int) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "Upload exceeds maximum size", http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, message, status)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// setupUploadTest 在临时目录中准备默认配置、空的下载目录与统计
+func setupUploadTest(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	oldConfig, oldDownloads, oldStore := config, DownloadsDir, statsStore
+	stagedFilesMu.Lock()
+	loadStagedFilesLocked()
+	oldStaged := stagedFiles
+	stagedFilesMu.Unlock()
+	t.Cleanup(func() {
+		config, DownloadsDir, statsStore = oldConfig, oldDownloads, oldStore
+		stagedFilesMu.Lock()
+		stagedFiles = oldStaged
+		stagedFilesMu.Unlock()
+	})
+	config = defaultConfig()
+	DownloadsDir = t.TempDir()
+	statsStore = newJSONStatsStore("stats.json")
+}
+
+// tempUpload 在上传目录写入已接收的临时文件
+func tempUpload(t *testing.T, name, content string, overwrite bool) *receivedUpload {
+	t.Helper()
+	tmp := filepath.Join(t.TempDir(), "upload.tmp")
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return &receivedUpload{
+		Filename:     name,
+		OriginalName: name,
+		TempPath:     tmp,
+		Size:         int64(len(content)),
+		Fields:       map[string]string{"overwrite": strconv.FormatBool(overwrite)},
+	}
+}
+
+func TestPublishUploadRefusesImmutableOverwrite(t *testing.T) {
+	setupUploadTest(t)
+	for _, name := range []string{"LizardClient_v1.0.0.zip", "LizardClient.zip"} {
+		if err := os.WriteFile(filepath.Join(DownloadsDir, name), []byte("old"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		want int
+		data string
+	}{
+		{"LizardClient_v1.0.0.zip", http.StatusConflict, "old"},
+		{"LizardClient.zip", http.StatusOK, "new"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		publishUpload(w, tempUpload(t, tt.name, "new", true))
+		if w.Code != tt.want {
+			t.Errorf("overwrite %s = %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+		if data, _ := os.ReadFile(filepath.Join(DownloadsDir, tt.name)); string(data) != tt.data {
+			t.Errorf("%s contains %q, want %q", tt.name, data, tt.data)
+		}
+	}
+
+	// 关闭 immutableCacheControl 后带版本号的文件名可以覆盖
+	config.ImmutableCacheControl = ""
+	w := httptest.NewRecorder()
+	publishUpload(w, tempUpload(t, "LizardClient_v1.0.0.zip", "new", true))
+	if w.Code != http.StatusOK {
+		t.Errorf("overwrite with immutableCacheControl disabled = %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestPublishUploadRestoresStagingOnFailedMove(t *testing.T) {
+	setupUploadTest(t)
+	config.UploadStaging.Enabled = true
+
+	previous := StagedFile{StagedAt: time.Now().Add(-time.Hour).UTC()}
+	stagedFilesMu.Lock()
+	stagedFiles = map[string]StagedFile{"existing.zip": previous}
+	stagedFilesMu.Unlock()
+
+	// 临时文件已不存在，移入下载目录失败
+	for _, name := range []string{"new.zip", "existing.zip"} {
+		w := httptest.NewRecorder()
+		publishUpload(w, &receivedUpload{
+			Filename:     name,
+			OriginalName: name,
+			TempPath:     filepath.Join(t.TempDir(), "missing.tmp"),
+			Fields:       map[string]string{"overwrite": "true"},
+		})
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("publishUpload(%s) = %d, want 500: %s", name, w.Code, w.Body)
+		}
+	}
+
+	if _, ok := stagedFile("new.zip"); ok {
+		t.Error("new.zip left staged after failed upload")
+	}
+	if got, ok := stagedFile("existing.zip"); !ok || !got.StagedAt.Equal(previous.StagedAt) {
+		t.Errorf("existing.zip staging = %+v, %v; want previous entry %+v", got, ok, previous)
+	}
+	if _, err := os.Stat(filepath.Join(DownloadsDir, "new.zip")); err == nil {
+		t.Error("new.zip created in downloads")
+	}
+}
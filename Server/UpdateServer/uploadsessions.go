@@ -0,0 +1,549 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UploadSessionsDir 上传临时目录：分块上传会话各有一个 .json 元数据和一个 .part 数据文件，
+// 普通上传写入 upload-*.tmp；可通过 -uploads-dir 或 LIZARD_UPLOADS_DIR 指定
+var UploadSessionsDir = "./uploads"
+
+const (
+	// DefaultUploadSessionTTL 未配置 uploadSessionTtl 时会话空闲多久后被清理
+	DefaultUploadSessionTTL = 24 * time.Hour
+	// UploadOffsetHeader 分块上传的字节偏移头
+	UploadOffsetHeader = "Upload-Offset"
+)
+
+// UploadSession 分块上传会话
+type UploadSession struct {
+	ID        string    `json:"id"`
+	Filename  string    `json:"filename"`
+	Size      int64     `json:"size,omitempty"`      // 预期总大小，0 表示未知
+	Hash      string    `json:"hash,omitempty"`      // 预期SHA256
+	Overwrite bool      `json:"overwrite,omitempty"` // 完成时允许覆盖同名文件
+	Offset    int64     `json:"offset"`              // 已接收的字节数
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// uploadSession 会话及其数据文件锁
+type uploadSession struct {
+	UploadSession
+	mu      sync.Mutex
+	removed bool
+}
+
+var (
+	uploadSessions       = make(map[string]*uploadSession)
+	uploadSessionsMu     sync.Mutex
+	uploadSessionsLoaded sync.Once
+)
+
+// uploadSessionPath 返回会话元数据或数据文件路径
+func uploadSessionPath(id, ext string) string {
+	return filepath.Join(UploadSessionsDir, id+ext)
+}
+
+// uploadSessionTTL 返回会话空闲超时
+func uploadSessionTTL() time.Duration {
+	if config.UploadSessionTTL.Duration > 0 {
+		return config.UploadSessionTTL.Duration
+	}
+	return DefaultUploadSessionTTL
+}
+
+// loadUploadSessionsLocked 首次访问时从临时目录恢复会话，调用方需持有 uploadSessionsMu
+func loadUploadSessionsLocked() {
+	uploadSessionsLoaded.Do(func() {
+		entries, err := os.ReadDir(UploadSessionsDir)
+		if err != nil {
+			return
+		}
+		for _, entry := range entries {
+			id, ok := strings.CutSuffix(entry.Name(), ".json")
+			if !ok {
+				continue
+			}
+			data, err := os.ReadFile(uploadSessionPath(id, ".json"))
+			if err != nil {
+				continue
+			}
+			session := &uploadSession{}
+			if err := json.Unmarshal(data, &session.UploadSession); err != nil || session.ID != id {
+				log.Printf("Skipping invalid upload session %s: %v", id, err)
+				continue
+			}
+			// 以数据文件的实际大小为准，写入中途崩溃时元数据可能落后
+			if info, err := os.Stat(uploadSessionPath(id, ".part")); err == nil {
+				session.Offset = info.Size()
+			}
+			uploadSessions[id] = session
+		}
+	})
+}
+
+// saveUploadSession 保存会话元数据，调用方需持有会话锁
+func saveUploadSession(session *uploadSession) error {
+	data, err := json.MarshalIndent(session.UploadSession, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(uploadSessionPath(session.ID, ".json"), data, 0644)
+}
+
+// getUploadSession 按ID查找会话
+func getUploadSession(id string) *uploadSession {
+	uploadSessionsMu.Lock()
+	defer uploadSessionsMu.Unlock()
+	loadUploadSessionsLocked()
+	return uploadSessions[id]
+}
+
+// removeUploadSession 删除会话及其数据，等待正在写入的分块结束
+func removeUploadSession(session *uploadSession) {
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	removeUploadSessionLocked(session)
+}
+
+// removeUploadSessionLocked 删除会话及其数据，调用方需持有会话锁
+func removeUploadSessionLocked(session *uploadSession) {
+	if session.removed {
+		return
+	}
+	session.removed = true
+
+	uploadSessionsMu.Lock()
+	delete(uploadSessions, session.ID)
+	uploadSessionsMu.Unlock()
+
+	for _, ext := range []string{".part", ".json"} {
+		if err := os.Remove(uploadSessionPath(session.ID, ext)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing upload session file %s%s: %v", session.ID, ext, err)
+		}
+	}
+}
+
+// listUploadSessions 返回所有进行中的会话，按创建时间排序
+func listUploadSessions() []UploadSession {
+	uploadSessionsMu.Lock()
+	loadUploadSessionsLocked()
+	sessions := make([]*uploadSession, 0, len(uploadSessions))
+	for _, session := range uploadSessions {
+		sessions = append(sessions, session)
+	}
+	uploadSessionsMu.Unlock()
+
+	list := make([]UploadSession, 0, len(sessions))
+	for _, session := range sessions {
+		session.mu.Lock()
+		list = append(list, session.UploadSession)
+		session.mu.Unlock()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// reapUploadSessions 删除空闲超过 TTL 的会话，返回清理的数量
+func reapUploadSessions(now time.Time) int {
+	ttl := uploadSessionTTL()
+
+	uploadSessionsMu.Lock()
+	loadUploadSessionsLocked()
+	var candidates []*uploadSession
+	for _, session := range uploadSessions {
+		candidates = append(candidates, session)
+	}
+	uploadSessionsMu.Unlock()
+
+	reaped := 0
+	for _, session := range candidates {
+		session.mu.Lock()
+		expired := now.Sub(session.UpdatedAt) > ttl
+		info := session.UploadSession
+		session.mu.Unlock()
+		if !expired {
+			continue
+		}
+
+		removeUploadSession(session)
+		reaped++
+		addActivity("upload-reaped", fmt.Sprintf("Reaped upload session %s: %s (%d bytes, idle since %s)",
+			info.ID, info.Filename, info.Offset, info.UpdatedAt.Format(time.RFC3339)))
+		log.Printf("Upload session reaped: %s (%s, %d bytes)", info.ID, info.Filename, info.Offset)
+	}
+	return reaped
+}
+
+// sweepUploadTempFiles 删除上传目录中修改时间早于 maxAge 的普通上传临时文件（upload-*.tmp），返回删除的数量；
+// 进程在上传中途退出时这些文件不会被清理，启动时以 0 调用删除全部遗留文件
+func sweepUploadTempFiles(now time.Time, maxAge time.Duration) int {
+	paths, err := filepath.Glob(filepath.Join(UploadSessionsDir, "upload-*.tmp"))
+	if err != nil {
+		return 0
+	}
+	swept := 0
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || now.Sub(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("Error removing stale upload temp file %s: %v", path, err)
+			continue
+		}
+		swept++
+		log.Printf("Stale upload temp file removed: %s (%d bytes)", path, info.Size())
+	}
+	return swept
+}
+
+// uploadSessionReaperLoop 定期清理过期的上传会话
+func uploadSessionReaperLoop() {
+	interval := min(uploadSessionTTL()/4, time.Hour)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reapUploadSessions(time.Now())
+	for range ticker.C {
+		reapUploadSessions(time.Now())
+		sweepUploadTempFiles(time.Now(), uploadSessionTTL())
+	}
+}
+
+// uploadRouteHandler 分块上传会话
+// POST   /api/upload/init        创建会话 {"filename": "...", "size": 123, "hash": "..."}
+// GET    /api/upload/sessions    进行中的会话
+// GET    /api/upload/{id}        会话状态（HEAD 只返回 Upload-Offset 头）
+// PATCH  /api/upload/{id}        追加数据，Upload-Offset 头必须等于已接收的字节数
+// DELETE /api/upload/{id}        取消会话并删除已上传的数据
+// POST   /api/upload/{id}/complete  校验哈希并移入下载目录
+func uploadRouteHandler(w http.ResponseWriter, r *http.Request) {
+	parts, ok := splitSubpath(r.URL.Path, "/api/upload/")
+	if ok && len(parts) == 2 && parts[1] == "complete" {
+		completeUploadSessionHandler(w, r, parts[0])
+		return
+	}
+	if !ok || len(parts) != 1 {
+		apiNotFoundHandler(w, r)
+		return
+	}
+
+	switch parts[0] {
+	case "init":
+		initUploadSessionHandler(w, r)
+	case "sessions":
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeList(w, r, listUploadSessions())
+	default:
+		uploadSessionHandler(w, r, parts[0])
+	}
+}
+
+// initUploadSessionHandler 创建分块上传会话
+func initUploadSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Filename  string `json:"filename"`
+		Size      int64  `json:"size"`
+		Hash      string `json:"hash"`
+		Overwrite bool   `json:"overwrite"`
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	filename, err := uploadFilename(req.Filename)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid filename %q: %v", req.Filename, err))
+		return
+	}
+	if req.Size < 0 {
+		http.Error(w, "Invalid size", http.StatusBadRequest)
+		return
+	}
+	// 提前拒绝，免得传完才发现不能覆盖；完成时会再次检查
+	if _, err := os.Stat(filepath.Join(DownloadsDir, filename)); err == nil && (!req.Overwrite || immutableDownloadName(filename)) {
+		writeFileExistsConflict(w, filename)
+		return
+	}
+	if config.MaxUploadSize > 0 && req.Size > config.MaxUploadSize {
+		http.Error(w, "Upload exceeds maximum size", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if !withinStorageQuota(req.Size) {
+		http.Error(w, "Storage quota exceeded", http.StatusInsufficientStorage)
+		return
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		http.Error(w, "Failed to create upload session", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	session := &uploadSession{UploadSession: UploadSession{
+		ID:        hex.EncodeToString(id),
+		Filename:  filename,
+		Size:      req.Size,
+		Hash:      strings.ToLower(req.Hash),
+		Overwrite: req.Overwrite,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}}
+
+	if err := os.MkdirAll(UploadSessionsDir, 0755); err != nil {
+		http.Error(w, "Failed to create upload session", http.StatusInternalServerError)
+		log.Printf("Error creating upload directory: %v", err)
+		return
+	}
+	if err := os.WriteFile(uploadSessionPath(session.ID, ".part"), nil, 0644); err != nil {
+		http.Error(w, "Failed to create upload session", http.StatusInternalServerError)
+		log.Printf("Error creating upload session: %v", err)
+		return
+	}
+	if err := saveUploadSession(session); err != nil {
+		os.Remove(uploadSessionPath(session.ID, ".part"))
+		http.Error(w, "Failed to create upload session", http.StatusInternalServerError)
+		log.Printf("Error saving upload session: %v", err)
+		return
+	}
+
+	uploadSessionsMu.Lock()
+	loadUploadSessionsLocked()
+	uploadSessions[session.ID] = session
+	uploadSessionsMu.Unlock()
+
+	log.Printf("Upload session started: %s (%s)", session.ID, session.Filename)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session.UploadSession)
+}
+
+// uploadSessionHandler 查询、追加或取消单个会话
+func uploadSessionHandler(w http.ResponseWriter, r *http.Request, id string) {
+	session := getUploadSession(id)
+	if session == nil {
+		writeJSONError(w, http.StatusNotFound, "Upload session not found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		session.mu.Lock()
+		info := session.UploadSession
+		session.mu.Unlock()
+
+		w.Header().Set(UploadOffsetHeader, strconv.FormatInt(info.Offset, 10))
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			json.NewEncoder(w).Encode(info)
+		}
+
+	case http.MethodPatch:
+		appendUploadChunk(w, r, session)
+
+	case http.MethodDelete:
+		removeUploadSession(session)
+		addActivity("upload", fmt.Sprintf("Cancelled upload session %s: %s", id, session.Filename))
+		log.Printf("Upload session cancelled: %s", id)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "cancelled"})
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// completeUploadSessionHandler 校验已接收数据的SHA256后移入下载目录，之后与 /api/upload 相同地暂存、扫描并按需生成补丁；
+// 哈希不一致时删除会话，配额不足等其他失败保留会话，处理后可再次完成
+// POST /api/upload/{id}/complete {"hash": "...", "channel": "stable", "patchFrom": "1.0.0", "overwrite": true}（请求体可省略，hash 与 overwrite 默认使用创建会话时的值）
+func completeUploadSessionHandler(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session := getUploadSession(id)
+	if session == nil {
+		writeJSONError(w, http.StatusNotFound, "Upload session not found")
+		return
+	}
+
+	var req struct {
+		Hash      string `json:"hash"`
+		Channel   string `json:"channel"`
+		PatchFrom string `json:"patchFrom"`
+		Overwrite bool   `json:"overwrite"`
+	}
+	if r.ContentLength != 0 {
+		if !requireJSON(w, r) {
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// 持有会话锁直到文件移走，期间的分块请求等待后返回 404
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.removed {
+		writeJSONError(w, http.StatusNotFound, "Upload session not found")
+		return
+	}
+	if session.Size > 0 && session.Offset != session.Size {
+		w.Header().Set(UploadOffsetHeader, strconv.FormatInt(session.Offset, 10))
+		writeJSONError(w, http.StatusConflict, fmt.Sprintf("Upload incomplete: received %d of %d bytes", session.Offset, session.Size))
+		return
+	}
+	expected := strings.ToLower(strings.TrimSpace(req.Hash))
+	if expected == "" {
+		expected = session.Hash
+	}
+	if expected == "" {
+		writeJSONError(w, http.StatusBadRequest, "Missing hash")
+		return
+	}
+
+	partPath := uploadSessionPath(session.ID, ".part")
+	actual, err := calculateFileHash(partPath)
+	if err != nil {
+		http.Error(w, "Failed to read upload data", http.StatusInternalServerError)
+		log.Printf("Error hashing upload session %s: %v", session.ID, err)
+		return
+	}
+	if actual != expected {
+		removeUploadSessionLocked(session)
+		addActivity("upload", fmt.Sprintf("Discarded upload session %s: %s (hash mismatch)", session.ID, session.Filename))
+		log.Printf("Upload session hash mismatch: %s (expected %s, got %s)", session.ID, expected, actual)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":    "Uploaded file does not match expected hash",
+			"expected": expected,
+			"actual":   actual,
+		})
+		return
+	}
+
+	publishUpload(w, &receivedUpload{
+		Filename:     session.Filename,
+		OriginalName: session.Filename,
+		TempPath:     partPath,
+		Size:         session.Offset,
+		Hash:         actual,
+		Fields: map[string]string{
+			"channel":   req.Channel,
+			"patchFrom": req.PatchFrom,
+			"overwrite": strconv.FormatBool(req.Overwrite || session.Overwrite),
+		},
+	})
+
+	// 数据文件已移入下载目录（或被隔离）时会话结束
+	if _, err := os.Stat(partPath); os.IsNotExist(err) {
+		removeUploadSessionLocked(session)
+		log.Printf("Upload session completed: %s (%s, %d bytes)", session.ID, session.Filename, session.Offset)
+	}
+}
+
+// appendUploadChunk 将请求体追加到会话数据文件，偏移不一致时返回 409 和当前偏移以便客户端续传
+func appendUploadChunk(w http.ResponseWriter, r *http.Request, session *uploadSession) {
+	offset, err := strconv.ParseInt(r.Header.Get(UploadOffsetHeader), 10, 64)
+	if err != nil {
+		http.Error(w, "Missing or invalid Upload-Offset header", http.StatusBadRequest)
+		return
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.removed {
+		writeJSONError(w, http.StatusNotFound, "Upload session not found")
+		return
+	}
+	if offset != session.Offset {
+		w.Header().Set(UploadOffsetHeader, strconv.FormatInt(session.Offset, 10))
+		writeJSONError(w, http.StatusConflict, "Upload offset mismatch")
+		return
+	}
+
+	file, err := os.OpenFile(uploadSessionPath(session.ID, ".part"), os.O_WRONLY, 0644)
+	if err != nil {
+		http.Error(w, "Failed to open upload data", http.StatusInternalServerError)
+		log.Printf("Error opening upload session %s: %v", session.ID, err)
+		return
+	}
+	defer file.Close()
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		http.Error(w, "Failed to open upload data", http.StatusInternalServerError)
+		return
+	}
+
+	// 超出声明大小（未声明时为 maxUploadSize）的数据会被截断并报错
+	limit := session.Size
+	if limit == 0 {
+		limit = config.MaxUploadSize
+	}
+	// 第一个分块检查文件类型，之后的分块不再检查
+	var body io.Reader = r.Body
+	if offset == 0 {
+		reader := bufio.NewReaderSize(r.Body, SniffLength)
+		head, _ := reader.Peek(SniffLength)
+		if detected, ok := checkUploadType(session.Filename, r.Header.Get("Content-Type"), head); !ok {
+			rejectUploadType(w, session.Filename, claimedUploadType(session.Filename, ""), detected)
+			return
+		}
+		body = reader
+	}
+	if limit > 0 {
+		body = io.LimitReader(body, limit-offset+1)
+	}
+	written, copyErr := io.Copy(file, body)
+	if limit > 0 && offset+written > limit {
+		file.Truncate(offset)
+		http.Error(w, "Upload exceeds maximum size", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// 中断的分块也保留已写入的部分，客户端可从新的偏移继续
+	session.Offset = offset + written
+	session.UpdatedAt = time.Now()
+	if err := saveUploadSession(session); err != nil {
+		log.Printf("Error saving upload session %s: %v", session.ID, err)
+	}
+	if copyErr != nil {
+		log.Printf("Upload chunk interrupted: %s at %d bytes: %v", session.ID, session.Offset, copyErr)
+		return
+	}
+
+	w.Header().Set(UploadOffsetHeader, strconv.FormatInt(session.Offset, 10))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session.UploadSession)
+}
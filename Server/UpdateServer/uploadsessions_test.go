@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSweepUploadTempFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if err := os.MkdirAll(UploadSessionsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	files := map[string]time.Duration{
+		"upload-stale.tmp": 2 * time.Hour,
+		"upload-fresh.tmp": time.Minute,
+		"session.part":     2 * time.Hour,
+	}
+	for name, age := range files {
+		path := filepath.Join(UploadSessionsDir, name)
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := sweepUploadTempFiles(now, time.Hour); n != 1 {
+		t.Errorf("swept %d files, want 1", n)
+	}
+	for name, want := range map[string]bool{"upload-stale.tmp": false, "upload-fresh.tmp": true, "session.part": true} {
+		if _, err := os.Stat(filepath.Join(UploadSessionsDir, name)); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, want)
+		}
+	}
+
+	// 启动时以 0 调用删除全部遗留的临时文件，会话数据文件由会话清理负责
+	if n := sweepUploadTempFiles(now, 0); n != 1 {
+		t.Errorf("startup sweep removed %d files, want 1", n)
+	}
+	if _, err := os.Stat(filepath.Join(UploadSessionsDir, "session.part")); err != nil {
+		t.Errorf("session data removed: %v", err)
+	}
+}
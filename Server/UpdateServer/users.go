@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// UsersFile 管理账号列表，不存在时回退到内置的 AdminUsername/AdminPassword
+const UsersFile = "./users.json"
+
+// 账号角色
+const (
+	RoleAdmin    = "admin"
+	RoleUploader = "uploader"
+	RoleViewer   = "viewer"
+)
+
+// AdminUser 管理账号
+type AdminUser struct {
+	Username string `json:"username"`
+	// PasswordHash bcrypt 哈希，如 htpasswd -nbBC 10 用户名 密码 输出中冒号之后的部分
+	PasswordHash string `json:"passwordHash"`
+	// Role 为 admin、uploader 或 viewer
+	Role string `json:"role"`
+}
+
+var (
+	// adminUsers 从 users.json 加载的账号，为 nil 时使用内置账号
+	adminUsers   []AdminUser
+	adminUsersMu sync.RWMutex
+
+	// placeholderHash 用户名不存在时参与比较的哈希，成本取所有账号中最高的，使耗时与存在的用户一致
+	placeholderHash []byte
+)
+
+// adminUserKey 请求上下文中已认证账号的键
+type adminUserKey struct{}
+
+// isValidRole 是否为已知角色
+func isValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleUploader || role == RoleViewer
+}
+
+// loadUsers 启动时加载 users.json；文件存在但无效时拒绝启动，避免静默回退到内置账号
+func loadUsers() {
+	data, err := os.ReadFile(UsersFile)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Printf("No %s found, using built-in admin account", UsersFile)
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", UsersFile, err)
+	}
+
+	users, err := parseUsers(data)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", UsersFile, err)
+	}
+
+	cost := bcrypt.DefaultCost
+	for _, user := range users {
+		userCost, _ := bcrypt.Cost([]byte(user.PasswordHash))
+		cost = max(cost, userCost)
+	}
+	placeholder, err := bcrypt.GenerateFromPassword([]byte(AdminPassword), cost)
+	if err != nil {
+		log.Fatalf("Failed to generate placeholder hash: %v", err)
+	}
+
+	adminUsersMu.Lock()
+	adminUsers = users
+	placeholderHash = placeholder
+	adminUsersMu.Unlock()
+	log.Printf("Loaded %d admin accounts from %s", len(users), UsersFile)
+}
+
+// parseUsers 解析并校验账号列表
+func parseUsers(data []byte) ([]AdminUser, error) {
+	var users []AdminUser
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, errors.New("no users defined")
+	}
+
+	seen := make(map[string]bool)
+	for _, user := range users {
+		if user.Username == "" {
+			return nil, errors.New("user without username")
+		}
+		if seen[user.Username] {
+			return nil, fmt.Errorf("duplicate user %q", user.Username)
+		}
+		seen[user.Username] = true
+		if !isValidRole(user.Role) {
+			return nil, fmt.Errorf("user %q has invalid role %q", user.Username, user.Role)
+		}
+		if _, err := bcrypt.Cost([]byte(user.PasswordHash)); err != nil {
+			return nil, fmt.Errorf("user %q has invalid password hash: %v", user.Username, err)
+		}
+	}
+	return users, nil
+}
+
+// builtinAdmin 内置账号
+func builtinAdmin() AdminUser {
+	return AdminUser{Username: AdminUsername, Role: RoleAdmin}
+}
+
+// lookupUser 按用户名查找账号
+func lookupUser(username string) (AdminUser, bool) {
+	adminUsersMu.RLock()
+	defer adminUsersMu.RUnlock()
+
+	if adminUsers == nil {
+		return builtinAdmin(), username == AdminUsername
+	}
+	for _, user := range adminUsers {
+		if user.Username == username {
+			return user, true
+		}
+	}
+	return AdminUser{}, false
+}
+
+// checkAdminCredentials 校验账号密码，全程使用constant-time比较防止时序攻击：
+// 遍历全部账号比较用户名，用户名不存在时仍对占位哈希执行一次 bcrypt
+func checkAdminCredentials(username, password string) (AdminUser, bool) {
+	adminUsersMu.RLock()
+	users, placeholder := adminUsers, placeholderHash
+	adminUsersMu.RUnlock()
+
+	if users == nil {
+		usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(AdminUsername))
+		passwordMatch := subtle.ConstantTimeCompare([]byte(password), []byte(AdminPassword))
+		return builtinAdmin(), usernameMatch&passwordMatch == 1
+	}
+
+	var matched AdminUser
+	found := 0
+	for _, user := range users {
+		if subtle.ConstantTimeCompare([]byte(username), []byte(user.Username)) == 1 {
+			matched = user
+			found = 1
+		}
+	}
+
+	hash := []byte(matched.PasswordHash)
+	if found == 0 {
+		hash = placeholder
+	}
+	passwordMatch := 0
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil {
+		passwordMatch = 1
+	}
+	return matched, subtle.ConstantTimeEq(int32(found&passwordMatch), 1) == 1
+}
+
+// withAdminUser 将已认证账号写入请求上下文
+func withAdminUser(r *http.Request, user AdminUser) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), adminUserKey{}, user))
+}
+
+// currentUser 返回请求的已认证账号
+func currentUser(r *http.Request) (AdminUser, bool) {
+	user, ok := r.Context().Value(adminUserKey{}).(AdminUser)
+	return user, ok
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 清单校验结果状态
+const (
+	VerifyOK           = "ok"
+	VerifyHashMismatch = "hash_mismatch"
+	VerifySizeMismatch = "size_mismatch"
+	VerifyMissingFile  = "missing_file"
+	VerifyMissingHash  = "missing_hash"
+)
+
+// VerifyResult 单个版本的校验结果
+type VerifyResult struct {
+	Channel      string `json:"channel"`
+	Version      string `json:"version"`
+	File         string `json:"file"`
+	Platform     string `json:"platform,omitempty"`
+	Kind         string `json:"kind,omitempty"`
+	Status       string `json:"status"`
+	ExpectedHash string `json:"expectedHash,omitempty"`
+	ActualHash   string `json:"actualHash,omitempty"`
+	ExpectedSize int64  `json:"expectedSize,omitempty"`
+	ActualSize   int64  `json:"actualSize,omitempty"`
+}
+
+// VerifyReport 全部频道的校验报告
+type VerifyReport struct {
+	Passed    bool           `json:"passed"`
+	CheckedAt time.Time      `json:"checkedAt"`
+	Summary   map[string]int `json:"summary"`
+	Results   []VerifyResult `json:"results"`
+}
+
+// verifyManifests 核对所有频道清单中的哈希和大小与磁盘文件是否一致
+func verifyManifests() VerifyReport {
+	report := VerifyReport{
+		Passed:    true,
+		CheckedAt: time.Now(),
+		Summary:   make(map[string]int),
+		Results:   []VerifyResult{},
+	}
+
+	for _, channel := range Channels {
+		manifest, err := loadManifest(channel)
+		if err != nil {
+			continue
+		}
+
+		for _, update := range manifest.Updates {
+			for _, asset := range releaseAssets(update) {
+				result := verifyAsset(channel, update.Version, asset)
+				report.Summary[result.Status]++
+				if result.Status != VerifyOK {
+					report.Passed = false
+				}
+				report.Results = append(report.Results, result)
+			}
+		}
+	}
+	return report
+}
+
+// verifyAsset 校验版本的单个发布文件
+func verifyAsset(channel, version string, asset Asset) VerifyResult {
+	name := downloadFilename(asset.Url)
+	result := VerifyResult{
+		Channel:      channel,
+		Version:      version,
+		File:         name,
+		Platform:     asset.Platform,
+		Kind:         asset.Kind,
+		ExpectedHash: asset.Hash,
+		ExpectedSize: asset.Size,
+	}
+
+	filePath := filepath.Join(DownloadsDir, name)
+	info, err := os.Stat(filePath)
+	if name == "" || err != nil || info.IsDir() {
+		result.Status = VerifyMissingFile
+		return result
+	}
+	result.ActualSize = info.Size()
+
+	hash, err := cachedFileHash(filePath)
+	if err != nil {
+		result.Status = VerifyMissingFile
+		return result
+	}
+	result.ActualHash = hash
+
+	switch {
+	case asset.Hash == "":
+		result.Status = VerifyMissingHash
+	case !strings.EqualFold(asset.Hash, hash):
+		result.Status = VerifyHashMismatch
+	case asset.Size > 0 && asset.Size != info.Size():
+		result.Status = VerifySizeMismatch
+	default:
+		result.Status = VerifyOK
+	}
+	return result
+}
+
+// verifyManifestsHandler 返回清单与磁盘文件的一致性报告
+// GET /api/manifests/verify
+func verifyManifestsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(verifyManifests())
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Semver 解析后的语义化版本号
+type Semver struct {
+	Major, Minor, Patch int
+	Prerelease          string
+	Build               string
+}
+
+// parseSemver 按 SemVer 2.0 严格解析版本号：MAJOR.MINOR.PATCH[-预发布][+构建]，
+// 不接受 v 前缀、缺少的段和带前导零的数字
+func parseSemver(version string) (Semver, error) {
+	var v Semver
+	rest, build, hasBuild := strings.Cut(version, "+")
+	core, pre, hasPre := strings.Cut(rest, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return v, errors.New("expected MAJOR.MINOR.PATCH")
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		if !isNumericIdentifier(part) {
+			return v, fmt.Errorf("invalid numeric component %q", part)
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return v, fmt.Errorf("invalid numeric component %q", part)
+		}
+		numbers[i] = n
+	}
+	v.Major, v.Minor, v.Patch = numbers[0], numbers[1], numbers[2]
+
+	if hasPre {
+		for _, id := range strings.Split(pre, ".") {
+			if !isSemverIdentifier(id) {
+				return v, fmt.Errorf("invalid pre-release identifier %q", id)
+			}
+			if strings.Trim(id, "0123456789") == "" && !isNumericIdentifier(id) {
+				return v, fmt.Errorf("numeric pre-release identifier %q has leading zeros", id)
+			}
+		}
+		v.Prerelease = pre
+	}
+	if hasBuild {
+		for _, id := range strings.Split(build, ".") {
+			if !isSemverIdentifier(id) {
+				return v, fmt.Errorf("invalid build identifier %q", id)
+			}
+		}
+		v.Build = build
+	}
+	return v, nil
+}
+
+// isNumericIdentifier 非空、只含数字且没有前导零
+func isNumericIdentifier(s string) bool {
+	if s == "" || strings.Trim(s, "0123456789") != "" {
+		return false
+	}
+	return s == "0" || s[0] != '0'
+}
+
+// isSemverIdentifier 非空且只含字母、数字和连字符
+func isSemverIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// VersionFieldError 清单中格式错误的版本字段
+type VersionFieldError struct {
+	Field   string
+	Version string
+	Reason  string
+}
+
+func (e *VersionFieldError) Error() string {
+	return fmt.Sprintf("%s %q: %s", e.Field, e.Version, e.Reason)
+}
+
+// validateVersion 校验字段值为 SemVer 2.0 版本号，错误中带有字段名
+func validateVersion(field, version string) error {
+	if version == "" {
+		return &VersionFieldError{Field: field, Version: version, Reason: "required"}
+	}
+	if _, err := parseSemver(version); err != nil {
+		return &VersionFieldError{Field: field, Version: version, Reason: "invalid semantic version: " + err.Error()}
+	}
+	return nil
+}
+
+// validateUpdateVersions 校验单个版本条目的 version、minimumCompatibleVersion（可为空）与依赖项格式
+func validateUpdateVersions(prefix string, update UpdateInfo) error {
+	if err := validateVersion(prefix+"version", update.Version); err != nil {
+		return err
+	}
+	if update.MinimumCompatibleVersion != "" {
+		if err := validateVersion(prefix+"minimumCompatibleVersion", update.MinimumCompatibleVersion); err != nil {
+			return err
+		}
+	}
+	for i, dep := range update.Dependencies {
+		if _, err := parseDependency(dep); err != nil {
+			return &VersionFieldError{Field: fmt.Sprintf("%sdependencies[%d]", prefix, i), Version: dep, Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// validateManifestVersions 校验清单中所有版本字段，并确认 latestVersion 存在于 updates 中
+func validateManifestVersions(manifest *UpdateManifest) error {
+	if manifest.LatestVersion != "" {
+		if err := validateVersion("latestVersion", manifest.LatestVersion); err != nil {
+			return err
+		}
+	}
+	if manifest.MinimumVersion != "" {
+		if err := validateVersion("minimumVersion", manifest.MinimumVersion); err != nil {
+			return err
+		}
+	}
+	for i, update := range manifest.Updates {
+		if err := validateUpdateVersions(fmt.Sprintf("updates[%d].", i), update); err != nil {
+			return err
+		}
+	}
+	if manifest.LatestVersion != "" && findUpdate(manifest, manifest.LatestVersion) == nil {
+		return &VersionFieldError{Field: "latestVersion", Version: manifest.LatestVersion, Reason: "not found in updates"}
+	}
+	return nil
+}
+
+// writeVersionFieldError 返回 400 并指出出错的字段
+func writeVersionFieldError(w http.ResponseWriter, err error) {
+	var fieldErr *VersionFieldError
+	if !errors.As(err, &fieldErr) {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": fieldErr.Error(),
+		"field": fieldErr.Field,
+		"value": fieldErr.Version,
+	})
+}
+
+// compareVersions 比较两个语义化版本号，返回 -1、0 或 1
+// 预发布版本（如 1.2.0-beta.1）低于同号正式版本
+func compareVersions(a, b string) int {
+	coreA, preA, _ := strings.Cut(strings.TrimPrefix(a, "v"), "-")
+	coreB, preB, _ := strings.Cut(strings.TrimPrefix(b, "v"), "-")
+
+	partsA := strings.Split(coreA, ".")
+	partsB := strings.Split(coreB, ".")
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var numA, numB int
+		if i < len(partsA) {
+			numA, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			numB, _ = strconv.Atoi(partsB[i])
+		}
+		if numA != numB {
+			if numA < numB {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	case preA < preB:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// isValidVersion 检查版本号格式：数字段以点分隔，可带 v 前缀和 -预发布标识
+func isValidVersion(version string) bool {
+	core, pre, hasPre := strings.Cut(strings.TrimPrefix(version, "v"), "-")
+	if core == "" || (hasPre && pre == "") {
+		return false
+	}
+	for _, part := range strings.Split(core, ".") {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	for _, c := range pre {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '.' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
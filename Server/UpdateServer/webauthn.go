@@ -0,0 +1,550 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WebAuthnCredentialsFile 已注册的通行密钥（仅公钥）
+const WebAuthnCredentialsFile = "./keys/webauthn.json"
+
+// webAuthnChallengeTTL 注册/登录挑战的有效期
+const webAuthnChallengeTTL = 5 * time.Minute
+
+// COSE 算法标识
+const (
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+)
+
+// authenticatorData 标志位
+const (
+	authFlagUserPresent  = 0x01
+	authFlagAttestedData = 0x40
+)
+
+// WebAuthnConfig 管理面板通行密钥登录配置，RPID 与 Origin 均配置后启用
+type WebAuthnConfig struct {
+	// RPID 依赖方ID，通常为面板域名，如 "updates.example.com"
+	RPID string `json:"rpId"`
+	// RPName 认证器中显示的名称
+	RPName string `json:"rpName"`
+	// Origin 面板的完整来源，如 "https://updates.example.com"
+	Origin string `json:"origin"`
+}
+
+// WebAuthnCredential 已注册的通行密钥
+type WebAuthnCredential struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Algorithm  int        `json:"algorithm"`
+	PublicKey  string     `json:"publicKey"`
+	SignCount  uint32     `json:"signCount"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+	// User 注册该凭据的账号，为空时属于内置管理员账号
+	User string `json:"user,omitempty"`
+}
+
+var (
+	webAuthnCredentials   []WebAuthnCredential
+	webAuthnCredentialsMu sync.Mutex
+
+	// webAuthnChallenges 未使用的挑战及其用途（register/login）和过期时间
+	webAuthnChallenges   = make(map[string]webAuthnChallenge)
+	webAuthnChallengesMu sync.Mutex
+)
+
+type webAuthnChallenge struct {
+	purpose   string
+	expiresAt time.Time
+}
+
+// webAuthnEnabled 是否配置了通行密钥登录
+func webAuthnEnabled() bool {
+	return config.WebAuthn.RPID != "" && config.WebAuthn.Origin != ""
+}
+
+// loadWebAuthnCredentials 加载已注册的通行密钥
+func loadWebAuthnCredentials() {
+	data, err := os.ReadFile(WebAuthnCredentialsFile)
+	if err != nil {
+		return
+	}
+
+	webAuthnCredentialsMu.Lock()
+	defer webAuthnCredentialsMu.Unlock()
+
+	if err := json.Unmarshal(data, &webAuthnCredentials); err != nil {
+		log.Printf("Error loading WebAuthn credentials: %v", err)
+		return
+	}
+	log.Printf("WebAuthn credentials loaded: %d", len(webAuthnCredentials))
+}
+
+// saveWebAuthnCredentialsLocked 保存通行密钥，调用方需持有锁
+func saveWebAuthnCredentialsLocked() error {
+	if err := os.MkdirAll(filepath.Dir(WebAuthnCredentialsFile), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(webAuthnCredentials, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicWriteFile(WebAuthnCredentialsFile, data, 0600)
+}
+
+// newWebAuthnChallenge 生成一次性挑战
+func newWebAuthnChallenge(purpose string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(buf)
+	now := time.Now()
+
+	webAuthnChallengesMu.Lock()
+	for c, pending := range webAuthnChallenges {
+		if now.After(pending.expiresAt) {
+			delete(webAuthnChallenges, c)
+		}
+	}
+	webAuthnChallenges[challenge] = webAuthnChallenge{purpose: purpose, expiresAt: now.Add(webAuthnChallengeTTL)}
+	webAuthnChallengesMu.Unlock()
+
+	return challenge, nil
+}
+
+// consumeWebAuthnChallenge 校验并作废挑战
+func consumeWebAuthnChallenge(challenge, purpose string) bool {
+	webAuthnChallengesMu.Lock()
+	defer webAuthnChallengesMu.Unlock()
+
+	pending, ok := webAuthnChallenges[challenge]
+	delete(webAuthnChallenges, challenge)
+	return ok && pending.purpose == purpose && time.Now().Before(pending.expiresAt)
+}
+
+// verifyClientData 校验 clientDataJSON 的类型、挑战和来源
+func verifyClientData(raw []byte, ceremony, purpose string) error {
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return errors.New("invalid clientDataJSON")
+	}
+	if clientData.Type != ceremony {
+		return fmt.Errorf("unexpected ceremony type %q", clientData.Type)
+	}
+	if clientData.Origin != config.WebAuthn.Origin {
+		return fmt.Errorf("unexpected origin %q", clientData.Origin)
+	}
+	if !consumeWebAuthnChallenge(clientData.Challenge, purpose) {
+		return errors.New("unknown or expired challenge")
+	}
+	return nil
+}
+
+// parseAuthenticatorData 校验 authenticatorData 头部，返回标志位、签名计数和剩余数据
+func parseAuthenticatorData(authData []byte) (flags byte, signCount uint32, rest []byte, err error) {
+	if len(authData) < 37 {
+		return 0, 0, nil, errors.New("authenticator data too short")
+	}
+
+	rpIDHash := sha256.Sum256([]byte(config.WebAuthn.RPID))
+	if !bytes.Equal(authData[:32], rpIDHash[:]) {
+		return 0, 0, nil, errors.New("rpId hash mismatch")
+	}
+
+	flags = authData[32]
+	if flags&authFlagUserPresent == 0 {
+		return 0, 0, nil, errors.New("user not present")
+	}
+	return flags, binary.BigEndian.Uint32(authData[33:37]), authData[37:], nil
+}
+
+// parseCOSEKey 将COSE公钥转换为PKIX格式
+func parseCOSEKey(data []byte) (alg int, pkix []byte, err error) {
+	decoded, _, err := decodeCBOR(data)
+	if err != nil {
+		return 0, nil, err
+	}
+	key, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return 0, nil, errors.New("invalid COSE key")
+	}
+
+	algorithm, _ := key[int64(3)].(int64)
+	x, _ := key[int64(-2)].([]byte)
+
+	var pub interface{}
+	switch algorithm {
+	case coseAlgES256:
+		y, _ := key[int64(-3)].([]byte)
+		if crv, _ := key[int64(-1)].(int64); crv != 1 || len(x) != 32 || len(y) != 32 {
+			return 0, nil, errors.New("unsupported EC2 key")
+		}
+		ecKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !ecKey.Curve.IsOnCurve(ecKey.X, ecKey.Y) {
+			return 0, nil, errors.New("EC2 point is not on curve")
+		}
+		pub = ecKey
+	case coseAlgEdDSA:
+		if crv, _ := key[int64(-1)].(int64); crv != 6 || len(x) != ed25519.PublicKeySize {
+			return 0, nil, errors.New("unsupported OKP key")
+		}
+		pub = ed25519.PublicKey(x)
+	default:
+		return 0, nil, fmt.Errorf("unsupported algorithm %d", algorithm)
+	}
+
+	pkix, err = x509.MarshalPKIXPublicKey(pub)
+	return int(algorithm), pkix, err
+}
+
+// verifyAssertionSignature 使用已注册公钥校验登录签名
+func verifyAssertionSignature(cred WebAuthnCredential, signed, signature []byte) error {
+	der, err := base64.StdEncoding.DecodeString(cred.PublicKey)
+	if err != nil {
+		return err
+	}
+	pub, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return err
+	}
+
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return errors.New("invalid signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, signed, signature) {
+			return errors.New("invalid signature")
+		}
+	default:
+		return errors.New("unsupported key type")
+	}
+	return nil
+}
+
+// credentialDescriptors 返回已注册密钥的描述符列表
+func credentialDescriptors() []map[string]string {
+	webAuthnCredentialsMu.Lock()
+	defer webAuthnCredentialsMu.Unlock()
+
+	descriptors := make([]map[string]string, 0, len(webAuthnCredentials))
+	for _, cred := range webAuthnCredentials {
+		descriptors = append(descriptors, map[string]string{"type": "public-key", "id": cred.ID})
+	}
+	return descriptors
+}
+
+// webAuthnHandler 处理通行密钥注册与登录
+// POST /admin/webauthn/register/begin   （需已认证）
+// POST /admin/webauthn/register/finish  （需已认证）
+// POST /admin/webauthn/login/begin
+// POST /admin/webauthn/login/finish     成功后签发会话Cookie
+func webAuthnHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !webAuthnEnabled() {
+		http.Error(w, "WebAuthn is not configured", http.StatusNotFound)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/admin/webauthn/register/begin":
+		basicAuth(webAuthnRegisterBegin)(w, r)
+	case "/admin/webauthn/register/finish":
+		basicAuth(webAuthnRegisterFinish)(w, r)
+	case "/admin/webauthn/login/begin":
+		webAuthnLoginBegin(w, r)
+	case "/admin/webauthn/login/finish":
+		webAuthnLoginFinish(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// webAuthnRegisterBegin 返回注册选项
+func webAuthnRegisterBegin(w http.ResponseWriter, r *http.Request) {
+	challenge, err := newWebAuthnChallenge("register")
+	if err != nil {
+		http.Error(w, "Failed to create challenge", http.StatusInternalServerError)
+		return
+	}
+
+	rpName := config.WebAuthn.RPName
+	if rpName == "" {
+		rpName = "LizardClient Update Server"
+	}
+
+	// 认证器按 user.id 区分账号，同一认证器为不同账号注册的通行密钥不会互相覆盖
+	username := AdminUsername
+	if user, ok := currentUser(r); ok {
+		username = user.Username
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"publicKey": map[string]interface{}{
+			"challenge": challenge,
+			"rp":        map[string]string{"id": config.WebAuthn.RPID, "name": rpName},
+			"user": map[string]string{
+				"id":          base64.RawURLEncoding.EncodeToString([]byte(username)),
+				"name":        username,
+				"displayName": username,
+			},
+			"pubKeyCredParams": []map[string]interface{}{
+				{"type": "public-key", "alg": coseAlgES256},
+				{"type": "public-key", "alg": coseAlgEdDSA},
+			},
+			"excludeCredentials": credentialDescriptors(),
+			"authenticatorSelection": map[string]string{
+				"residentKey":      "preferred",
+				"userVerification": "preferred",
+			},
+			"attestation": "none",
+			"timeout":     webAuthnChallengeTTL.Milliseconds(),
+		},
+	})
+}
+
+// webAuthnRegisterFinish 校验注册响应并保存公钥（不校验认证器证明）
+func webAuthnRegisterFinish(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name     string `json:"name"`
+		Response struct {
+			ClientDataJSON    string `json:"clientDataJSON"`
+			AttestationObject string `json:"attestationObject"`
+		} `json:"response"`
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	clientData, err1 := base64.RawURLEncoding.DecodeString(req.Response.ClientDataJSON)
+	attestation, err2 := base64.RawURLEncoding.DecodeString(req.Response.AttestationObject)
+	if err1 != nil || err2 != nil {
+		http.Error(w, "Invalid encoding", http.StatusBadRequest)
+		return
+	}
+
+	if err := verifyClientData(clientData, "webauthn.create", "register"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	decoded, _, err := decodeCBOR(attestation)
+	object, ok := decoded.(map[interface{}]interface{})
+	if err != nil || !ok {
+		http.Error(w, "Invalid attestation object", http.StatusBadRequest)
+		return
+	}
+	authData, _ := object["authData"].([]byte)
+
+	flags, signCount, rest, err := parseAuthenticatorData(authData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 已证明的凭据数据：AAGUID(16) + 凭据ID长度(2) + 凭据ID + COSE公钥
+	if flags&authFlagAttestedData == 0 || len(rest) < 18 {
+		http.Error(w, "Missing attested credential data", http.StatusBadRequest)
+		return
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	if len(rest) < 18+idLen {
+		http.Error(w, "Invalid credential data", http.StatusBadRequest)
+		return
+	}
+	credID := rest[18 : 18+idLen]
+
+	alg, pkix, err := parseCOSEKey(rest[18+idLen:])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := req.Name
+	if name == "" {
+		name = fmt.Sprintf("Passkey %s", time.Now().Format("2006-01-02"))
+	}
+	cred := WebAuthnCredential{
+		ID:        base64.RawURLEncoding.EncodeToString(credID),
+		Name:      name,
+		Algorithm: alg,
+		PublicKey: base64.StdEncoding.EncodeToString(pkix),
+		SignCount: signCount,
+		CreatedAt: time.Now(),
+	}
+	if user, ok := currentUser(r); ok {
+		cred.User = user.Username
+	}
+
+	webAuthnCredentialsMu.Lock()
+	for _, existing := range webAuthnCredentials {
+		if existing.ID == cred.ID {
+			webAuthnCredentialsMu.Unlock()
+			http.Error(w, "Credential already registered", http.StatusConflict)
+			return
+		}
+	}
+	webAuthnCredentials = append(webAuthnCredentials, cred)
+	err = saveWebAuthnCredentialsLocked()
+	webAuthnCredentialsMu.Unlock()
+	if err != nil {
+		http.Error(w, "Failed to save credential", http.StatusInternalServerError)
+		log.Printf("Error saving WebAuthn credentials: %v", err)
+		return
+	}
+
+	addActivity("webauthn", fmt.Sprintf("Registered passkey: %s", cred.Name))
+	log.Printf("WebAuthn credential registered: %s", cred.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "id": cred.ID})
+}
+
+// webAuthnLoginBegin 返回登录选项
+func webAuthnLoginBegin(w http.ResponseWriter, r *http.Request) {
+	allow := credentialDescriptors()
+	if len(allow) == 0 {
+		http.Error(w, "No passkeys registered", http.StatusNotFound)
+		return
+	}
+
+	challenge, err := newWebAuthnChallenge("login")
+	if err != nil {
+		http.Error(w, "Failed to create challenge", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"publicKey": map[string]interface{}{
+			"challenge":        challenge,
+			"rpId":             config.WebAuthn.RPID,
+			"allowCredentials": allow,
+			"userVerification": "preferred",
+			"timeout":          webAuthnChallengeTTL.Milliseconds(),
+		},
+	})
+}
+
+// webAuthnLoginFinish 校验登录签名并签发会话
+func webAuthnLoginFinish(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID       string `json:"id"`
+		Response struct {
+			ClientDataJSON    string `json:"clientDataJSON"`
+			AuthenticatorData string `json:"authenticatorData"`
+			Signature         string `json:"signature"`
+		} `json:"response"`
+	}
+	if !requireJSON(w, r) {
+		return
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	clientData, err1 := base64.RawURLEncoding.DecodeString(req.Response.ClientDataJSON)
+	authData, err2 := base64.RawURLEncoding.DecodeString(req.Response.AuthenticatorData)
+	signature, err3 := base64.RawURLEncoding.DecodeString(req.Response.Signature)
+	if err1 != nil || err2 != nil || err3 != nil {
+		http.Error(w, "Invalid encoding", http.StatusBadRequest)
+		return
+	}
+
+	if err := verifyClientData(clientData, "webauthn.get", "login"); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	_, signCount, _, err := parseAuthenticatorData(authData)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	webAuthnCredentialsMu.Lock()
+	defer webAuthnCredentialsMu.Unlock()
+
+	var cred *WebAuthnCredential
+	for i := range webAuthnCredentials {
+		if webAuthnCredentials[i].ID == req.ID {
+			cred = &webAuthnCredentials[i]
+			break
+		}
+	}
+	if cred == nil {
+		http.Error(w, "Unknown credential", http.StatusUnauthorized)
+		return
+	}
+
+	clientDataHash := sha256.Sum256(clientData)
+	signed := append(append([]byte{}, authData...), clientDataHash[:]...)
+	if err := verifyAssertionSignature(*cred, signed, signature); err != nil {
+		http.Error(w, "Invalid signature", http.StatusUnauthorized)
+		log.Printf("WebAuthn login failed for %s: %v", cred.Name, err)
+		return
+	}
+
+	// 签名计数未递增说明认证器可能被克隆
+	if (signCount != 0 || cred.SignCount != 0) && signCount <= cred.SignCount {
+		http.Error(w, "Sign counter did not increase", http.StatusUnauthorized)
+		log.Printf("WebAuthn sign counter regression for %s", cred.Name)
+		return
+	}
+
+	now := time.Now()
+	cred.SignCount = signCount
+	cred.LastUsedAt = &now
+	if err := saveWebAuthnCredentialsLocked(); err != nil {
+		log.Printf("Error saving WebAuthn credentials: %v", err)
+	}
+
+	username := cred.User
+	if username == "" {
+		username = AdminUsername
+	}
+	if err := issueSession(w, r, username); err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	log.Printf("WebAuthn login: %s", cred.Name)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
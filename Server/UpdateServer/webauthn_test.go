@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebAuthnRegisterBeginUsesCurrentUser(t *testing.T) {
+	for _, username := range []string{"alice", "bob"} {
+		req := httptest.NewRequest(http.MethodPost, "/admin/webauthn/register/begin", nil)
+		req = req.WithContext(context.WithValue(req.Context(), adminUserKey{}, AdminUser{Username: username, Role: RoleAdmin}))
+		rec := httptest.NewRecorder()
+		webAuthnRegisterBegin(rec, req)
+
+		var options struct {
+			PublicKey struct {
+				User struct {
+					ID   string `json:"id"`
+					Name string `json:"name"`
+				} `json:"user"`
+			} `json:"publicKey"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&options); err != nil {
+			t.Fatal(err)
+		}
+		user := options.PublicKey.User
+		if want := base64.RawURLEncoding.EncodeToString([]byte(username)); user.ID != want || user.Name != username {
+			t.Errorf("registration user = %+v, want id %s and name %s", user, want, username)
+		}
+	}
+}
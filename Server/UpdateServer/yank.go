@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// yankHandler 撤回或恢复指定版本
+// 撤回的版本不再参与最新版本解析，但文件仍可按确切版本下载
+func yankHandler(w http.ResponseWriter, r *http.Request, channel, version string, yank bool) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !isValidChannel(channel) {
+		http.Error(w, "Invalid channel", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if yank && r.ContentLength != 0 {
+		if !requireJSON(w, r) {
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest, err := loadManifest(channel)
+	if err != nil {
+		http.Error(w, "Failed to read manifest", http.StatusInternalServerError)
+		log.Printf("Error reading manifest: %v", err)
+		return
+	}
+
+	update := findUpdate(manifest, version)
+	if update == nil {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+
+	update.Yanked = yank
+	update.YankedReason = ""
+	if yank {
+		update.YankedReason = req.Reason
+	}
+
+	// 重新解析最新版本，跳过已撤回的版本
+	if latest := latestUpdate(manifest); latest != nil {
+		manifest.LatestVersion = latest.Version
+	}
+	manifest.LastUpdated = time.Now()
+
+	err = saveManifest(channel, manifest)
+	if errors.Is(err, errInvalidManifest) {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save manifest", http.StatusInternalServerError)
+		log.Printf("Error saving manifest: %v", err)
+		return
+	}
+
+	if yank {
+		addActivity("yank", fmt.Sprintf("Yanked %s/%s: %s", channel, version, req.Reason))
+		log.Printf("Version yanked: %s/%s", channel, version)
+	} else {
+		addActivity("unyank", fmt.Sprintf("Unyanked %s/%s", channel, version))
+		log.Printf("Version unyanked: %s/%s", channel, version)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":        "success",
+		"latestVersion": manifest.LatestVersion,
+	})
+}
@@ -29,6 +29,6 @@ echo 按 Ctrl+C 停止服务器
 echo ============================================
 echo.
 
-go run main.go
+go run .
 
 pause